@@ -15,6 +15,31 @@
 # Build docker image (TODO: should it be defined in docker-compose.yml to avoid any env issues?)
 docker build -t local/migration-backend-tests "${BASE_TEST_FOLDER}/verification"
 
+# Fail fast, with the migrate messages, if testcafe did not import every row of the migration CSVs
+M=/migrations
+docker run --network gateway --rm -e IDC_VERIFY_USER=admin -e IDC_VERIFY_PASSWORD=password -v "${TESTCAFE_TESTS_FOLDER}/migrations":${M} local/migration-backend-tests \
+  go run . migrations \
+    idc_ingest_taxonomy_persons=${M}/persons-01.csv,${M}/persons-02.csv,${M}/islandora_object-persons.csv \
+    idc_ingest_taxonomy_family=${M}/family-01.csv,${M}/family-02.csv \
+    idc_ingest_taxonomy_accessrights=${M}/accessrights.csv \
+    idc_ingest_taxonomy_islandora_accessterms=${M}/accessterms.csv,${M}/islandora_object-accessterms.csv \
+    idc_ingest_taxonomy_copyrightanduse=${M}/copyrightanduse.csv \
+    idc_ingest_taxonomy_subject=${M}/subject.csv,${M}/islandora_object-subjects.csv \
+    idc_ingest_taxonomy_geolocation=${M}/geolocation.csv,${M}/islandora_object-geolocations.csv \
+    idc_ingest_taxonomy_resourcetypes=${M}/resourcetypes.csv \
+    idc_ingest_taxonomy_language=${M}/language.csv \
+    idc_ingest_taxonomy_corporatebody=${M}/corporatebody-01.csv,${M}/corporatebody-02.csv,${M}/islandora_object-corporatebodies.csv \
+    idc_ingest_taxonomy_genre=${M}/genre.csv,${M}/islandora_object-genres.csv \
+    idc_ingest_new_collection=${M}/collection-01.csv,${M}/collection-02.csv,${M}/islandora_object-collections.csv,${M}/media-collection.csv \
+    idc_ingest_new_items=${M}/islandora_object.csv,${M}/media-islandora_object.csv \
+    idc_ingest_media_image=${M}/media-image.csv \
+    idc_ingest_media_document=${M}/media-document.csv \
+    idc_ingest_media_extracted_text=${M}/media-extracted_text.csv \
+    idc_ingest_media_file=${M}/media-file.csv \
+    idc_ingest_media_video=${M}/media-video.csv \
+    idc_ingest_media_remote_video=${M}/media-remote_video.csv \
+    idc_ingest_media_audio=${M}/media-audio.csv
+
 # Execute tests in docker image, on the same docker network (gateway, idc_default?) as Drupal
 # TODO: expose logs when failing tests?
 # N.B. trailing slash on the BASE_ASSETS_URL is important.  uses the internal URL.
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The entity types crawled by an audit
+var auditedEntities = map[string]bool{
+	"node":          true,
+	"media":         true,
+	"file":          true,
+	"taxonomy_term": true,
+}
+
+// Crawls every resource of the entity types advertised by the JSONAPI entry point, answering the resources
+// keyed by resource type.  Resource types that cannot be crawled are added to the report as errors; resource types
+// that are not accessible to the current user are added as warnings.  Neither are present in the answered map.
+func (c *jsonApiClient) crawl(report *Report, check string, entities map[string]bool) map[DrupalType][]JsonApiResource {
+	crawled := make(map[DrupalType][]JsonApiResource)
+
+	types, err := c.resourceTypes()
+	if err != nil {
+		report.error(check, c.baseUrl+"/jsonapi", "unable to list JSONAPI resource types: %s", err)
+		return crawled
+	}
+
+	for _, t := range types {
+		if !entities[t.entity()] {
+			continue
+		}
+
+		resources, err := c.collection(fmt.Sprintf("%s/jsonapi/%s/%s", c.baseUrl, t.entity(), t.bundle()))
+		switch {
+		case errors.Is(err, ErrForbidden):
+			report.warning(check, string(t), "unable to crawl: %s", err)
+			continue
+		case err != nil:
+			report.error(check, string(t), "unable to crawl: %s", err)
+			continue
+		}
+
+		crawled[t] = resources
+		report.Counts[string(t)] = len(resources)
+	}
+
+	return crawled
+}
+
+// Audits the repository, checking the referential integrity of every resource crawled
+func (c *jsonApiClient) audit(report *Report) {
+	crawled := c.crawl(report, "audit", auditedEntities)
+	for _, resources := range crawled {
+		report.Checked += len(resources)
+	}
+	c.checkIntegrity(newCrawlIndex(crawled), report)
+}
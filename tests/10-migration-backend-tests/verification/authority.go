@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// An authority declared as the source of an authority link, and the URIs of its entities
+type authoritySource struct {
+	name string
+	// matches the URIs of the entities of the authority
+	pattern *regexp.Regexp
+	// a URI of the authority, used to describe the pattern
+	example string
+	// answers why a URI matching the pattern does not identify an entity, or the empty string, may be nil
+	validate func(m []string) string
+}
+
+// The authority sources whose URIs idc-verify recognizes, by the key of the source in field_authority_link.  Sources
+// not present here (e.g. "local" and "other") may link to any URI.
+var authoritySources = map[string]authoritySource{
+	"lcnaf": {
+		name:    "Library of Congress Name Authority File",
+		pattern: regexp.MustCompile(`^https?://id\.loc\.gov/authorities/names/(n|nb|no|nr)[0-9]{8,10}(\.html)?$`),
+		example: "http://id.loc.gov/authorities/names/n50034947",
+	},
+	"lgcft": {
+		name:    "Library of Congress Genre/Form Terms",
+		pattern: regexp.MustCompile(`^https?://id\.loc\.gov/authorities/genreForms/gf[0-9]{10}(\.html)?$`),
+		example: "http://id.loc.gov/authorities/genreForms/gf2017027249",
+	},
+	"viaf": {
+		name:    "Virtual International Authority File",
+		pattern: regexp.MustCompile(`^https?://(www\.)?viaf\.org/viaf/[1-9][0-9]*/?$`),
+		example: "http://viaf.org/viaf/7407018",
+	},
+	"wikidata": {
+		name:    "Wikidata",
+		pattern: regexp.MustCompile(`^https?://(www\.)?wikidata\.org/(wiki|entity)/Q[1-9][0-9]*$`),
+		example: "https://www.wikidata.org/wiki/Q60809",
+	},
+	"fast": {
+		name:    "Faceted Application of Subject Terminology",
+		pattern: regexp.MustCompile(`^https?://id\.worldcat\.org/fast/[1-9][0-9]*/?$`),
+		example: "http://id.worldcat.org/fast/1204920",
+	},
+	"aat": {
+		name:    "Art & Architecture Thesaurus",
+		pattern: regexp.MustCompile(`^https?://vocab\.getty\.edu/(page/)?aat/3[0-9]{8}$`),
+		example: "http://vocab.getty.edu/aat/300054152",
+	},
+	"ulan": {
+		name:    "Getty Union List of Artist Names",
+		pattern: regexp.MustCompile(`^https?://vocab\.getty\.edu/(page/)?ulan/5[0-9]{8}$`),
+		example: "http://vocab.getty.edu/ulan/500024301",
+	},
+	"geonames": {
+		name:    "GeoNames",
+		pattern: regexp.MustCompile(`^https?://(www\.|sws\.)?geonames\.org/[1-9][0-9]*(/[^/?#]*)?/?$`),
+		example: "https://sws.geonames.org/5509151/",
+	},
+	"iso639-2b": {
+		name:     "Library of Congress MARC List for Languages",
+		pattern:  regexp.MustCompile(`^https?://id\.loc\.gov/vocabulary/(iso639-2|languages)/([a-z]{3})(\.html)?$`),
+		example:  "http://id.loc.gov/vocabulary/iso639-2/eng",
+		validate: validateIso6392Uri,
+	},
+	"orcid": {
+		name:     "ORCID",
+		pattern:  regexp.MustCompile(`^https?://orcid\.org/([0-9]{4}-[0-9]{4}-[0-9]{4}-[0-9]{3}[0-9X])$`),
+		example:  "https://orcid.org/0000-0002-1825-0097",
+		validate: validateOrcid,
+	},
+	"mesh": {
+		name:    "Medical Subject Headings",
+		pattern: regexp.MustCompile(`^https?://id\.nlm\.nih\.gov/mesh/[CDMQ][0-9]{6,9}$`),
+		example: "http://id.nlm.nih.gov/mesh/D000001",
+	},
+	"rightsstatements": {
+		name:    "RightsStatements.org",
+		pattern: regexp.MustCompile(`^https?://rightsstatements\.org/(vocab|page)/[A-Za-z-]+/1\.0/?$`),
+		example: "http://rightsstatements.org/vocab/InC/1.0/",
+	},
+	"dcmi_types": {
+		name:    "DCMI Type Vocabulary",
+		pattern: regexp.MustCompile(`^https?://(purl\.org/dc/dcmitype/|(www\.)?dublincore\.org/specifications/dublin-core/dcmi-terms/dcmitype/)[A-Z][A-Za-z]+/?$`),
+		example: "http://purl.org/dc/dcmitype/StillImage",
+	},
+}
+
+// The authority sources permitted by the field_authority_link of each vocabulary, following the `authority_sources` of
+// `field.field.taxonomy_term.<vocabulary>.field_authority_link.yml`
+var vocabularyAuthoritySources = map[string][]string{
+	"access_rights":     {"local"},
+	"copyright_and_use": {"rightsstatements"},
+	"corporate_body":    {"lcnaf", "viaf", "other"},
+	"family":            {"lcnaf", "viaf", "other"},
+	"genre":             {"aat", "fast", "lgcft"},
+	"geo_location":      {"geonames", "fast"},
+	"language":          {"iso639-2b"},
+	"person":            {"viaf", "lcnaf", "ulan", "orcid", "other"},
+	"resource_types":    {"dcmi_types"},
+	"subject":           {"iso19115", "fast", "mesh", "other"},
+}
+
+// Answers why the language of an ISO 639-2 URI is not a language
+func validateIso6392Uri(m []string) string {
+	if level, problem := languageCodeProblem(m[2]); level == LevelError {
+		return "its language " + problem
+	}
+	return ""
+}
+
+// Answers why an ORCID iD is not valid: its last character is a ISO 7064 MOD 11-2 check digit
+func validateOrcid(m []string) string {
+	digits := strings.ReplaceAll(m[1], "-", "")
+	total := 0
+	for _, d := range digits[:len(digits)-1] {
+		total = (total + int(d-'0')) * 2
+	}
+	check := (12 - total%11) % 11
+	expected := fmt.Sprint(check)
+	if check == 10 {
+		expected = "X"
+	}
+	if digits[len(digits)-1:] != expected {
+		return fmt.Sprintf("its check digit is not %s", expected)
+	}
+	return ""
+}
+
+// Answers why the URI of an authority link is not a well-formed absolute http(s) URI, or the empty string
+func malformedUri(uri string) string {
+	if uri == "" {
+		return "is empty"
+	}
+	if strings.TrimSpace(uri) != uri || strings.ContainsAny(uri, " \t\n") {
+		return "contains white space"
+	}
+	u, err := url.Parse(uri)
+	switch {
+	case err != nil:
+		return "is not a URI"
+	case u.Scheme != "http" && u.Scheme != "https":
+		return "is not an http or https URI"
+	case u.Host == "":
+		return "has no host"
+	}
+	return ""
+}
+
+// Dereferences authority URIs against a mirror of the authorities (or a stand-in for them), so links are resolved
+// without requesting the authorities themselves.  The URI http://id.loc.gov/authorities/names/n50034947 is requested as
+// <mirror>/id.loc.gov/authorities/names/n50034947.  Each URI is requested once.
+type authorityResolver struct {
+	mirror   string
+	http     *http.Client
+	resolved map[string]error
+}
+
+func newAuthorityResolver(mirror string) *authorityResolver {
+	return &authorityResolver{
+		mirror:   strings.TrimSuffix(mirror, "/"),
+		http:     &http.Client{Timeout: 60 * time.Second},
+		resolved: make(map[string]error),
+	}
+}
+
+// Answers the URL of the authority URI in the mirror
+func (r *authorityResolver) mirrored(uri string) string {
+	u, _ := url.Parse(uri)
+	mirrored := r.mirror + "/" + u.Host + u.EscapedPath()
+	if u.RawQuery != "" {
+		mirrored += "?" + u.RawQuery
+	}
+	return mirrored
+}
+
+// Dereferences the authority URI, answering an error if the mirror does not answer it successfully
+func (r *authorityResolver) resolve(uri string) error {
+	if err, ok := r.resolved[uri]; ok {
+		return err
+	}
+	u := r.mirrored(uri)
+	res, err := r.http.Get(u)
+	if err == nil {
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			err = fmt.Errorf("%d status encountered when requesting %s", res.StatusCode, u)
+		}
+	}
+	r.resolved[uri] = err
+	return err
+}
+
+// Verifies the authority links of every taxonomy term.  Every link must declare a source permitted by the vocabulary
+// of the term (see vocabularyAuthoritySources), and its URI must be a well-formed http(s) URI matching the URIs of that
+// source (see authoritySources).  A link to "other" whose URI is that of a permitted source is a warning.  If resolver
+// is not nil, every well-formed URI must also be answered by it.
+func (c *jsonApiClient) verifyAuthorityLinks(resolver *authorityResolver, report *Report) {
+	crawled := c.crawl(report, "authorities", map[string]bool{"taxonomy_term": true})
+	for _, t := range sortedTypes(crawled) {
+		for i := range crawled[t] {
+			term := &crawled[t][i]
+			links := asList(term.Attributes["field_authority_link"])
+			if len(links) == 0 {
+				continue
+			}
+			report.Checked++
+			for _, elem := range links {
+				link, _ := elem.(map[string]interface{})
+				report.Counts["authority links"]++
+				verifyAuthorityLink(term, scalarString(link["uri"]), scalarString(link["source"]), resolver, report)
+			}
+		}
+	}
+}
+
+// Verifies an authority link of a term
+func verifyAuthorityLink(term *JsonApiResource, uri, source string, resolver *authorityResolver, report *Report) {
+	fail := func(level, format string, args ...interface{}) {
+		report.add(Finding{
+			Level:   level,
+			Check:   "authorities",
+			Subject: term.String(),
+			Field:   "field_authority_link",
+			Actual:  fmt.Sprintf("%s (%s)", uri, source),
+			Message: fmt.Sprintf("the authority link '%s' of '%s' ", uri, term.label()) + fmt.Sprintf(format, args...),
+		})
+	}
+
+	permitted := vocabularyAuthoritySources[term.Type.bundle()]
+	switch {
+	case source == "":
+		fail(LevelError, "has no source")
+	case permitted != nil && !contains(permitted, source):
+		fail(LevelError, "has the source '%s', which the %s vocabulary does not permit: %s", source, term.Type.bundle(), strings.Join(permitted, ", "))
+	}
+
+	if problem := malformedUri(uri); problem != "" {
+		fail(LevelError, "%s", problem)
+		return
+	}
+
+	if s, ok := authoritySources[source]; ok {
+		m := s.pattern.FindStringSubmatch(uri)
+		switch {
+		case m == nil:
+			fail(LevelError, "is not a %s URI, e.g. %s", s.name, s.example)
+		case s.validate != nil:
+			if problem := s.validate(m); problem != "" {
+				fail(LevelError, "is not a %s URI: %s", s.name, problem)
+			}
+		}
+	} else if source == "other" {
+		var keys []string
+		for key := range authoritySources {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if contains(permitted, key) && authoritySources[key].pattern.MatchString(uri) {
+				fail(LevelWarning, "is a %s URI, so its source should be '%s' rather than 'other'", authoritySources[key].name, key)
+			}
+		}
+	}
+
+	if resolver != nil {
+		if err := resolver.resolve(uri); err != nil {
+			fail(LevelError, "does not resolve: %s", err)
+			return
+		}
+		report.Counts["resolved links"]++
+	}
+}
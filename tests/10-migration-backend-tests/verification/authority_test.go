@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// An in-process stand-in for a mirror of the authorities.  The paths it knows are answered with 200, and everything
+// else with 404.
+type fakeMirror struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	known    map[string]bool
+	requests []string
+}
+
+func newFakeMirror(t *testing.T, paths ...string) *fakeMirror {
+	fake := &fakeMirror{known: make(map[string]bool)}
+	for _, p := range paths {
+		fake.known[p] = true
+	}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (fake *fakeMirror) serve(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.requests = append(fake.requests, r.URL.Path)
+	if !fake.known[r.URL.Path] {
+		http.NotFound(w, r)
+	}
+}
+
+// Answers a term of the vocabulary with the authority links, supplied as URI and source pairs
+func fakeAuthorityTerm(vocabulary, id, name string, links ...string) fakeResource {
+	var authority []interface{}
+	for i := 0; i+1 < len(links); i += 2 {
+		authority = append(authority, map[string]interface{}{"uri": links[i], "source": links[i+1], "title": ""})
+	}
+	return fakeTaxonomyTerm(vocabulary, id, name, map[string]interface{}{"field_authority_link": authority})
+}
+
+func Test_AuthoritySources(t *testing.T) {
+	for source, uris := range map[string][]string{
+		"lcnaf":            {"http://id.loc.gov/authorities/names/n50034947", "https://id.loc.gov/authorities/names/no2008094836.html"},
+		"lgcft":            {"http://id.loc.gov/authorities/genreForms/gf2017027249"},
+		"viaf":             {"http://viaf.org/viaf/7407018", "https://www.viaf.org/viaf/7407018/"},
+		"wikidata":         {"https://www.wikidata.org/wiki/Q60809", "http://www.wikidata.org/entity/Q60809"},
+		"fast":             {"http://id.worldcat.org/fast/1204920"},
+		"aat":              {"http://vocab.getty.edu/aat/300054152", "http://vocab.getty.edu/page/aat/300054152"},
+		"geonames":         {"https://www.geonames.org/5509151/nevada.html", "https://sws.geonames.org/5509151/"},
+		"iso639-2b":        {"http://id.loc.gov/vocabulary/iso639-2/eng", "http://id.loc.gov/vocabulary/languages/spa.html"},
+		"orcid":            {"https://orcid.org/0000-0002-1825-0097", "https://orcid.org/0000-0002-1694-233X"},
+		"rightsstatements": {"http://rightsstatements.org/vocab/UND/1.0/"},
+		"dcmi_types":       {"https://www.dublincore.org/specifications/dublin-core/dcmi-terms/dcmitype/StillImage/", "http://purl.org/dc/dcmitype/Text"},
+	} {
+		s := authoritySources[source]
+		for _, uri := range uris {
+			m := s.pattern.FindStringSubmatch(uri)
+			if assert.NotNil(t, m, uri) && s.validate != nil {
+				assert.Equal(t, "", s.validate(m), uri)
+			}
+		}
+	}
+
+	for source, uri := range map[string]string{
+		"lcnaf":     "http://loc.gov",
+		"viaf":      "http://viaf.org/viaf/",
+		"wikidata":  "https://en.wikipedia.org/wiki/Lewis_Hine",
+		"fast":      "https://www.oclc.org/research/areas/data-science/fast.html",
+		"aat":       "http://vocab.getty.edu/ulan/500024301",
+		"geonames":  "https://www.geonames.org/countries/US/united-states.html",
+		"iso639-2b": "http://lc.gov",
+	} {
+		assert.False(t, authoritySources[source].pattern.MatchString(uri), uri)
+	}
+
+	orcid := authoritySources["orcid"]
+	assert.Equal(t, "its check digit is not 7", orcid.validate(orcid.pattern.FindStringSubmatch("https://orcid.org/0000-0002-1825-0098")))
+	iso := authoritySources["iso639-2b"]
+	assert.Equal(t, "its language has the unknown language code 'xyz'", iso.validate(iso.pattern.FindStringSubmatch("http://id.loc.gov/vocabulary/iso639-2/xyz")))
+}
+
+func Test_MalformedUri(t *testing.T) {
+	for uri, expected := range map[string]string{
+		"https://www.wikidata.org/wiki/Q60809": "",
+		"":                                     "is empty",
+		" http://viaf.org/viaf/7407018":        "contains white space",
+		"http://viaf.org/viaf/ 7407018":        "contains white space",
+		"viaf.org/viaf/7407018":                "is not an http or https URI",
+		"ftp://viaf.org/viaf/7407018":          "is not an http or https URI",
+		"http:///viaf/7407018":                 "has no host",
+		"http://[::1":                          "is not a URI",
+	} {
+		assert.Equal(t, expected, malformedUri(uri), uri)
+	}
+}
+
+func Test_VerifyAuthorityLinks(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	fake.add(
+		fakeAuthorityTerm("person", "adams", "Adams, Ansel Easton, 1902-1984",
+			"http://id.loc.gov/authorities/names/n79027202", "lcnaf",
+			"http://viaf.org/viaf/7407018", "other",
+			"https://www.wikidata.org/wiki/Q60809", "other"),
+		fakeAuthorityTerm("person", "hine", "Hine, Lewis Wickes, 1874-1940",
+			"http://loc.gov", "lcnaf",
+			"https://www.wikidata.org/wiki/Q60809", "wikidata"),
+		fakeAuthorityTerm("language", "klingon", "Klingon",
+			"http://id.loc.gov/vocabulary/iso639-2/tlh", "iso639-2b",
+			"http://lc.gov", ""),
+		fakeAuthorityTerm("genre", "photographs", "Photographs", "http://vocab.getty.edu/aat/300046300", "aat"),
+		fakeAuthorityTerm("subject", "moon", "Moon"),
+	)
+
+	report := newReport("authorities", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyAuthorityLinks(nil, report)
+	assert.True(t, report.failed())
+
+	var problems []string
+	for _, f := range report.Findings {
+		problems = append(problems, fmt.Sprintf("%s: %s %s", f.Level, f.Subject, f.Message))
+	}
+	assert.Equal(t, []string{
+		"error: taxonomy_term--language klingon the authority link 'http://lc.gov' of 'Klingon' has no source",
+		"warning: taxonomy_term--person adams the authority link 'http://viaf.org/viaf/7407018' of 'Adams, Ansel Easton, 1902-1984' is a Virtual International Authority File URI, so its source should be 'viaf' rather than 'other'",
+		"error: taxonomy_term--person hine the authority link 'http://loc.gov' of 'Hine, Lewis Wickes, 1874-1940' is not a Library of Congress Name Authority File URI, e.g. http://id.loc.gov/authorities/names/n50034947",
+		"error: taxonomy_term--person hine the authority link 'https://www.wikidata.org/wiki/Q60809' of 'Hine, Lewis Wickes, 1874-1940' has the source 'wikidata', which the person vocabulary does not permit: viaf, lcnaf, ulan, orcid, other",
+	}, problems)
+	assert.Equal(t, 4, report.Checked)
+	assert.Equal(t, 8, report.Counts["authority links"])
+	assert.Equal(t, 0, report.Counts["resolved links"])
+
+	// the links are resolved against the mirror, each once
+	mirror := newFakeMirror(t, "/id.loc.gov/authorities/names/n79027202", "/viaf.org/viaf/7407018", "/www.wikidata.org/wiki/Q60809",
+		"/id.loc.gov/vocabulary/iso639-2/tlh", "/lc.gov", "/loc.gov")
+	report = newReport("authorities", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyAuthorityLinks(newAuthorityResolver(mirror.URL+"/"), report)
+
+	assert.Equal(t, 7, report.Counts["resolved links"])
+	assert.Equal(t, 5, len(report.Findings))
+	assert.Contains(t, report.Findings, Finding{
+		Level:   LevelError,
+		Check:   "authorities",
+		Subject: "taxonomy_term--genre photographs",
+		Field:   "field_authority_link",
+		Actual:  "http://vocab.getty.edu/aat/300046300 (aat)",
+		Message: "the authority link 'http://vocab.getty.edu/aat/300046300' of 'Photographs' does not resolve: 404 status encountered when requesting " +
+			mirror.URL + "/vocab.getty.edu/aat/300046300",
+	})
+	assert.Equal(t, 7, len(mirror.requests))
+}
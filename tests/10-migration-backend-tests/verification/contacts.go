@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+const (
+	// The fields of a collection naming its contact, migrated from the contact_email and contact_name columns
+	contactEmailField = "field_collection_contact_email"
+	contactNameField  = "field_collection_contact_name"
+
+	// The entities migrated by idc_ingest_new_contact_email, which address the messages of a contact form to the
+	// contact email of a collection
+	contactEmailType DrupalType = "contact_email--contact_email"
+)
+
+// The columns of a contact email CSV compared with the attributes of the contact email migrated from it
+var contactEmailColumns = []string{
+	"subject",
+	"append_message",
+	"recipient_type",
+	"recipient_reference",
+	"reply_to_type",
+	"reply_to_email",
+	"reply_to_reference",
+}
+
+// Parses the value of a contact email, answering the address, or an error if the value is not a single bare address
+// as defined by RFC 5322, e.g. "someone@example.org".  A collection has a single contact email, so values holding
+// many addresses are rejected, as are addresses with a display name: the name of the contact is a field of its own.
+func parseContactEmail(value string) (string, error) {
+	if n := len(strings.Split(value, csvValueSeparator)); n > 1 {
+		return "", fmt.Errorf("holds %d addresses, but a collection has a single contact email", n)
+	}
+	addresses, err := mail.ParseAddressList(value)
+	if err != nil {
+		return "", fmt.Errorf("is not a valid RFC 5322 address: %s", err)
+	}
+	if len(addresses) > 1 {
+		return "", fmt.Errorf("holds %d addresses, but a collection has a single contact email", len(addresses))
+	}
+	if addresses[0].Name != "" || strings.ContainsAny(value, "<>") {
+		return "", fmt.Errorf("is not a bare address, expected '%s'", addresses[0].Address)
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// Answers the value of an attribute of a contact email in the form of its CSV column: booleans are "1" or "0"
+func contactEmailValue(res *JsonApiResource, name string) string {
+	if b, ok := res.Attributes[name].(bool); ok {
+		if b {
+			return "1"
+		}
+		return "0"
+	}
+	return scalarString(res.Attributes[name])
+}
+
+// Verifies the contacts of collections, and the contact emails addressing contact forms to them (see
+// `14-migrate-contact-emails`):
+//
+//   - the contact email of every collection must be a single, valid RFC 5322 address
+//   - each row of the supplied CSVs with a contact_email column must have been migrated to the collection with its
+//     title, which must hold its contact_email and contact_name.  The entity:node destination of
+//     idc_ingest_new_collection does not validate the entities it saves, so a malformed address is migrated as is: it
+//     is reported against the row of the CSV it was migrated from
+//   - each row of the supplied CSVs with an email_id column must have been migrated to a contact email with its subject,
+//     contact form and recipients
+//   - if the permissions of `field_collection_contact_email` restrict it, the contact emails of collections must not
+//     be exposed to the anonymous client
+func (c *jsonApiClient) verifyContactEmails(anonymous *jsonApiClient, csvs []*csvTable, report *Report) {
+	t := DrupalType("node--collection_object")
+	collections, err := c.collection(fmt.Sprintf("%s/jsonapi/%s/%s", c.baseUrl, t.entity(), t.bundle()))
+	if err != nil {
+		report.error("contacts", string(t), "unable to retrieve the collections: %s", err)
+		return
+	}
+	for i := range collections {
+		res := &collections[i]
+		report.Checked++
+		email := res.attribute(contactEmailField)
+		if email == "" {
+			if res.attribute(contactNameField) != "" {
+				report.warning("contacts", res.String(), "'%s' names the contact '%s', but has no contact email", res.label(), res.attribute(contactNameField))
+			}
+			continue
+		}
+		report.Counts["contacts"]++
+		if _, err := parseContactEmail(email); err != nil {
+			report.add(Finding{
+				Level:   LevelError,
+				Check:   "contacts",
+				Subject: res.String(),
+				Field:   contactEmailField,
+				Actual:  email,
+				Message: fmt.Sprintf("the contact email of '%s' %s", res.label(), err),
+			})
+		}
+	}
+
+	for _, table := range csvs {
+		switch {
+		case contains(table.columns, "contact_email"):
+			c.verifyCollectionContacts(t, table, report)
+		case contains(table.columns, "email_id"):
+			c.verifyContactEmailEntities(table, report)
+		default:
+			report.warning("contacts", table.path, "the CSV has neither a contact_email nor an email_id column, and was not compared")
+		}
+	}
+
+	restricted, err := c.contactEmailsRestricted()
+	switch {
+	case err != nil:
+		report.warning("contacts", contactEmailField, "unable to determine whether the field is restricted, so its exposure was not checked: %s", err)
+	case restricted:
+		c.verifyContactsNotExposed(anonymous, t, report)
+	}
+}
+
+// Verifies the contacts of the collections migrated from the rows of a collection CSV
+func (c *jsonApiClient) verifyCollectionContacts(t DrupalType, table *csvTable, report *Report) {
+	for _, row := range table.rows {
+		cell := strings.TrimSpace(row["contact_email"])
+		if cell == "" {
+			continue
+		}
+		subject := fmt.Sprintf("%s %s", table.path, rowKey(row))
+		collections, err := c.find(t, "title", row["title"])
+		if err != nil {
+			report.error("contacts", subject, "unable to find the %s '%s': %s", t, row["title"], err)
+			continue
+		}
+		if len(collections) != 1 {
+			report.error("contacts", subject, "%d %s are titled '%s', expected 1", len(collections), t, row["title"])
+			continue
+		}
+		res := &collections[0]
+		actual := res.attribute(contactEmailField)
+
+		expected, invalid := parseContactEmail(cell)
+		if invalid != nil {
+			expected = cell
+			report.Counts["malformed"]++
+			report.add(Finding{
+				Level:   LevelError,
+				Check:   "contacts",
+				Subject: subject,
+				Field:   "contact_email",
+				Actual:  cell,
+				Message: fmt.Sprintf("the contact email of '%s' %s, and is migrated as is", row["title"], invalid),
+			})
+		} else {
+			report.Counts["migrated"]++
+		}
+
+		if actual != expected {
+			report.add(Finding{
+				Level:    LevelError,
+				Check:    "contacts",
+				Subject:  res.String(),
+				Field:    contactEmailField,
+				Expected: expected,
+				Actual:   actual,
+				Message:  fmt.Sprintf("the contact email of '%s' does not match %s", res.label(), table.path),
+			})
+		}
+		if name := strings.TrimSpace(row["contact_name"]); name != "" && name != res.attribute(contactNameField) {
+			report.add(Finding{
+				Level:    LevelError,
+				Check:    "contacts",
+				Subject:  res.String(),
+				Field:    contactNameField,
+				Expected: name,
+				Actual:   res.attribute(contactNameField),
+				Message:  fmt.Sprintf("the contact name of '%s' does not match %s", res.label(), table.path),
+			})
+		}
+	}
+}
+
+// Verifies the contact emails migrated from the rows of a contact email CSV, which are matched by subject
+func (c *jsonApiClient) verifyContactEmailEntities(table *csvTable, report *Report) {
+	emails, err := c.collection(fmt.Sprintf("%s/jsonapi/%s/%s", c.baseUrl, contactEmailType.entity(), contactEmailType.bundle()))
+	if err != nil {
+		report.error("contacts", string(contactEmailType), "unable to retrieve the contact emails: %s", err)
+		return
+	}
+
+	for _, row := range table.rows {
+		subject := fmt.Sprintf("%s email_id %s", table.path, row["email_id"])
+		var res *JsonApiResource
+		for i := range emails {
+			if emails[i].attribute("subject") == row["subject"] {
+				res = &emails[i]
+			}
+		}
+		if res == nil {
+			report.error("contacts", subject, "no %s has the subject '%s'", contactEmailType, row["subject"])
+			continue
+		}
+		report.Counts["contact emails"]++
+
+		var form string
+		for _, target := range res.related("contact_form") {
+			form = scalarString(target.Meta["drupal_internal__target_id"])
+		}
+		if form != row["contact_form"] {
+			report.add(Finding{
+				Level:    LevelError,
+				Check:    "contacts",
+				Subject:  res.String(),
+				Field:    "contact_form",
+				Expected: row["contact_form"],
+				Actual:   form,
+				Message:  fmt.Sprintf("the contact email '%s' is not of the contact form of %s", row["subject"], table.path),
+			})
+		}
+		for _, column := range contactEmailColumns {
+			expected := strings.TrimSpace(row[column])
+			if expected == "" {
+				continue
+			}
+			if actual := contactEmailValue(res, column); actual != expected {
+				report.add(Finding{
+					Level:    LevelError,
+					Check:    "contacts",
+					Subject:  res.String(),
+					Field:    column,
+					Expected: expected,
+					Actual:   actual,
+					Message:  fmt.Sprintf("the %s of the contact email '%s' does not match %s", column, row["subject"], table.path),
+				})
+			}
+		}
+	}
+}
+
+// Answers true if the permissions of field_collection_contact_email, granted by the field_permissions module, do not
+// permit anonymous users to view it: its permission type is private, or it is custom and the anonymous role is not
+// granted the permission to view the field.
+func (c *jsonApiClient) contactEmailsRestricted() (bool, error) {
+	storage, err := c.find("field_storage_config--field_storage_config", "field_name", contactEmailField)
+	if err != nil {
+		return false, err
+	}
+	if len(storage) == 0 {
+		return false, fmt.Errorf("%w: the field storage of %s", ErrNotFound, contactEmailField)
+	}
+
+	settings, _ := storage[0].Attributes["third_party_settings"].(map[string]interface{})
+	permissions, _ := settings["field_permissions"].(map[string]interface{})
+	switch scalarString(permissions["permission_type"]) {
+	case "private":
+		return true, nil
+	case "custom":
+		roles, err := c.find("user_role--user_role", "drupal_internal__id", "anonymous")
+		if err != nil {
+			return false, err
+		}
+		if len(roles) == 0 {
+			return false, errors.New("the anonymous role was not found")
+		}
+		var granted []string
+		for _, p := range asList(roles[0].Attributes["permissions"]) {
+			granted = append(granted, scalarString(p))
+		}
+		return !contains(granted, "view "+contactEmailField), nil
+	default:
+		return false, nil
+	}
+}
+
+// Verifies that no collection the anonymous client can view exposes its contact email
+func (c *jsonApiClient) verifyContactsNotExposed(anonymous *jsonApiClient, t DrupalType, report *Report) {
+	collections, err := anonymous.collection(fmt.Sprintf("%s/jsonapi/%s/%s", anonymous.baseUrl, t.entity(), t.bundle()))
+	if err != nil {
+		report.error("contacts", string(t), "unable to retrieve the collections anonymously: %s", err)
+		return
+	}
+	for i := range collections {
+		res := &collections[i]
+		if email := res.attribute(contactEmailField); email != "" {
+			report.add(Finding{
+				Level:   LevelError,
+				Check:   "contacts",
+				Subject: res.String(),
+				Field:   contactEmailField,
+				Actual:  email,
+				Message: fmt.Sprintf("the contact email of '%s' is exposed to anonymous users, but %s is restricted", res.label(), contactEmailField),
+			})
+			continue
+		}
+		report.Counts["not exposed"]++
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A collection CSV with a valid contact, a malformed contact, and many contacts for a single collection
+const contactsCsv = `node_id,local_id,title,contact_email,contact_name
+,collection-01,Valid Contact,emetsger@gmail.com,Elliot Metsger
+,collection-02,Malformed Contact,emetsger at gmail.com,Elliot Metsger
+,collection-03,Many Contacts,emetsger@gmail.com|someone@example.org,Elliot Metsger|Someone
+,collection-04,No Contact,,
+`
+
+// The contact email CSV of 14-migrate-contact-emails
+const contactEmailsCsv = `email_id,langcode,contact_form,subject,message_value,message_format,append_message,recipient_type,recipient_field,recipient_reference,reply_to_type,reply_to_email,reply_to_field,reply_to_reference,status,created,changed,default_langcode
+1,en,collection_contact,Feedback for [contact_message:field_collection:entity:title]: [contact_message:subject],,,1,reference,,field_collection.node.collection_object.field_collection_contact_email,reference,,,field_collection.node.collection_object.field_collection_contact_email,,,,
+2,en,repository_item_contact,this is a moo,this is a message,,1,reference,,field_collection.node.collection_object.field_collection_contact_email,reference,,,field_collection.node.collection_object.field_collection_contact_email,,,,
+`
+
+const contactReference = "field_collection.node.collection_object.field_collection_contact_email"
+
+func fakeContactCollection(id, title, email, name string) fakeResource {
+	return fakeNode("node--collection_object", id, title, map[string]interface{}{contactEmailField: email, contactNameField: name})
+}
+
+func fakeContactEmail(id, form, subject string) fakeResource {
+	return fakeResource{
+		Type: contactEmailType,
+		Id:   id,
+		Attributes: map[string]interface{}{
+			"subject":             subject,
+			"append_message":      true,
+			"recipient_type":      "reference",
+			"recipient_reference": contactReference,
+			"reply_to_type":       "reference",
+			"reply_to_reference":  contactReference,
+		},
+		Relationships: map[string]interface{}{
+			"contact_form": fakeRelationship{
+				Type: "contact_form--contact_form",
+				Id:   form + "-uuid",
+				Meta: map[string]interface{}{"drupal_internal__target_id": form},
+			},
+		},
+	}
+}
+
+// Answers the field storage of field_collection_contact_email with the supplied permission type
+func fakeContactStorage(permissionType string) fakeResource {
+	return fakeResource{
+		Type: "field_storage_config--field_storage_config",
+		Id:   "contact-email-storage",
+		Attributes: map[string]interface{}{
+			"field_name": contactEmailField,
+			"third_party_settings": map[string]interface{}{
+				"field_permissions": map[string]interface{}{"permission_type": permissionType},
+			},
+		},
+	}
+}
+
+func readContactCsvs(t *testing.T, contents ...string) []*csvTable {
+	var tables []*csvTable
+	for i, content := range contents {
+		table, err := readCsv(fmt.Sprintf("contacts-%d.csv", i+1), strings.NewReader(content), nil)
+		assert.Nil(t, err)
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+func Test_ParseContactEmail(t *testing.T) {
+	for _, valid := range []string{"emetsger@gmail.com", "first.last+tag@example.org", `"quoted local"@example.org`} {
+		address, err := parseContactEmail(valid)
+		assert.Nil(t, err, valid)
+		assert.Equal(t, valid, address)
+	}
+
+	for value, problem := range map[string]string{
+		"emetsger at gmail.com":               "is not a valid RFC 5322 address",
+		"emetsger@":                           "is not a valid RFC 5322 address",
+		"a@example.org|b@example.org":         "holds 2 addresses",
+		"a@example.org, b@example.org":        "holds 2 addresses",
+		"Elliot Metsger <emetsger@gmail.com>": "is not a bare address, expected 'emetsger@gmail.com'",
+	} {
+		_, err := parseContactEmail(value)
+		if assert.NotNil(t, err, value) {
+			assert.Contains(t, err.Error(), problem, value)
+		}
+	}
+}
+
+func Test_VerifyContactEmails_Migrated(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	fake.add(
+		fakeContactCollection("valid", "Valid Contact", "emetsger@gmail.com", "Elliot Metsger"),
+		// the migration does not validate the contact emails, so malformed addresses are migrated as is
+		fakeContactCollection("malformed", "Malformed Contact", "emetsger at gmail.com", "Elliot Metsger"),
+		fakeContactCollection("many", "Many Contacts", "emetsger@gmail.com|someone@example.org", "Elliot Metsger|Someone"),
+		fakeContactCollection("none", "No Contact", "", ""),
+		fakeContactEmail("email-1", "collection_contact", "Feedback for [contact_message:field_collection:entity:title]: [contact_message:subject]"),
+		fakeContactEmail("email-2", "repository_item_contact", "this is a moo"),
+		fakeContactStorage("private"),
+	)
+	anonymous := newFakeJsonApi(t)
+	anonymous.add(fakeContactCollection("valid", "Valid Contact", "", ""))
+
+	report := newReport("contacts", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyContactEmails(newJsonApiClient(anonymous.URL, "", ""),
+		readContactCsvs(t, contactsCsv, contactEmailsCsv), report)
+
+	_, malformed := parseContactEmail("emetsger at gmail.com")
+	assert.Equal(t, []string{
+		"error: node--collection_object malformed [field_collection_contact_email] the contact email of 'Malformed Contact' " + malformed.Error(),
+		"error: node--collection_object many [field_collection_contact_email] the contact email of 'Many Contacts' holds 2 addresses, but a collection has a single contact email",
+		"error: contacts-1.csv local_id collection-02 [contact_email] the contact email of 'Malformed Contact' " + malformed.Error() + ", and is migrated as is",
+		"error: contacts-1.csv local_id collection-03 [contact_email] the contact email of 'Many Contacts' holds 2 addresses, but a collection has a single contact email, and is migrated as is",
+	}, findingLines(report))
+	assert.Equal(t, 4, report.Checked)
+	assert.Equal(t, 1, report.Counts["migrated"])
+	assert.Equal(t, 2, report.Counts["malformed"])
+	assert.Equal(t, 2, report.Counts["contact emails"])
+	assert.Equal(t, 1, report.Counts["not exposed"])
+}
+
+func Test_VerifyContactEmails_Problems(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	email := fakeContactEmail("email-2", "collection_contact", "this is a moo")
+	email.Attributes["append_message"] = false
+	fake.add(
+		fakeContactCollection("valid", "Valid Contact", "elliot@gmail.com", "Elliot"),
+		fakeContactCollection("malformed", "Malformed Contact", "emetsger at gmail.com", "Elliot Metsger"),
+		fakeContactCollection("many", "Many Contacts", "emetsger@gmail.com|someone@example.org", "Elliot Metsger|Someone"),
+		fakeContactCollection("none", "No Contact", "", "Nobody"),
+		email,
+		fakeContactStorage("custom"),
+		fakeResource{
+			Type:       "user_role--user_role",
+			Id:         "anonymous-uuid",
+			Attributes: map[string]interface{}{"drupal_internal__id": "anonymous", "permissions": []string{"access content"}},
+		},
+	)
+	// the anonymous user sees what the administrator sees
+	anonymous := newJsonApiClient(fake.URL, "", "")
+
+	report := newReport("contacts", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyContactEmails(anonymous, readContactCsvs(t, contactsCsv, contactEmailsCsv), report)
+	assert.True(t, report.failed())
+
+	_, malformed := parseContactEmail("emetsger at gmail.com")
+	assert.Equal(t, []string{
+		"error: node--collection_object malformed [field_collection_contact_email] the contact email of 'Malformed Contact' " + malformed.Error(),
+		"error: node--collection_object many [field_collection_contact_email] the contact email of 'Many Contacts' holds 2 addresses, but a collection has a single contact email",
+		"warning: node--collection_object none [] 'No Contact' names the contact 'Nobody', but has no contact email",
+		"error: node--collection_object valid [field_collection_contact_email] the contact email of 'Valid Contact' does not match contacts-1.csv",
+		"error: node--collection_object valid [field_collection_contact_name] the contact name of 'Valid Contact' does not match contacts-1.csv",
+		"error: contacts-1.csv local_id collection-02 [contact_email] the contact email of 'Malformed Contact' " + malformed.Error() + ", and is migrated as is",
+		"error: contacts-1.csv local_id collection-03 [contact_email] the contact email of 'Many Contacts' holds 2 addresses, but a collection has a single contact email, and is migrated as is",
+		"error: contacts-2.csv email_id 1 [] no contact_email--contact_email has the subject 'Feedback for [contact_message:field_collection:entity:title]: [contact_message:subject]'",
+		"error: contact_email--contact_email email-2 [contact_form] the contact email 'this is a moo' is not of the contact form of contacts-2.csv",
+		"error: contact_email--contact_email email-2 [append_message] the append_message of the contact email 'this is a moo' does not match contacts-2.csv",
+		"error: node--collection_object valid [field_collection_contact_email] the contact email of 'Valid Contact' is exposed to anonymous users, but field_collection_contact_email is restricted",
+		"error: node--collection_object malformed [field_collection_contact_email] the contact email of 'Malformed Contact' is exposed to anonymous users, but field_collection_contact_email is restricted",
+		"error: node--collection_object many [field_collection_contact_email] the contact email of 'Many Contacts' is exposed to anonymous users, but field_collection_contact_email is restricted",
+	}, findingLines(report))
+}
+
+func Test_VerifyContactEmails_Public(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	fake.add(fakeContactCollection("valid", "Valid Contact", "emetsger@gmail.com", "Elliot Metsger"), fakeContactStorage("public"))
+
+	report := newReport("contacts", fake.URL)
+	c := newJsonApiClient(fake.URL, "", "")
+	c.verifyContactEmails(c, nil, report)
+
+	assert.Equal(t, 0, len(report.Findings), "unexpected findings: %v", report.Findings)
+	assert.Equal(t, 0, report.Counts["not exposed"])
+
+	// the permissions of the field cannot be read
+	fake.remove("contact-email-storage")
+	report = newReport("contacts", fake.URL)
+	c.verifyContactEmails(c, nil, report)
+	assert.False(t, report.failed())
+	assert.Equal(t, 1, len(report.Findings))
+	assert.Contains(t, report.Findings[0].Message, "its exposure was not checked")
+}
+
+func Test_VerifyContactEmails_Restricted(t *testing.T) {
+	anonymousRole := func(permissions ...string) fakeResource {
+		return fakeResource{
+			Type:       "user_role--user_role",
+			Id:         "anonymous-uuid",
+			Attributes: map[string]interface{}{"drupal_internal__id": "anonymous", "permissions": permissions},
+		}
+	}
+	for _, test := range []struct {
+		permissionType string
+		granted        []string
+		restricted     bool
+	}{
+		{"public", nil, false},
+		{"private", nil, true},
+		{"custom", []string{"access content"}, true},
+		{"custom", []string{"access content", "view " + contactEmailField}, false},
+	} {
+		fake := newFakeJsonApi(t)
+		fake.add(
+			fakeContactCollection("valid", "Valid Contact", "emetsger@gmail.com", "Elliot Metsger"),
+			fakeContactStorage(test.permissionType),
+			anonymousRole(test.granted...),
+		)
+		restricted, err := newJsonApiClient(fake.URL, "", "").contactEmailsRestricted()
+		assert.Nil(t, err, test.permissionType)
+		assert.Equal(t, test.restricted, restricted, "%s %v", test.permissionType, test.granted)
+
+		// the anonymous client is only consulted if the field is restricted; it sees the contact email
+		report := newReport("contacts", fake.URL)
+		c := newJsonApiClient(fake.URL, "", "")
+		c.verifyContactEmails(c, nil, report)
+		if test.restricted {
+			assert.Equal(t, []string{
+				"error: node--collection_object valid [field_collection_contact_email] the contact email of 'Valid Contact' is exposed to anonymous users, but field_collection_contact_email is restricted",
+			}, findingLines(report), test.permissionType)
+		} else {
+			assert.Equal(t, 0, len(report.Findings), test.permissionType)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// The entity types crawled to verify file deletion
+var deletionEntities = map[string]bool{
+	"media": true,
+	"file":  true,
+}
+
+// Verifies the deletion of the media described by a fixture (e.g. `expected/deletion-file.json`), which supplies the
+// `name` of the media and the `uri` of its file.  The media must be gone.  Files are deduplicated by content address,
+// so the fate of the file depends on whether another media still refers to a file with the same uri:
+//
+//   - if one does, the content must remain downloadable, and files with the uri that are no longer referred to are
+//     reported as warnings, since deleting them would delete the content shared with the referenced file
+//   - otherwise, every file with the uri must be gone, and its download URL must answer 404, showing the content was
+//     removed from private storage
+//
+// A fixture may instead declare the file `retained`: a file with the uri must then remain, referred to by no media, and
+// its content must remain downloadable.  Drupal keeps the file of a media deleted by 11-file-deletion-tests, reporting
+// that the user may not delete it, so the removal of a file is verified by the unit tests alone.
+func (c *jsonApiClient) verifyFileDeletion(f *fixture, report *Report) {
+	t := DrupalType(fmt.Sprintf("%s--%s", f.values["type"], f.values["bundle"]))
+	name, _ := f.values["name"].(string)
+	uri, _ := f.values["uri"].(map[string]interface{})
+	value, url := scalarString(uri["value"]), scalarString(uri["url"])
+	retained, _ := f.values["retained"].(bool)
+	if t.entity() != "media" || name == "" || value == "" || url == "" {
+		report.error("deletion", f.path, "fixture must describe a media, with a 'name' and the 'uri' of its file")
+		return
+	}
+	report.Checked++
+
+	// a resource type that is not found has no media at all
+	if media, err := c.find(t, "name", name); err != nil && !errors.Is(err, ErrNotFound) {
+		report.error("deletion", f.path, "unable to find the %s '%s': %s", t, name, err)
+		return
+	} else if len(media) > 0 {
+		report.error("deletion", media[0].String(), "the %s '%s' was not deleted", t, name)
+	}
+
+	idx := newCrawlIndex(c.crawl(report, "deletion", deletionEntities))
+
+	// the files with the uri, and which of them are referred to by a media
+	var sharers []*JsonApiResource
+	for i := range idx.crawled["file--file"] {
+		file := &idx.crawled["file--file"][i]
+		if u, _ := file.Attributes["uri"].(map[string]interface{}); scalarString(u["value"]) == value {
+			sharers = append(sharers, file)
+		}
+	}
+	referrers := make(map[string]*JsonApiResource)
+	for _, mt := range sortedTypes(idx.crawled) {
+		if mt.entity() != "media" {
+			continue
+		}
+		for i := range idx.crawled[mt] {
+			media := &idx.crawled[mt][i]
+			for _, field := range media.relationshipNames() {
+				for _, target := range media.related(field) {
+					for _, file := range sharers {
+						if target.Id == file.Id {
+							referrers[file.Id] = media
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if len(referrers) > 0 {
+		report.Counts["retained"]++
+		// the sharers have the same uri, so their content is downloaded once
+		_, downloadErr := c.download(url, ioutil.Discard)
+		for _, file := range sharers {
+			if media, ok := referrers[file.Id]; ok {
+				if downloadErr != nil {
+					report.error("deletion", file.String(), "the content of '%s' is referred to by '%s', but is not downloadable: %s",
+						file.label(), media.label(), downloadErr)
+				}
+			} else {
+				report.warning("deletion", file.String(), "'%s' is no longer referred to, but shares its content with a file that is: it was retained",
+					file.label())
+			}
+		}
+		return
+	}
+
+	if retained {
+		if len(sharers) == 0 {
+			report.error("deletion", f.path, "the file '%s' of the %s '%s' was expected to be retained, but was deleted", value, t, name)
+			return
+		}
+		report.Counts["retained"]++
+		if _, err := c.download(url, ioutil.Discard); err != nil {
+			report.error("deletion", sharers[0].String(), "'%s' was retained, but its content is not downloadable: %s", sharers[0].label(), err)
+		}
+		return
+	}
+
+	for _, file := range sharers {
+		report.error("deletion", file.String(), "'%s' is no longer referred to by any media, but was not deleted", file.label())
+	}
+	_, err := c.download(url, ioutil.Discard)
+	switch {
+	case err == nil:
+		report.error("deletion", url, "the content of the deleted file is still served")
+	case errors.Is(err, ErrNotFound):
+		report.Counts["removed"]++
+	default:
+		report.error("deletion", url, "the download URL of the deleted file did not answer 404, its content may remain in private storage: %s", err)
+	}
+}
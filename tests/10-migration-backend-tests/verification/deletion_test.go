@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Answers a fixture describing the deleted media and its stored file, whose content is served by the fake
+func deletionFixture(fake *fakeJsonApi, content string) (*fixture, fakeResource) {
+	file := fakeStoredFile(fake, "deleted-file", content, len(content))
+	return &fixture{path: "deletion.json", values: map[string]interface{}{
+		"type":   "media",
+		"bundle": "document",
+		"name":   "Deleted Document",
+		"uri":    file.Attributes["uri"],
+	}}, file
+}
+
+func Test_VerifyFileDeletion_Removed(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateIntegrityFake(fake)
+
+	// the fixture of the file deletion suite is well formed, and its file is gone
+	f, err := readFixture("expected/deletion-file.json")
+	assert.Nil(t, err)
+	uri := f.values["uri"].(map[string]interface{})
+	fake.fail(uri["url"].(string), http.StatusNotFound, "Not Found")
+
+	report := newReport("deletion", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyFileDeletion(f, report)
+
+	assert.Equal(t, 0, len(report.Findings), "unexpected findings: %v", report.Findings)
+	assert.Equal(t, 1, report.Checked)
+	assert.Equal(t, 1, report.Counts["removed"])
+}
+
+func Test_VerifyFileDeletion_Retained(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateIntegrityFake(fake)
+	f, deleted := deletionFixture(fake, "shared content")
+	kept := fakeStoredFile(fake, "kept-file", "shared content", 14)
+	copied := fakeStoredFile(fake, "copied-file", "shared content", 14)
+	fake.add(deleted, kept, copied, fakeDocument("kept-document", "kept-file", 14), fakeDocument("copied-document", "copied-file", 14))
+
+	report := newReport("deletion", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyFileDeletion(f, report)
+
+	assert.False(t, report.failed(), "unexpected findings: %v", report.Findings)
+	assert.Equal(t, 1, len(report.Findings))
+	assert.Equal(t, "file--file deleted-file", report.Findings[0].Subject)
+	assert.Contains(t, report.Findings[0].Message, "it was retained")
+	assert.Equal(t, 1, report.Counts["retained"])
+
+	// the content shared by the referenced files is downloaded once
+	var downloads int
+	for _, uri := range fake.received() {
+		if uri == kept.Attributes["uri"].(map[string]interface{})["url"] {
+			downloads++
+		}
+	}
+	assert.Equal(t, 1, downloads)
+}
+
+func Test_VerifyFileDeletion_NotDeleted(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateIntegrityFake(fake)
+	f, deleted := deletionFixture(fake, "deleted content")
+	other := fakeStoredFile(fake, "other-file", "other content", 13)
+	media := fakeDocument("remaining-document", "other-file", 13)
+	media.Attributes["name"] = "Deleted Document"
+	fake.add(deleted, other, media)
+
+	report := newReport("deletion", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyFileDeletion(f, report)
+	assert.True(t, report.failed())
+
+	var problems []string
+	for _, finding := range report.Findings {
+		problems = append(problems, fmt.Sprintf("%s: %s", finding.Subject, finding.Message))
+	}
+	url := deleted.Attributes["uri"].(map[string]interface{})["url"]
+	assert.Equal(t, []string{
+		"media--document remaining-document: the media--document 'Deleted Document' was not deleted",
+		"file--file deleted-file: 'deleted-file.pdf' is no longer referred to by any media, but was not deleted",
+		fmt.Sprintf("%s: the content of the deleted file is still served", url),
+	}, problems)
+}
+
+func Test_VerifyFileDeletion_Forbidden(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateIntegrityFake(fake)
+	f, deleted := deletionFixture(fake, "deleted content")
+	fake.fail(deleted.Attributes["uri"].(map[string]interface{})["url"].(string), http.StatusForbidden, "Forbidden")
+
+	report := newReport("deletion", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyFileDeletion(f, report)
+
+	assert.True(t, report.failed())
+	assert.Equal(t, 1, len(report.Findings))
+	assert.Contains(t, report.Findings[0].Message, "did not answer 404")
+}
+
+func Test_VerifyFileDeletion_ExpectedRetained(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateIntegrityFake(fake)
+	f, kept := deletionFixture(fake, "kept content")
+	f.values["retained"] = true
+
+	// the file was deleted after all
+	report := newReport("deletion", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyFileDeletion(f, report)
+	assert.True(t, report.failed())
+	assert.Equal(t, 1, len(report.Findings))
+	assert.Contains(t, report.Findings[0].Message, "was expected to be retained, but was deleted")
+
+	fake.add(kept)
+	report = newReport("deletion", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyFileDeletion(f, report)
+	assert.Equal(t, 0, len(report.Findings), "unexpected findings: %v", report.Findings)
+	assert.Equal(t, 1, report.Counts["retained"])
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// The external URIs of the Islandora media use terms
+const (
+	MediaUseOriginalFile   = "http://pcdm.org/use#OriginalFile"
+	MediaUseServiceFile    = "http://pcdm.org/use#ServiceFile"
+	MediaUseThumbnailImage = "http://pcdm.org/use#ThumbnailImage"
+	MediaUseExtractedText  = "http://pcdm.org/use#ExtractedText"
+	MediaUseFits           = "https://projects.iq.harvard.edu/fits"
+)
+
+// A derivative media generated from an Original File
+type derivative struct {
+	// the external URI of the media use of the derivative
+	use string
+	// the resource type of the derivative media
+	bundle DrupalType
+	// the resource type the Original File must be for the derivative to be generated, or empty if any
+	source DrupalType
+}
+
+// Answers a human-readable name of the derivative, e.g. "Service File (media--image)"
+func (d derivative) String() string {
+	names := map[string]string{
+		MediaUseServiceFile:    "Service File",
+		MediaUseThumbnailImage: "Thumbnail Image",
+		MediaUseExtractedText:  "Extracted Text",
+		MediaUseFits:           "FITS",
+	}
+	return fmt.Sprintf("%s (%s)", names[d.use], d.bundle)
+}
+
+// The derivatives generated from every Original File, following `context.context.technical_metadata_on_ingest.yml`
+var originalFileDerivatives = []derivative{
+	{MediaUseFits, "media--fits_technical_metadata", ""},
+}
+
+// The derivatives generated from an Original File, keyed by the external URI of a term (e.g. the model) of the node it
+// is the media of, following `context.context.*_original_file.yml` and the derivative actions they perform
+var modelDerivatives = map[string][]derivative{
+	// Image
+	"http://purl.org/coar/resource_type/c_c513": {
+		{MediaUseServiceFile, "media--image", "media--image"},
+		{MediaUseThumbnailImage, "media--image", "media--image"},
+	},
+	// Audio
+	"http://purl.org/coar/resource_type/c_18cc": {
+		{MediaUseServiceFile, "media--audio", ""},
+	},
+	// Video
+	"http://purl.org/coar/resource_type/c_12ce": {
+		{MediaUseServiceFile, "media--video", ""},
+		{MediaUseThumbnailImage, "media--image", ""},
+	},
+	// Digital Document
+	"https://schema.org/DigitalDocument": {
+		{MediaUseExtractedText, "media--extracted_text", ""},
+		{MediaUseThumbnailImage, "media--image", ""},
+	},
+}
+
+// The term fields of a node consulted for the URIs that select derivatives
+var derivativeTermFields = []string{"field_model", "field_resource_type"}
+
+// The entity types crawled to verify derivatives
+var derivativeEntities = map[string]bool{
+	"media": true,
+}
+
+// A derivative that is expected, but has not yet been found
+type pendingDerivative struct {
+	original   *JsonApiResource
+	node       *JsonApiResource
+	derivative derivative
+	// the error encountered by the last attempt to find the derivative, if any
+	err error
+}
+
+// Answers the external URIs of the terms a resource refers to with the supplied relationship fields
+func (c *jsonApiClient) termUris(res *JsonApiResource, fields ...string) ([]string, error) {
+	var uris []string
+	for _, field := range fields {
+		for _, target := range res.related(field) {
+			if target.Id == missingId {
+				continue
+			}
+			term, err := c.resolve(target.JsonApiData)
+			if err != nil {
+				return nil, err
+			}
+			uri, _ := term.Attributes["field_external_uri"].(map[string]interface{})
+			if v := scalarString(uri["uri"]); v != "" {
+				uris = append(uris, v)
+			}
+		}
+	}
+	return uris, nil
+}
+
+// Verifies that the expected derivatives of every Original File exist: a media of the derivative's type, whose
+// `field_media_use` is the derivative's use, and whose `field_media_of` is the node the Original File is the media of.
+// Derivatives are generated asynchronously, so the media of each node are polled every interval until every expected
+// derivative is found or the timeout elapses.  A timeout of zero checks once.
+func (c *jsonApiClient) verifyDerivatives(report *Report, timeout, interval time.Duration) {
+	idx := newCrawlIndex(c.crawl(report, "derivatives", derivativeEntities))
+
+	var pending []pendingDerivative
+	for _, t := range sortedTypes(idx.crawled) {
+		for i := range idx.crawled[t] {
+			media := &idx.crawled[t][i]
+			uses, err := c.termUris(media, "field_media_use")
+			if err != nil {
+				report.error("derivatives", media.String(), "unable to resolve the media use of '%s': %s", media.label(), err)
+				continue
+			}
+			if !contains(uses, MediaUseOriginalFile) {
+				continue
+			}
+			report.Checked++
+			report.Counts["original files"]++
+
+			for _, target := range media.related("field_media_of") {
+				if target.Id == missingId {
+					continue
+				}
+				node, err := c.resolve(target.JsonApiData)
+				if err != nil {
+					report.error("derivatives", media.String(), "unable to resolve the node '%s' is the media of: %s", media.label(), err)
+					continue
+				}
+				uris, err := c.termUris(node, derivativeTermFields...)
+				if err != nil {
+					report.error("derivatives", media.String(), "unable to resolve the model of %s: %s", node, err)
+					continue
+				}
+
+				expected := append([]derivative{}, originalFileDerivatives...)
+				for _, uri := range uris {
+					expected = append(expected, modelDerivatives[uri]...)
+				}
+				for _, d := range expected {
+					if d.source == "" || d.source == media.Type {
+						pending = append(pending, pendingDerivative{media, node, d, nil})
+					}
+				}
+			}
+		}
+	}
+	report.Counts["expected derivatives"] = len(pending)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var remaining []pendingDerivative
+		for _, p := range pending {
+			// errors may be transient, so the derivative remains pending until the deadline
+			found, err := c.hasDerivative(p.node, p.derivative)
+			if !found {
+				p.err = err
+				remaining = append(remaining, p)
+			}
+		}
+		pending = remaining
+
+		if len(pending) == 0 || !time.Now().Add(interval).Before(deadline) {
+			break
+		}
+		time.Sleep(interval)
+	}
+
+	for _, p := range pending {
+		if p.err != nil {
+			report.error("derivatives", p.original.String(), "unable to find the %s of %s: %s", p.derivative, p.node, p.err)
+			continue
+		}
+		report.add(Finding{
+			Level:    LevelError,
+			Check:    "derivatives",
+			Subject:  p.original.String(),
+			Field:    "field_media_use",
+			Expected: p.derivative.use,
+			Message: fmt.Sprintf("no %s derivative of the Original File '%s' is the media of '%s' after %s", p.derivative,
+				p.original.label(), p.node.label(), timeout),
+		})
+	}
+}
+
+// Answers true if a media of the derivative's type and use is the media of node
+func (c *jsonApiClient) hasDerivative(node *JsonApiResource, d derivative) (bool, error) {
+	candidates, err := c.find(d.bundle, "field_media_of.id", node.Id)
+	if err != nil {
+		return false, err
+	}
+	for i := range candidates {
+		uses, err := c.termUris(&candidates[i], "field_media_use")
+		if err != nil {
+			return false, err
+		}
+		if contains(uses, d.use) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
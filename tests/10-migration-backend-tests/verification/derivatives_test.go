@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Answers a term whose field_external_uri is uri
+func fakeUriTerm(t DrupalType, id, name, uri string) fakeResource {
+	return fakeResource{
+		Type: t,
+		Id:   id,
+		Attributes: map[string]interface{}{
+			"name":               name,
+			"field_external_uri": map[string]interface{}{"uri": uri, "title": nil, "options": []string{}},
+		},
+	}
+}
+
+// Answers a media of the node, with the supplied media use
+func fakeMediaOf(t DrupalType, id, nodeId, useId string) fakeResource {
+	return fakeResource{
+		Type:       t,
+		Id:         id,
+		Attributes: map[string]interface{}{"name": id},
+		Relationships: map[string]interface{}{
+			"field_media_of":  []fakeRelationship{{Type: "node--islandora_object", Id: nodeId}},
+			"field_media_use": []fakeRelationship{{Type: "taxonomy_term--islandora_media_use", Id: useId}},
+		},
+	}
+}
+
+// Populates the fake with an image repository item and its Original File, and the media use terms
+func populateDerivativesFake(fake *fakeJsonApi) {
+	fake.add(
+		fakeUriTerm("taxonomy_term--islandora_media_use", "original", "Original File", MediaUseOriginalFile),
+		fakeUriTerm("taxonomy_term--islandora_media_use", "service", "Service File", MediaUseServiceFile),
+		fakeUriTerm("taxonomy_term--islandora_media_use", "thumbnail", "Thumbnail Image", MediaUseThumbnailImage),
+		fakeUriTerm("taxonomy_term--islandora_media_use", "fits", "FITS File", MediaUseFits),
+		fakeUriTerm("taxonomy_term--islandora_models", modelId, "Image", "http://purl.org/coar/resource_type/c_c513"),
+		fakeResource{
+			Type:       "node--islandora_object",
+			Id:         objectId,
+			Attributes: map[string]interface{}{"title": "Repository Item One"},
+			Relationships: map[string]interface{}{
+				"field_model": fakeRelationship{Type: "taxonomy_term--islandora_models", Id: modelId},
+			},
+		},
+		fakeMediaOf("media--image", "original-image", objectId, "original"),
+		// the FITS of another item, so that the fake knows the FITS media type before any derivative of the image is generated
+		fakeMediaOf("media--fits_technical_metadata", "other-fits", "other-item", "fits"),
+	)
+}
+
+func Test_VerifyDerivatives_Generated(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateDerivativesFake(fake)
+	fake.add(
+		fakeMediaOf("media--image", "service-image", objectId, "service"),
+		fakeMediaOf("media--image", "thumbnail-image", objectId, "thumbnail"),
+	)
+	// the FITS is generated after verification has started
+	time.AfterFunc(30*time.Millisecond, func() {
+		fake.add(fakeMediaOf("media--fits_technical_metadata", "image-fits", objectId, "fits"))
+	})
+
+	report := newReport("derivatives", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyDerivatives(report, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, 0, len(report.Findings), "unexpected findings: %v", report.Findings)
+	assert.Equal(t, 1, report.Checked)
+	assert.Equal(t, 1, report.Counts["original files"])
+	assert.Equal(t, 3, report.Counts["expected derivatives"])
+}
+
+func Test_VerifyDerivatives_Missing(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateDerivativesFake(fake)
+	fake.add(
+		// the thumbnail has the wrong media use, and the FITS is the media of another item
+		fakeMediaOf("media--image", "service-image", objectId, "service"),
+		fakeMediaOf("media--image", "misused-thumbnail", objectId, "service"),
+		fakeMediaOf("media--fits_technical_metadata", "misplaced-fits", "other-item", "fits"),
+	)
+
+	report := newReport("derivatives", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyDerivatives(report, 30*time.Millisecond, 10*time.Millisecond)
+	assert.True(t, report.failed())
+
+	var problems []string
+	for _, f := range report.Findings {
+		assert.Equal(t, "derivatives", f.Check)
+		problems = append(problems, fmt.Sprintf("%s %s: %s", f.Subject, f.Expected, f.Message))
+	}
+	assert.Equal(t, []string{
+		"media--image original-image " + MediaUseFits + ": no FITS (media--fits_technical_metadata) derivative of the Original File 'original-image' is the media of 'Repository Item One' after 30ms",
+		"media--image original-image " + MediaUseThumbnailImage + ": no Thumbnail Image (media--image) derivative of the Original File 'original-image' is the media of 'Repository Item One' after 30ms",
+	}, problems)
+}
+
+func Test_VerifyDerivatives_Unavailable(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateDerivativesFake(fake)
+	fake.add(
+		fakeMediaOf("media--image", "service-image", objectId, "service"),
+		fakeMediaOf("media--image", "thumbnail-image", objectId, "thumbnail"),
+		fakeMediaOf("media--fits_technical_metadata", "image-fits", objectId, "fits"),
+	)
+	// the FITS of the item are found with a filter, rather than by crawling
+	u, err := (&JsonApiUrl{baseUrl: fake.URL, drupalEntity: "media", drupalBundle: "fits_technical_metadata",
+		filter: "field_media_of.id", value: objectId}).compose()
+	assert.Nil(t, err)
+	fits := strings.TrimPrefix(u, fake.URL)
+
+	// the FITS are briefly unavailable
+	fake.fail(fits, http.StatusServiceUnavailable, "Service Unavailable")
+	time.AfterFunc(30*time.Millisecond, func() { fake.recover(fits) })
+	report := newReport("derivatives", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyDerivatives(report, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 0, len(report.Findings), "unexpected findings: %v", report.Findings)
+
+	// the FITS remain unavailable, so the last error is reported
+	fake.fail(fits, http.StatusServiceUnavailable, "Service Unavailable")
+	report = newReport("derivatives", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyDerivatives(report, 30*time.Millisecond, 10*time.Millisecond)
+	assert.True(t, report.failed())
+	if assert.Equal(t, 1, len(report.Findings)) {
+		assert.Contains(t, report.Findings[0].Message, "unable to find the FITS (media--fits_technical_metadata) of")
+		assert.Contains(t, report.Findings[0].Message, "503")
+	}
+}
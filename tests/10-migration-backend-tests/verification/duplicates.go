@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// The vocabularies searched for duplicate terms.  Terms in these vocabularies are looked up by name when migrating
+// repository items (see `13-migration-entity-resolution`), so a lookup that fails to match an existing term creates a
+// duplicate.
+var duplicateVocabularies = []string{
+	"person",
+	"family",
+	"corporate_body",
+	"subject",
+	"genre",
+	"geo_location",
+	"language",
+}
+
+// Suffixes of alternate serializations of an authority record, e.g. "https://id.loc.gov/authorities/names/n50034947.html"
+var authoritySuffixes = []string{".html", ".json", ".jsonld", ".rdf", ".nt", ".ttl"}
+
+// Normalizes a term name for comparison: the name is composed (Unicode NFC) and lower-cased, punctuation is removed,
+// and runs of whitespace are collapsed to a single space.
+//
+//   "  Adams,  Ansel   Easton. " -> "adams ansel easton"
+func normalizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) {
+			return ' '
+		}
+		return unicode.ToLower(r)
+	}, norm.NFC.String(name))
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// Normalizes an authority URI for comparison, answering the empty string if the URI does not identify an entity (e.g.
+// "http://www.google.com").  The scheme, a leading "www.", the fragment, any trailing slash and the suffix of an
+// alternate serialization are removed, and the host is lower-cased:
+//
+//   "https://ID.loc.gov/authorities/names/n50034947.html" -> "id.loc.gov/authorities/names/n50034947"
+func normalizeAuthority(uri string) string {
+	u, err := url.Parse(strings.TrimSpace(norm.NFC.String(uri)))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	path := strings.TrimRight(u.Path, "/")
+	for _, suffix := range authoritySuffixes {
+		path = strings.TrimSuffix(path, suffix)
+	}
+	if path == "" && u.RawQuery == "" {
+		return ""
+	}
+
+	normalized := strings.TrimPrefix(strings.ToLower(u.Host), "www.") + path
+	if u.RawQuery != "" {
+		normalized += "?" + u.RawQuery
+	}
+	return normalized
+}
+
+// Answers the authority URIs of a taxonomy term
+func authorityUris(term *JsonApiResource) []string {
+	var uris []string
+	for _, elem := range asList(term.Attributes["field_authority_link"]) {
+		if obj, ok := elem.(map[string]interface{}); ok {
+			uris = append(uris, scalarString(obj["uri"]))
+		}
+	}
+	return uris
+}
+
+// Reports likely duplicate terms in each of the duplicateVocabularies.  Terms of the same vocabulary with the same
+// normalized name are reported as errors.  Terms (of any of the vocabularies) that share a normalized authority URI are
+// reported as warnings, because distinct entities may legitimately share a link, e.g. two families party to the same
+// feud.
+func (c *jsonApiClient) findDuplicateTerms(report *Report) {
+	byAuthority := make(map[string][]*JsonApiResource)
+	var authorities []string
+
+	for _, vocabulary := range duplicateVocabularies {
+		t := DrupalType("taxonomy_term--" + vocabulary)
+		terms, err := c.collection(fmt.Sprintf("%s/jsonapi/%s/%s", c.baseUrl, t.entity(), t.bundle()))
+		if err != nil {
+			report.error("duplicates", string(t), "unable to list terms: %s", err)
+			continue
+		}
+		report.Checked += len(terms)
+		report.Counts[string(t)] = len(terms)
+
+		byName := make(map[string][]*JsonApiResource)
+		var names []string
+		for i := range terms {
+			term := &terms[i]
+
+			name := normalizeName(term.label())
+			if _, seen := byName[name]; !seen {
+				names = append(names, name)
+			}
+			byName[name] = append(byName[name], term)
+
+			for _, uri := range authorityUris(term) {
+				authority := normalizeAuthority(uri)
+				if authority == "" {
+					continue
+				}
+				if _, seen := byAuthority[authority]; !seen {
+					authorities = append(authorities, authority)
+				}
+				byAuthority[authority] = appendTerm(byAuthority[authority], term)
+			}
+		}
+
+		for _, name := range names {
+			if dups := byName[name]; len(dups) > 1 {
+				report.Counts["duplicate names"]++
+				report.add(Finding{
+					Level:   LevelError,
+					Check:   "duplicates",
+					Subject: string(t),
+					Field:   "name",
+					Actual:  describeTerms(dups),
+					Message: fmt.Sprintf("%d terms are named '%s' once normalized", len(dups), name),
+				})
+			}
+		}
+	}
+
+	sort.Strings(authorities)
+	for _, authority := range authorities {
+		if dups := byAuthority[authority]; len(dups) > 1 {
+			report.Counts["shared authority links"]++
+			report.add(Finding{
+				Level:   LevelWarning,
+				Check:   "duplicates",
+				Subject: authority,
+				Field:   "field_authority_link",
+				Actual:  describeTerms(dups),
+				Message: fmt.Sprintf("%d terms share an authority link", len(dups)),
+			})
+		}
+	}
+}
+
+// Appends the term to terms, unless it is already present (a term may link to the same authority more than once)
+func appendTerm(terms []*JsonApiResource, term *JsonApiResource) []*JsonApiResource {
+	for _, t := range terms {
+		if t.Id == term.Id {
+			return terms
+		}
+	}
+	return append(terms, term)
+}
+
+// Describes terms for a finding, e.g. "taxonomy_term--person 0e3c... 'Adams, Ansel' | taxonomy_term--person 5b3e... 'adams ansel'"
+func describeTerms(terms []*JsonApiResource) string {
+	s := make([]string, len(terms))
+	for i, term := range terms {
+		s[i] = fmt.Sprintf("%s '%s'", term, term.label())
+	}
+	return strings.Join(s, " | ")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Answers a taxonomy term with the supplied name and authority links
+func fakeTerm(vocabulary, id, name string, authorities ...string) fakeResource {
+	var links []map[string]interface{}
+	for _, uri := range authorities {
+		links = append(links, map[string]interface{}{"uri": uri, "title": nil, "source": "other"})
+	}
+	return fakeTaxonomyTerm(vocabulary, id, name, map[string]interface{}{"field_authority_link": links})
+}
+
+func Test_NormalizeName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"Adams, Ansel Easton, 1902-1984": "adams ansel easton 1902 1984",
+		"  Adams,  Ansel   Easton. ":     "adams ansel easton",
+		"ADAMS ANSEL EASTON":             "adams ansel easton",
+		"Analog\tPhotography\n":          "analog photography",
+		// composed and decomposed forms of e with a diaeresis
+		"Bront\u00eb, Charlotte":  "bront\u00eb charlotte",
+		"Bronte\u0308, Charlotte": "bront\u00eb charlotte",
+		// an en dash, curly quotes and guillemets
+		"Hatfield\u2013McCoy feud":             "hatfield mccoy feud",
+		"\u201cQuoted\u201d \u00abtitle\u00bb": "quoted title",
+	} {
+		assert.Equal(t, expected, normalizeName(name), "normalizing %q", name)
+	}
+}
+
+func Test_NormalizeAuthority(t *testing.T) {
+	for uri, expected := range map[string]string{
+		"https://id.loc.gov/authorities/names/n50034947.html": "id.loc.gov/authorities/names/n50034947",
+		"http://ID.LOC.GOV/authorities/names/n50034947":       "id.loc.gov/authorities/names/n50034947",
+		"http://id.loc.gov/authorities/names/n50034947.json":  "id.loc.gov/authorities/names/n50034947",
+		"https://www.wikidata.org/wiki/Q60809":                "wikidata.org/wiki/Q60809",
+		"http://wikidata.org/wiki/Q60809#sitelinks":           "wikidata.org/wiki/Q60809",
+		"http://viaf.org/viaf/24609378/":                      "viaf.org/viaf/24609378",
+		"http://www.google.com?q=Analog%20Photography":        "google.com?q=Analog%20Photography",
+		// links that do not identify an entity are ignored
+		"http://www.google.com": "",
+		"http://loc.gov/":       "",
+		"not a uri":             "",
+	} {
+		assert.Equal(t, expected, normalizeAuthority(uri), "normalizing %q", uri)
+	}
+}
+
+func Test_FindDuplicateTerms(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	fake.add(
+		fakeTerm("person", "p1", "Adams, Ansel Easton, 1902-1984", "https://www.wikidata.org/wiki/Q60809"),
+		fakeTerm("person", "p2", "adams ansel easton 1902-1984"),
+		fakeTerm("person", "p3", "Weston, Edward, 1886-1958", "https://www.wikidata.org/wiki/Q346988"),
+		fakeTerm("person", "p4", "Adams, Islandora Object Ansel Easton, 1902-1984", "http://wikidata.org/wiki/Q60809/"),
+		fakeTerm("family", "f1", "Hatfields", "https://en.wikipedia.org/wiki/Hatfield%E2%80%93McCoy_feud", "http://www.google.com"),
+		fakeTerm("family", "f2", "McCoy", "https://en.wikipedia.org/wiki/Hatfield%E2%80%93McCoy_feud", "http://www.google.com"),
+		// the same name in different vocabularies is not a duplicate
+		fakeTerm("subject", "s1", "McCoy"),
+		fakeTerm("subject", "s2", "Analog Photography"),
+		fakeTerm("subject", "s3", "Analog  Photography."),
+		fakeTerm("language", "l1", "English"),
+	)
+
+	for _, vocabulary := range []string{"corporate_body", "genre", "geo_location"} {
+		fake.respond("/jsonapi/taxonomy_term/"+vocabulary, http.StatusOK, `{"data": []}`)
+	}
+
+	report := newReport("duplicates", fake.URL)
+	newJsonApiClient(fake.URL, "", "").findDuplicateTerms(report)
+
+	assert.Equal(t, 10, report.Checked)
+	assert.Equal(t, 2, report.Counts["duplicate names"])
+	assert.Equal(t, 2, report.Counts["shared authority links"])
+
+	assert.Equal(t, []Finding{
+		{
+			Level:   LevelError,
+			Check:   "duplicates",
+			Subject: "taxonomy_term--person",
+			Field:   "name",
+			Actual:  "taxonomy_term--person p1 'Adams, Ansel Easton, 1902-1984' | taxonomy_term--person p2 'adams ansel easton 1902-1984'",
+			Message: "2 terms are named 'adams ansel easton 1902 1984' once normalized",
+		},
+		{
+			Level:   LevelError,
+			Check:   "duplicates",
+			Subject: "taxonomy_term--subject",
+			Field:   "name",
+			Actual:  "taxonomy_term--subject s2 'Analog Photography' | taxonomy_term--subject s3 'Analog  Photography.'",
+			Message: "2 terms are named 'analog photography' once normalized",
+		},
+		{
+			Level:   LevelWarning,
+			Check:   "duplicates",
+			Subject: "en.wikipedia.org/wiki/Hatfield\u2013McCoy_feud",
+			Field:   "field_authority_link",
+			Actual:  "taxonomy_term--family f1 'Hatfields' | taxonomy_term--family f2 'McCoy'",
+			Message: "2 terms share an authority link",
+		},
+		{
+			Level:   LevelWarning,
+			Check:   "duplicates",
+			Subject: "wikidata.org/wiki/Q60809",
+			Field:   "field_authority_link",
+			Actual:  "taxonomy_term--person p1 'Adams, Ansel Easton, 1902-1984' | taxonomy_term--person p4 'Adams, Islandora Object Ansel Easton, 1902-1984'",
+			Message: "2 terms share an authority link",
+		},
+	}, report.Findings)
+}
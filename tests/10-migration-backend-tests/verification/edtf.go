@@ -0,0 +1,710 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A day of the proleptic Gregorian calendar.  Years may be negative, or far beyond four digits.
+type edtfDay struct {
+	year       int64
+	month, day int
+}
+
+// The earliest and latest days of an unbounded (open or unknown) interval
+var (
+	firstDay = edtfDay{math.MinInt64, 1, 1}
+	lastDay  = edtfDay{math.MaxInt64, 12, 31}
+)
+
+// Answers true if the day is before the other
+func (d edtfDay) before(other edtfDay) bool {
+	if d.year != other.year {
+		return d.year < other.year
+	}
+	if d.month != other.month {
+		return d.month < other.month
+	}
+	return d.day < other.day
+}
+
+func (d edtfDay) String() string {
+	if d.year < 0 {
+		return fmt.Sprintf("-%04d-%02d-%02d", -d.year, d.month, d.day)
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", d.year, d.month, d.day)
+}
+
+// Answers the greater of a and b
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Answers true if the year is a leap year
+func isLeapYear(year int64) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
+
+// Answers the number of days in the month of the year
+func daysInMonth(year int64, month int) int {
+	switch month {
+	case 2:
+		if isLeapYear(year) {
+			return 29
+		}
+		return 28
+	case 4, 6, 9, 11:
+		return 30
+	}
+	return 31
+}
+
+// The qualification of a component of a date: uncertain ("?"), approximate ("~"), or both ("%")
+type edtfQualifier uint8
+
+const (
+	edtfUncertain edtfQualifier = 1 << iota
+	edtfApproximate
+)
+
+var edtfQualifiers = map[byte]edtfQualifier{'?': edtfUncertain, '~': edtfApproximate, '%': edtfUncertain | edtfApproximate}
+
+func (q edtfQualifier) String() string {
+	return [...]string{"", "?", "~", "%"}[q]
+}
+
+// The first month and the number of months of each EDTF season (sub-year grouping) code.  21-24 are the seasons of
+// level 1; 25-32 the hemispheric seasons, 33-36 quarters, 37-39 quadrimesters and 40-41 semestrals of level 2.
+var edtfSeasons = map[int][2]int{
+	21: {3, 3}, 22: {6, 3}, 23: {9, 3}, 24: {12, 3},
+	25: {3, 3}, 26: {6, 3}, 27: {9, 3}, 28: {12, 3},
+	29: {9, 3}, 30: {12, 3}, 31: {3, 3}, 32: {6, 3},
+	33: {1, 3}, 34: {4, 3}, 35: {7, 3}, 36: {10, 3},
+	37: {1, 4}, 38: {5, 4}, 39: {9, 4},
+	40: {1, 6}, 41: {7, 6},
+}
+
+// A single EDTF date, e.g. "1941-11", "2004-06~-11", "156X-12-25", "Y-17E7" or "1985-04-12T23:20:30Z"
+type edtfDate struct {
+	// the first and last days the date may denote
+	earliest, latest edtfDay
+	// the season code, if the date is a season
+	season int
+	// the instant of a date and time, in UTC if it has a time zone
+	instant string
+	// the qualification of the year, month and day
+	qualifiers [3]edtfQualifier
+	level      int
+}
+
+// Answers a form of the date that is the same for every date with the same meaning, e.g. "1985", "1985-XX" and
+// "1985-XX-XX" all denote some day of 1985, and "2004?-?06" and "2004-06?" an uncertain year and month
+func (d *edtfDate) canonical() string {
+	if d.instant != "" {
+		return d.instant
+	}
+	var s string
+	switch {
+	case d.season != 0:
+		s = fmt.Sprintf("%d-%d", d.earliest.year, d.season)
+	case d.earliest == d.latest:
+		s = d.earliest.String()
+	default:
+		s = d.earliest.String() + " to " + d.latest.String()
+	}
+	for i, component := range []string{"year", "month", "day"} {
+		if d.qualifiers[i] != 0 {
+			s += " " + component + d.qualifiers[i].String()
+		}
+	}
+	return s
+}
+
+var edtfDateTime = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})T(\d{2}:\d{2}:\d{2})(Z|[+-]\d{2}(:\d{2})?)?$`)
+
+// Parses a single EDTF date
+func parseEdtfDate(s string) (*edtfDate, error) {
+	if strings.Contains(s, "T") {
+		return parseEdtfDateTime(s)
+	}
+
+	p := &edtfDateParser{s: s}
+	d := &edtfDate{}
+
+	// each component may be qualified on its left, which qualifies it alone, or on its right, which qualifies it and
+	// the components before it
+	type component struct {
+		digits      string
+		left, right edtfQualifier
+	}
+	var components []component
+	for len(components) < 3 {
+		var c component
+		c.left = p.qualifier()
+		if len(components) == 0 {
+			year, err := p.year(d)
+			if err != nil {
+				return nil, err
+			}
+			c.digits = year
+		} else {
+			c.digits = p.take(2)
+			if len(c.digits) != 2 || strings.Trim(c.digits, "0123456789X") != "" {
+				return nil, fmt.Errorf("the %s '%s' is not two digits", []string{"", "month", "day"}[len(components)], c.digits)
+			}
+		}
+		c.right = p.qualifier()
+		components = append(components, c)
+		if !p.consume('-') {
+			break
+		}
+	}
+	if p.rest() != "" {
+		return nil, fmt.Errorf("'%s' is unexpected after the date", p.rest())
+	}
+
+	for i, c := range components {
+		d.qualifiers[i] |= c.left
+		if c.left != 0 {
+			d.level = 2
+		}
+		for j := 0; j <= i; j++ {
+			d.qualifiers[j] |= c.right
+		}
+		if c.right != 0 {
+			d.level = maxInt(d.level, 1)
+			if i != len(components)-1 {
+				d.level = 2
+			}
+		}
+	}
+
+	// unspecified digits are level 1 if they are the last digits of the year, or the whole month or day of an
+	// otherwise specified date, and level 2 anywhere else
+	year := components[0].digits
+	for i, c := range components {
+		unspecified := strings.Count(c.digits, "X")
+		switch {
+		case unspecified == 0:
+		case i == 0 && len(components) == 1 && unspecified <= 2 && strings.HasSuffix(year, strings.Repeat("X", unspecified)):
+			d.level = maxInt(d.level, 1)
+		case i > 0 && c.digits == "XX" && !strings.Contains(year, "X") && (i == 2 || len(components) == 2 || components[2].digits == "XX"):
+			d.level = maxInt(d.level, 1)
+		default:
+			d.level = 2
+		}
+	}
+
+	// the earliest and latest year were bounded by p.year
+	if len(components) == 1 {
+		d.earliest.month, d.earliest.day = 1, 1
+		d.latest.month, d.latest.day = 12, 31
+		return d, nil
+	}
+
+	month := components[1].digits
+	if n, err := strconv.Atoi(month); err == nil && n > 12 {
+		season, ok := edtfSeasons[n]
+		if !ok {
+			return nil, fmt.Errorf("the month %s is not a month or a season", month)
+		}
+		if len(components) > 2 {
+			return nil, fmt.Errorf("the season %s has no days", month)
+		}
+		if d.earliest.year != d.latest.year {
+			return nil, fmt.Errorf("the season %s of an unspecified year is not supported", month)
+		}
+		d.season = n
+		d.level = maxInt(d.level, 1)
+		if n > 24 {
+			d.level = 2
+		}
+		last := season[0] + season[1] - 1
+		d.earliest.month, d.earliest.day = season[0], 1
+		if last > 12 {
+			d.latest.year++
+			last -= 12
+		}
+		d.latest.month, d.latest.day = last, daysInMonth(d.latest.year, last)
+		return d, nil
+	}
+	firstMonth, lastMonth, err := edtfRange(month, 1, 12)
+	if err != nil {
+		return nil, fmt.Errorf("the month %s is not valid", month)
+	}
+	d.earliest.month, d.earliest.day = firstMonth, 1
+	d.latest.month, d.latest.day = lastMonth, daysInMonth(d.latest.year, lastMonth)
+	if len(components) == 2 {
+		return d, nil
+	}
+
+	// a day is valid if it is in any of the months (and years) the date may denote
+	day := components[2].digits
+	leapYear := d.latest.year
+	if d.earliest.year != d.latest.year {
+		leapYear = 2000
+	}
+	most := 0
+	for m := firstMonth; m <= lastMonth; m++ {
+		most = maxInt(most, daysInMonth(leapYear, m))
+	}
+	first, last, err := edtfRange(day, 1, most)
+	switch {
+	case err != nil && firstMonth == lastMonth && d.earliest.year == d.latest.year:
+		return nil, fmt.Errorf("%s %d has no day %s", time.Month(firstMonth), d.earliest.year, day)
+	case err != nil:
+		return nil, fmt.Errorf("the day %s is not valid", day)
+	}
+	d.earliest.day = first
+	if last < d.latest.day {
+		d.latest.day = last
+	}
+	return d, nil
+}
+
+// Parses an EDTF (and ISO 8601) date and time.  The time may have a time zone, in which case the date time is
+// normalized to UTC.
+func parseEdtfDateTime(s string) (*edtfDate, error) {
+	m := edtfDateTime.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("the date and time is not of the form YYYY-MM-DDThh:mm:ss")
+	}
+	zone := m[3]
+	if len(zone) == 3 {
+		zone += ":00"
+	}
+	layout, value := "2006-01-02T15:04:05", m[1]+"T"+m[2]
+	if zone != "" {
+		layout, value = time.RFC3339, value+zone
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return nil, fmt.Errorf("the date and time is not valid")
+	}
+	d := &edtfDate{instant: t.Format(layout)}
+	if zone != "" {
+		d.instant = t.UTC().Format(layout)
+	}
+	d.earliest = edtfDay{int64(t.Year()), int(t.Month()), t.Day()}
+	d.latest = d.earliest
+	return d, nil
+}
+
+// Answers the first and last of the values from min to max matching the digits, some of which may be unspecified
+// ("X"), e.g. "1X" matches 10-12 of the months
+func edtfRange(digits string, min, max int) (int, int, error) {
+	first, last := -1, -1
+	for v := min; v <= max; v++ {
+		candidate := fmt.Sprintf("%0*d", len(digits), v)
+		matched := true
+		for i := range digits {
+			if digits[i] != 'X' && digits[i] != candidate[i] {
+				matched = false
+			}
+		}
+		if matched {
+			if first < 0 {
+				first = v
+			}
+			last = v
+		}
+	}
+	if first < 0 {
+		return 0, 0, fmt.Errorf("'%s' is not from %d to %d", digits, min, max)
+	}
+	return first, last, nil
+}
+
+// Reads the components of an EDTF date
+type edtfDateParser struct {
+	s   string
+	pos int
+}
+
+func (p *edtfDateParser) rest() string {
+	return p.s[p.pos:]
+}
+
+// Answers the next n characters, or as many as remain
+func (p *edtfDateParser) take(n int) string {
+	end := p.pos + n
+	if end > len(p.s) {
+		end = len(p.s)
+	}
+	taken := p.s[p.pos:end]
+	p.pos = end
+	return taken
+}
+
+// Answers the digits from the current position
+func (p *edtfDateParser) digits() string {
+	start := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// Consumes the character if it is next
+func (p *edtfDateParser) consume(ch byte) bool {
+	if p.pos < len(p.s) && p.s[p.pos] == ch {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// Consumes a qualifier if one is next
+func (p *edtfDateParser) qualifier() edtfQualifier {
+	if p.pos < len(p.s) {
+		if q, ok := edtfQualifiers[p.s[p.pos]]; ok {
+			p.pos++
+			return q
+		}
+	}
+	return 0
+}
+
+// Reads the year of the date, bounding its earliest and latest year.  A year is four digits, some of which may be
+// unspecified, or "Y" and more than four digits (level 1), or "Y" and digits with an exponent (level 2).  Any of them
+// may be negative (level 1), and be followed by a number of significant digits (level 2), e.g. "1950S2" is some year
+// from 1900 to 1999.
+func (p *edtfDateParser) year(d *edtfDate) (string, error) {
+	start := p.pos
+	var first, last int64
+
+	if p.consume('Y') {
+		negative := p.consume('-')
+		digits := p.digits()
+		if digits == "" {
+			return "", fmt.Errorf("the year '%s' has no digits", p.s[start:p.pos])
+		}
+		d.level = 1
+		if p.consume('E') {
+			exponent := p.digits()
+			e, err := strconv.Atoi(exponent)
+			if err != nil || e == 0 {
+				return "", fmt.Errorf("the year '%s' has no exponent", p.s[start:p.pos])
+			}
+			digits += strings.Repeat("0", e)
+			d.level = 2
+		} else if len(digits) <= 4 {
+			return "", fmt.Errorf("the year '%s' has no more than four digits, so is written without the 'Y'", p.s[start:p.pos])
+		}
+		year, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("the year '%s' is too large", p.s[start:p.pos])
+		}
+		if negative {
+			year = -year
+		}
+		first, last = year, year
+	} else {
+		negative := p.consume('-')
+		digits := p.take(4)
+		if len(digits) != 4 || strings.Trim(digits, "0123456789X") != "" {
+			return "", fmt.Errorf("the year '%s' is not four digits", p.s[start:p.pos])
+		}
+		first, _ = strconv.ParseInt(strings.ReplaceAll(digits, "X", "0"), 10, 64)
+		last, _ = strconv.ParseInt(strings.ReplaceAll(digits, "X", "9"), 10, 64)
+		if negative {
+			first, last = -last, -first
+			d.level = 1
+		}
+	}
+
+	if p.consume('S') {
+		significant, err := strconv.Atoi(p.digits())
+		year := first
+		if year < 0 {
+			year = -year
+		}
+		n := len(strconv.FormatInt(year, 10))
+		if err != nil || significant == 0 || significant > n || first != last {
+			return "", fmt.Errorf("the year '%s' does not have that many significant digits", p.s[start:p.pos])
+		}
+		unit := int64(math.Pow10(n - significant))
+		lo := year / unit * unit
+		if first < 0 {
+			first, last = -(lo + unit - 1), -lo
+		} else {
+			first, last = lo, lo+unit-1
+		}
+		d.level = 2
+	}
+
+	d.earliest.year, d.latest.year = first, last
+	return p.s[start:p.pos], nil
+}
+
+// An EDTF value: a date, an interval of dates, or a set of dates
+type edtfValue struct {
+	// the value as written
+	text  string
+	level int
+	// the form of the value that is the same for every value with the same meaning
+	canonical string
+	// the first and last days the value may denote, or firstDay and lastDay if the value is unbounded
+	earliest, latest edtfDay
+}
+
+// Parses an EDTF value of levels 0 to 2 (https://www.loc.gov/standards/datetime/), answering an error describing why
+// the value is not valid EDTF.  Besides its syntax, the months and days of dates must exist, and intervals and ranges
+// must not end before they begin.
+func parseEdtf(s string) (*edtfValue, error) {
+	if s == "" {
+		return nil, fmt.Errorf("the date is empty")
+	}
+	v := &edtfValue{text: s}
+
+	if open, last := s[0], s[len(s)-1]; open == '[' || open == '{' {
+		if last != map[byte]byte{'[': ']', '{': '}'}[open] {
+			return nil, fmt.Errorf("the set is not closed by '%c'", map[byte]byte{'[': ']', '{': '}'}[open])
+		}
+		return v, v.parseSet(s[1:len(s)-1], open == '[')
+	}
+
+	if i := strings.Index(s, "/"); i >= 0 {
+		return v, v.parseInterval(s[:i], s[i+1:])
+	}
+
+	d, err := parseEdtfDate(s)
+	if err != nil {
+		return nil, err
+	}
+	v.level, v.canonical, v.earliest, v.latest = d.level, d.canonical(), d.earliest, d.latest
+	return v, nil
+}
+
+// Parses the start and end of an interval.  Either may be unknown (empty) or open (".."), but not both.
+func (v *edtfValue) parseInterval(start, end string) error {
+	if strings.Contains(end, "/") {
+		return fmt.Errorf("the interval has more than two dates")
+	}
+	if start == end && (start == "" || start == "..") {
+		return fmt.Errorf("the interval has neither a start nor an end")
+	}
+
+	var ends [2]*edtfDate
+	var canonical [2]string
+	for i, s := range []string{start, end} {
+		switch s {
+		case "":
+			canonical[i] = "unknown"
+			v.level = maxInt(v.level, 1)
+		case "..":
+			canonical[i] = "open"
+			v.level = maxInt(v.level, 1)
+		default:
+			d, err := parseEdtfDate(s)
+			if err != nil {
+				return err
+			}
+			if d.instant != "" {
+				return fmt.Errorf("the interval has the date and time '%s' rather than a date", s)
+			}
+			ends[i], canonical[i] = d, d.canonical()
+			v.level = maxInt(v.level, d.level)
+		}
+	}
+
+	v.earliest, v.latest = firstDay, lastDay
+	if ends[0] != nil {
+		v.earliest = ends[0].earliest
+	}
+	if ends[1] != nil {
+		v.latest = ends[1].latest
+	}
+	if ends[0] != nil && ends[1] != nil && ends[1].latest.before(ends[0].earliest) {
+		return fmt.Errorf("the interval ends before it begins")
+	}
+	v.canonical = canonical[0] + "/" + canonical[1]
+	return nil
+}
+
+// Parses the members of a set of dates, of which one (oneOf) or all are meant.  Members are dates or ranges of dates
+// ("1760..1770"), and the first may have an open start and the last an open end.
+func (v *edtfValue) parseSet(members string, oneOf bool) error {
+	v.level = 2
+	v.earliest, v.latest = lastDay, firstDay
+	if strings.TrimSpace(members) == "" {
+		return fmt.Errorf("the set is empty")
+	}
+
+	var canonical []string
+	parts := strings.Split(members, ",")
+	for i, member := range parts {
+		member = strings.TrimSpace(member)
+		var bounds []string
+		if j := strings.Index(member, ".."); j >= 0 {
+			bounds = []string{member[:j], member[j+2:]}
+		} else {
+			bounds = []string{member}
+		}
+
+		var dates []*edtfDate
+		var forms []string
+		for k, b := range bounds {
+			if b == "" {
+				if len(bounds) == 1 || k == 0 && i != 0 || k == 1 && i != len(parts)-1 || bounds[1-k] == "" {
+					return fmt.Errorf("the set member '%s' is not a date or a range of dates", member)
+				}
+				forms = append(forms, "open")
+				if k == 0 {
+					v.earliest = firstDay
+				} else {
+					v.latest = lastDay
+				}
+				continue
+			}
+			d, err := parseEdtfDate(b)
+			if err != nil {
+				return err
+			}
+			dates = append(dates, d)
+			forms = append(forms, d.canonical())
+			if d.earliest.before(v.earliest) {
+				v.earliest = d.earliest
+			}
+			if v.latest.before(d.latest) {
+				v.latest = d.latest
+			}
+		}
+		if len(dates) == 2 && dates[1].latest.before(dates[0].earliest) {
+			return fmt.Errorf("the range '%s' ends before it begins", member)
+		}
+		canonical = append(canonical, strings.Join(forms, " .. "))
+	}
+
+	sort.Strings(canonical)
+	unique := canonical[:0]
+	for i, c := range canonical {
+		if i == 0 || c != canonical[i-1] {
+			unique = append(unique, c)
+		}
+	}
+	if oneOf {
+		v.canonical = "one of [" + strings.Join(unique, ", ") + "]"
+	} else {
+		v.canonical = "all of {" + strings.Join(unique, ", ") + "}"
+	}
+	return nil
+}
+
+// Answers a form of the value that is the same for every value with the same meaning, or the value itself if it is
+// not valid EDTF
+func canonicalEdtf(s string) string {
+	v, err := parseEdtf(s)
+	if err != nil {
+		return s
+	}
+	return v.canonical
+}
+
+// Common ways of writing dates that are not EDTF, with the EDTF they are meant as
+var edtfHints = []struct {
+	pattern *regexp.Regexp
+	hint    string
+}{
+	{regexp.MustCompile(`^(\d{4}) ?- ?(\d{4})$`), "a span of years is written '$1/$2'"},
+	{regexp.MustCompile(`^(?i)(?:ca?\.?|circa) ?(\d{4})$`), "an approximate year is written '$1~'"},
+	{regexp.MustCompile(`^(\d{3})0s$`), "a decade is written '${1}X'"},
+	{regexp.MustCompile(`^(\d{1,2})/(\d{1,2})/(\d{4})$`), "a day is written year first, e.g. '$3-MM-DD'"},
+}
+
+// Answers how a value that is not EDTF is written in EDTF, if it is written in a common way, e.g. "1902-1984" is
+// written "1902/1984"
+func edtfHint(s string) string {
+	for _, h := range edtfHints {
+		if h.pattern.MatchString(s) {
+			return h.pattern.ReplaceAllString(s, h.hint)
+		}
+	}
+	return ""
+}
+
+// Answers the EDTF date fields of the resource type, in alphabetical order
+func dateFields(t DrupalType) []string {
+	var fields []string
+	for _, mapping := range fixtureFields[t] {
+		if mapping.kind == dateField && !contains(fields, mapping.field) {
+			fields = append(fields, mapping.field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// A lifespan at the end of the name of a person, e.g. "Adams, Ansel Easton, 1902-1984"
+var nameLifespan = regexp.MustCompile(`, (\d{4})-(\d{4})$`)
+
+// Verifies the date fields of every node and taxonomy term.  Every value of a date field (e.g. field_date_created)
+// must be valid EDTF of levels 0 to 2 (see parseEdtf); values written in a common way that is not EDTF, like the
+// lifespan "1902-1984", are reported with the EDTF they are meant as.  The field_date of a term whose name ends with a
+// lifespan must have a date from the year of its birth to the year of its death.
+func (c *jsonApiClient) verifyDates(report *Report) {
+	crawled := c.crawl(report, "dates", map[string]bool{"node": true, "taxonomy_term": true})
+	for _, t := range sortedTypes(crawled) {
+		fields := dateFields(t)
+		if len(fields) == 0 {
+			continue
+		}
+		for i := range crawled[t] {
+			res := &crawled[t][i]
+			report.Checked++
+			var dates []*edtfValue
+			for _, field := range fields {
+				for _, elem := range asList(res.Attributes[field]) {
+					value := scalarString(elem)
+					report.Counts["dates"]++
+					v, err := parseEdtf(value)
+					if err != nil {
+						message := fmt.Sprintf("the date '%s' of '%s' is not valid EDTF: %s", value, res.label(), err)
+						if hint := edtfHint(value); hint != "" {
+							message += "; " + hint
+						}
+						report.add(Finding{Level: LevelError, Check: "dates", Subject: res.String(), Field: field, Actual: value, Message: message})
+						continue
+					}
+					report.Counts[fmt.Sprintf("level %d dates", v.level)]++
+					if field == "field_date" {
+						dates = append(dates, v)
+					}
+				}
+			}
+			verifyLifespan(res, dates, report)
+		}
+	}
+}
+
+// Verifies that a term whose name ends with a lifespan has a date spanning it
+func verifyLifespan(res *JsonApiResource, dates []*edtfValue, report *Report) {
+	m := nameLifespan.FindStringSubmatch(res.label())
+	if m == nil || len(dates) == 0 {
+		return
+	}
+	var values []string
+	for _, v := range dates {
+		if strconv.FormatInt(v.earliest.year, 10) == m[1] && strconv.FormatInt(v.latest.year, 10) == m[2] {
+			return
+		}
+		values = append(values, v.text)
+	}
+	report.add(Finding{
+		Level:    LevelWarning,
+		Check:    "dates",
+		Subject:  res.String(),
+		Field:    "field_date",
+		Expected: m[1] + "/" + m[2],
+		Actual:   strings.Join(values, " | "),
+		Message:  fmt.Sprintf("none of the dates of '%s' spans the lifespan %s-%s in its name", res.label(), m[1], m[2]),
+	})
+}
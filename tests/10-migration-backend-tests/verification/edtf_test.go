@@ -0,0 +1,178 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseEdtf(t *testing.T) {
+	for _, test := range []struct {
+		value            string
+		level            int
+		earliest, latest string
+	}{
+		// level 0
+		{"1943", 0, "1943-01-01", "1943-12-31"},
+		{"1941-11", 0, "1941-11-01", "1941-11-30"},
+		{"2010-01-01", 0, "2010-01-01", "2010-01-01"},
+		{"2000-02-29", 0, "2000-02-29", "2000-02-29"},
+		{"1985-04-12T23:20:30Z", 0, "1985-04-12", "1985-04-12"},
+		{"1874/1940", 0, "1874-01-01", "1940-12-31"},
+		{"1902-02-20/1984-04-22", 0, "1902-02-20", "1984-04-22"},
+		// level 1
+		{"Y170000002", 1, "170000002-01-01", "170000002-12-31"},
+		{"-1985", 1, "-1985-01-01", "-1985-12-31"},
+		{"2001-21", 1, "2001-03-01", "2001-05-31"},
+		{"2001-24", 1, "2001-12-01", "2002-02-28"},
+		{"1984?", 1, "1984-01-01", "1984-12-31"},
+		{"2004-06~", 1, "2004-06-01", "2004-06-30"},
+		{"2004-06-11%", 1, "2004-06-11", "2004-06-11"},
+		{"201X", 1, "2010-01-01", "2019-12-31"},
+		{"20XX", 1, "2000-01-01", "2099-12-31"},
+		{"1985-04-XX", 1, "1985-04-01", "1985-04-30"},
+		{"1985-XX-XX", 1, "1985-01-01", "1985-12-31"},
+		{"1985-04-12/..", 1, "1985-04-12", "max"},
+		{"/1985-04-12", 1, "min", "1985-04-12"},
+		{"1984~/2004-06", 1, "1984-01-01", "2004-06-30"},
+		// level 2
+		{"Y-17E7", 2, "-170000000-01-01", "-170000000-12-31"},
+		{"1950S2", 2, "1900-01-01", "1999-12-31"},
+		{"2001-33", 2, "2001-01-01", "2001-03-31"},
+		{"2004?-06-11", 2, "2004-06-11", "2004-06-11"},
+		{"?2004-06-~11", 2, "2004-06-11", "2004-06-11"},
+		{"156X-12-25", 2, "1560-12-25", "1569-12-25"},
+		{"1XXX-XX", 2, "1000-01-01", "1999-12-31"},
+		{"1984-1X", 2, "1984-10-01", "1984-12-31"},
+		{"XXXX-12-XX", 2, "0000-12-01", "9999-12-31"},
+		{"[1667, 1668, 1670..1672]", 2, "1667-01-01", "1672-12-31"},
+		{"[..1760-12-03]", 2, "min", "1760-12-03"},
+		{"{1960, 1961-12}", 2, "1960-01-01", "1961-12-31"},
+	} {
+		v, err := parseEdtf(test.value)
+		if !assert.Nil(t, err, test.value) {
+			continue
+		}
+		bound := func(d edtfDay) string {
+			switch d {
+			case firstDay:
+				return "min"
+			case lastDay:
+				return "max"
+			}
+			return d.String()
+		}
+		assert.Equal(t, test.level, v.level, test.value)
+		assert.Equal(t, test.earliest, bound(v.earliest), test.value)
+		assert.Equal(t, test.latest, bound(v.latest), test.value)
+	}
+
+	for value, expected := range map[string]string{
+		"":                    "the date is empty",
+		"1902-1984":           "'84' is unexpected after the date",
+		"1941-13":             "the month 13 is not a month or a season",
+		"1941-00":             "the month 00 is not valid",
+		"1900-02-29":          "February 1900 has no day 29",
+		"1941-11-31":          "November 1941 has no day 31",
+		"1941-1":              "the month '1' is not two digits",
+		"41":                  "the year '41' is not four digits",
+		"Y1941":               "the year 'Y1941' has no more than four digits, so is written without the 'Y'",
+		"2001-21-01":          "the season 21 has no days",
+		"1984/1941":           "the interval ends before it begins",
+		"../..":               "the interval has neither a start nor an end",
+		"1941/1942/1943":      "the interval has more than two dates",
+		"[1670..1667]":        "the range '1670..1667' ends before it begins",
+		"[1667, 1668":         "the set is not closed by ']'",
+		"{}":                  "the set is empty",
+		"[1667, ..1668]":      "the set member '..1668' is not a date or a range of dates",
+		"1985-04-12T25:20:30": "the date and time is not valid",
+		"1985-04-12T23:20":    "the date and time is not of the form YYYY-MM-DDThh:mm:ss",
+		"1950S5":              "the year '1950S5' does not have that many significant digits",
+		"c. 1940":             "the year 'c. 1' is not four digits",
+	} {
+		_, err := parseEdtf(value)
+		if assert.NotNil(t, err, value) {
+			assert.Equal(t, expected, err.Error(), value)
+		}
+	}
+}
+
+func Test_CanonicalEdtf(t *testing.T) {
+	for _, same := range [][]string{
+		{"1985", "1985-XX", "1985-XX-XX"},
+		{"2004-06?", "2004?-?06", "?2004-?06"},
+		{"2004-06-11%", "%2004-%06-%11", "2004%-06%-11%"},
+		{"1985-04-12T23:20:30Z", "1985-04-13T01:20:30+02:00", "1985-04-12T22:20:30-01"},
+		{"[1667, 1668, 1670..1672]", "[1670..1672, 1668, 1667, 1667]"},
+		{"1950S2", "19XX"},
+	} {
+		for _, s := range same[1:] {
+			assert.Equal(t, canonicalEdtf(same[0]), canonicalEdtf(s), "%s %s", same[0], s)
+		}
+	}
+
+	for _, different := range [][2]string{
+		{"1985", "1985?"},
+		{"2004-06?", "2004-?06"},
+		{"1985/..", "1985/"},
+		{"[1667, 1668]", "{1667, 1668}"},
+		{"2001-21", "2001-25"},
+		{"1985-04-12T23:20:30", "1985-04-12T23:20:30Z"},
+	} {
+		assert.NotEqual(t, canonicalEdtf(different[0]), canonicalEdtf(different[1]), "%v", different)
+	}
+	assert.Equal(t, "1902-1984", canonicalEdtf("1902-1984"))
+}
+
+func Test_EdtfHint(t *testing.T) {
+	for value, expected := range map[string]string{
+		"1902-1984":   "a span of years is written '1902/1984'",
+		"1902 - 1984": "a span of years is written '1902/1984'",
+		"ca. 1940":    "an approximate year is written '1940~'",
+		"circa 1940":  "an approximate year is written '1940~'",
+		"1940s":       "a decade is written '194X'",
+		"4/22/1984":   "a day is written year first, e.g. '1984-MM-DD'",
+		"someday":     "",
+	} {
+		assert.Equal(t, expected, edtfHint(value), value)
+	}
+}
+
+func Test_SameValues(t *testing.T) {
+	assert.True(t, sameValues(dateField, []string{"1985-XX", "2004-06?"}, []string{"?2004-?06", "1985"}))
+	assert.False(t, sameValues(attributeField, []string{"1985-XX"}, []string{"1985"}))
+	assert.False(t, sameValues(dateField, []string{"1985"}, []string{"1985?"}))
+	assert.False(t, sameValues(dateField, []string{"1985"}, []string{"1985", "1985"}))
+	// values that are not EDTF are compared as they are
+	assert.True(t, sameValues(dateField, []string{"1902-1984"}, []string{"1902-1984"}))
+}
+
+func Test_VerifyDates(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	fake.add(
+		fakeTaxonomyTerm("person", "adams", "Adams, Ansel Easton, 1902-1984", map[string]interface{}{"field_date": []interface{}{"1902-02-20/1984-04-22"}}),
+		fakeTaxonomyTerm("person", "hine", "Hine, Lewis Wickes, 1874-1940", map[string]interface{}{"field_date": []interface{}{"1874", "1902-1984"}}),
+		fakeTaxonomyTerm("subject", "moon", "Moon", nil),
+		fakeNode("node--islandora_object", "moonrise", "Moonrise", map[string]interface{}{
+			"field_date_created":   []interface{}{"1941-11-01", "1941-11", "1943?"},
+			"field_date_published": []interface{}{"1941-11-31"},
+			"field_years":          []interface{}{"[1941, 1943..1945]", "1940s"},
+		}),
+	)
+
+	report := newReport("dates", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyDates(report)
+	assert.True(t, report.failed())
+
+	assert.Equal(t, []string{
+		"error: node--islandora_object moonrise [field_date_published] the date '1941-11-31' of 'Moonrise' is not valid EDTF: November 1941 has no day 31",
+		"error: node--islandora_object moonrise [field_years] the date '1940s' of 'Moonrise' is not valid EDTF: 's' is unexpected after the date; a decade is written '194X'",
+		"error: taxonomy_term--person hine [field_date] the date '1902-1984' of 'Hine, Lewis Wickes, 1874-1940' is not valid EDTF: '84' is unexpected after the date; a span of years is written '1902/1984'",
+		"warning: taxonomy_term--person hine [field_date] none of the dates of 'Hine, Lewis Wickes, 1874-1940' spans the lifespan 1874-1940 in its name",
+	}, findingLines(report))
+	assert.Equal(t, 3, report.Checked)
+	assert.Equal(t, 9, report.Counts["dates"])
+	assert.Equal(t, 4, report.Counts["level 0 dates"])
+	assert.Equal(t, 1, report.Counts["level 1 dates"])
+	assert.Equal(t, 1, report.Counts["level 2 dates"])
+}
@@ -1,25 +1,5 @@
 package main
 
-import (
-	"strings"
-)
-
-// Encapsulates the entity type and bundle of a Drupal resource.
-//
-// DrupalType is parsed from the JSONAPI response, where type is represented, e.g. as:
-//   "type": "taxonomy_term--person"
-type DrupalType string
-
-// The entity (e.g. taxonomy_term, node, etc) encapsulated by this type
-func (t DrupalType) entity() string {
-	return strings.Split(string(t), "--")[0]
-}
-
-// The bundle (e.g. 'person', 'islandora_object', etc) encapsulated by this type
-func (t DrupalType) bundle() string {
-	return strings.Split(string(t), "--")[1]
-}
-
 // Represents the expected results of a migrated person
 type ExpectedPerson struct {
 	Type        string
@@ -105,10 +85,7 @@ type ExpectedRepoObj struct {
 		Rel  string
 		Name string
 	}
-	Description []struct {
-		Value    string
-		LangCode string `json:"language"`
-	}
+	Description []LanguageString
 }
 
 // Represents the expected results of a migrated Access Rights taxonomy term
@@ -272,18 +249,12 @@ type ExpectedLanguage struct {
 
 // Represents the expected results of a migrated Collection entity
 type ExpectedCollection struct {
-	Type          string
-	Bundle        string
-	Title         string
-	TitleLangCode string `json:"title_language"`
-	AltTitle      []struct {
-		Value    string
-		LangCode string `json:"language"`
-	} `json:"alternative_title"`
-	Description []struct {
-		Value    string
-		LangCode string `json:"language"`
-	}
+	Type             string
+	Bundle           string
+	Title            string
+	TitleLangCode    string           `json:"title_language"`
+	AltTitle         []LanguageString `json:"alternative_title"`
+	Description      []LanguageString
 	ContactEmail     string   `json:"contact_email"`
 	ContactName      string   `json:"contact_name"`
 	CollectionNumber []string `json:"collection_number"`
@@ -323,11 +294,6 @@ type ExpectedCorporateBody struct {
 	} `json:"relationships"`
 }
 
-type LanguageString struct {
-	Value    string
-	LangCode string `json:"language"`
-}
-
 type ExpectedMediaGeneric struct {
 	Type         string
 	Bundle       string
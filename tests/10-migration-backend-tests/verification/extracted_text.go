@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// The media holding the text extracted from a document, in its file and, as edited, in field_edited_text
+	extractedTextType DrupalType = "media--extracted_text"
+
+	// The largest number of runes of the texts whose edit distance is computed: the similarity of texts that differ
+	// in more runes than this is estimated from the character bigrams they share
+	editDistanceLimit = 10000
+)
+
+var (
+	// Matches HTML comments, and start and end tags with their attributes
+	htmlElement = regexp.MustCompile(`<!--[\s\S]*?-->|<(/?)([a-zA-Z][a-zA-Z0-9]*)((?:\s+[^\s"'>/=]+(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'>]+))?)*)\s*/?>`)
+
+	// Matches the elements whose content is not text
+	htmlScriptElement = regexp.MustCompile(`(?is)<(script|style)\b[^>]*>.*?</(script|style)\s*>`)
+
+	// Matches the attributes of a tag matched by htmlElement
+	htmlAttribute = regexp.MustCompile(`([^\s"'>/=]+)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+))?`)
+
+	// Elements whose tags separate the words of the text on either side
+	htmlBlockElements = map[string]bool{
+		"blockquote": true, "br": true, "dd": true, "div": true, "dl": true, "dt": true, "h1": true, "h2": true, "h3": true,
+		"h4": true, "h5": true, "h6": true, "hr": true, "li": true, "ol": true, "p": true, "pre": true, "td": true,
+		"th": true, "tr": true, "ul": true,
+	}
+
+	// Attributes whose values are URLs
+	htmlUrlAttributes = map[string]bool{"href": true, "src": true, "cite": true}
+
+	// The elements filter_autop and filter_url add to text escaped by filter_html_escape
+	escapedTextElements = map[string]map[string]bool{"p": {}, "br": {}, "a": {"href": true}}
+)
+
+// A text format: the HTML its filters permit in processed text
+type textFormat struct {
+	id string
+	// true if the format escapes HTML, in which case values are plain text
+	escapes bool
+	// the elements and attributes of elements permitted in processed text, or nil if any are permitted
+	allowed map[string]map[string]bool
+	// true if images must be served by the site (filter_html_image_secure)
+	localImages bool
+}
+
+// Parses the allowed_html setting of filter_html, e.g. "<a href hreflang> <em> <ol start type>", answering the
+// attributes permitted for each element.  Restrictions on the values of attributes (e.g. `<ol type="1 A I">`) are
+// not enforced.
+func parseAllowedHtml(allowed string) map[string]map[string]bool {
+	elements := make(map[string]map[string]bool)
+	for _, m := range htmlElement.FindAllStringSubmatch(allowed, -1) {
+		if m[2] == "" {
+			continue
+		}
+		attributes := make(map[string]bool)
+		for _, a := range htmlAttribute.FindAllStringSubmatch(m[3], -1) {
+			attributes[strings.ToLower(a[1])] = true
+		}
+		elements[strings.ToLower(m[2])] = attributes
+	}
+	return elements
+}
+
+// Answers the text format of the filter_format config entity, from the filters it enables
+func newTextFormat(res *JsonApiResource) *textFormat {
+	f := &textFormat{id: res.attribute("drupal_internal__format")}
+	filters, _ := res.Attributes["filters"].(map[string]interface{})
+	enabled := func(id string) map[string]interface{} {
+		filter, _ := filters[id].(map[string]interface{})
+		if filter == nil || filter["status"] != true {
+			return nil
+		}
+		return filter
+	}
+
+	if filter := enabled("filter_html"); filter != nil {
+		settings, _ := filter["settings"].(map[string]interface{})
+		f.allowed = parseAllowedHtml(scalarString(settings["allowed_html"]))
+	}
+	if enabled("filter_html_escape") != nil {
+		f.escapes = true
+		f.allowed = escapedTextElements
+	}
+	f.localImages = enabled("filter_html_image_secure") != nil
+	return f
+}
+
+// Answers the text of a value, for comparison with other text: scripts and styles are removed from HTML, as are the
+// tags of other elements, separating words where they are of block elements, and entities are decoded.  The text is
+// normalized to Unicode NFC, and runs of whitespace are collapsed to a single space.
+//
+//   "Hello,&nbsp;<em>extracted</em> text<br />world!\n" -> "Hello, extracted text world!"
+func normalizeText(value string, isHtml bool) string {
+	value = strings.TrimPrefix(value, "\uFEFF")
+	if isHtml {
+		value = htmlScriptElement.ReplaceAllString(value, "")
+		value = htmlElement.ReplaceAllStringFunc(value, func(tag string) string {
+			if m := htmlElement.FindStringSubmatch(tag); htmlBlockElements[strings.ToLower(m[2])] {
+				return " "
+			}
+			return ""
+		})
+		value = html.UnescapeString(value)
+	}
+	return strings.Join(strings.Fields(norm.NFC.String(value)), " ")
+}
+
+// Answers the similarity of two texts between 0 and 1: one less the edit distance between their runes divided by the
+// length of the longer.  The common prefix and suffix of the texts are not compared, and the similarity of texts that
+// differ over more than editDistanceLimit runes is estimated by the Dice coefficient of their character bigrams.
+func textSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+
+	for len(ra) > 0 && len(rb) > 0 && ra[0] == rb[0] {
+		ra, rb = ra[1:], rb[1:]
+	}
+	for len(ra) > 0 && len(rb) > 0 && ra[len(ra)-1] == rb[len(rb)-1] {
+		ra, rb = ra[:len(ra)-1], rb[:len(rb)-1]
+	}
+	if len(ra) > editDistanceLimit || len(rb) > editDistanceLimit {
+		return bigramSimilarity(a, b)
+	}
+
+	// the Levenshtein distance, computed a row at a time
+	previous, current := make([]int, len(rb)+1), make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return 1 - float64(previous[len(rb)])/float64(longest)
+}
+
+// Answers the least of three ints
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// Answers the Dice coefficient of the character bigrams of two texts
+func bigramSimilarity(a, b string) float64 {
+	bigrams := func(s string) map[[2]rune]int {
+		counts := make(map[[2]rune]int)
+		r := []rune(s)
+		for i := 1; i < len(r); i++ {
+			counts[[2]rune{r[i-1], r[i]}]++
+		}
+		return counts
+	}
+	ba, bb := bigrams(a), bigrams(b)
+	shared, total := 0, 0
+	for bigram, n := range ba {
+		total += n
+		if m := bb[bigram]; m < n {
+			shared += m
+		} else {
+			shared += n
+		}
+	}
+	for _, n := range bb {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	return 2 * float64(shared) / float64(total)
+}
+
+// Answers the problems of processed text that the filters of its format should have removed: elements or attributes
+// the format does not permit, comments, event handler and style attributes, scripting URLs, and images served by other
+// sites.  Nothing is a problem in a format that permits any HTML.
+func sanitizationProblems(processed string, f *textFormat, baseUrl string) []string {
+	if f.allowed == nil {
+		return nil
+	}
+	site, _ := url.Parse(baseUrl)
+
+	var problems []string
+	for _, m := range htmlElement.FindAllStringSubmatch(processed, -1) {
+		if m[2] == "" {
+			problems = append(problems, "contains a comment")
+			continue
+		}
+		element := strings.ToLower(m[2])
+		attributes, ok := f.allowed[element]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("contains the element <%s>", element))
+			continue
+		}
+		if m[1] == "/" {
+			continue
+		}
+
+		for _, a := range htmlAttribute.FindAllStringSubmatch(m[3], -1) {
+			name, value := strings.ToLower(a[1]), html.UnescapeString(strings.Trim(a[2], `"'`))
+			switch {
+			case strings.HasPrefix(name, "on") || name == "style" || !attributes[name]:
+				problems = append(problems, fmt.Sprintf("contains the attribute %s of <%s>", name, element))
+			case htmlUrlAttributes[name]:
+				scheme := strings.ToLower(strings.Join(strings.Fields(value), ""))
+				if i := strings.Index(scheme, ":"); i >= 0 && (strings.HasPrefix(scheme, "javascript:") ||
+					strings.HasPrefix(scheme, "vbscript:") || strings.HasPrefix(scheme, "data:")) {
+					problems = append(problems, fmt.Sprintf("contains the %s URL '%s' in <%s>", scheme[:i], scheme, element))
+				} else if element == "img" && name == "src" && f.localImages {
+					if u, err := url.Parse(value); err != nil || (u.Host != "" && (site == nil || u.Host != site.Host)) {
+						problems = append(problems, fmt.Sprintf("contains the image '%s' of another site", value))
+					}
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// Verifies the text of every extracted text media:
+//
+//   - the content of its file (field_media_file) must be UTF-8 text, which after normalization (see normalizeText)
+//     must match the value of field_edited_text, otherwise the similarity of the two is reported: an error if the
+//     similarity is below minSimilarity, and a warning if the text was edited within it
+//   - field_edited_text must have a text format, and its processed HTML must contain only what the filters of the
+//     format permit
+func (c *jsonApiClient) verifyExtractedText(minSimilarity float64, report *Report) {
+	media, err := c.collection(fmt.Sprintf("%s/jsonapi/%s/%s", c.baseUrl, extractedTextType.entity(), extractedTextType.bundle()))
+	if err != nil {
+		report.error("text", string(extractedTextType), "unable to retrieve the extracted text media: %s", err)
+		return
+	}
+
+	formats := make(map[string]*textFormat)
+	for i := range media {
+		res := &media[i]
+		report.Checked++
+		text, _ := res.Attributes["field_edited_text"].(map[string]interface{})
+		format := scalarString(text["format"])
+		fail := func(level, expected, actual, message string) {
+			report.add(Finding{
+				Level:    level,
+				Check:    "text",
+				Subject:  res.String(),
+				Field:    "field_edited_text",
+				Expected: expected,
+				Actual:   actual,
+				Message:  message,
+			})
+		}
+
+		if format == "" {
+			fail(LevelError, "", "", fmt.Sprintf("the extracted text of '%s' has no text format", res.label()))
+			continue
+		}
+		f, ok := formats[format]
+		if !ok {
+			f, err = c.textFormat(format)
+			if err != nil {
+				report.error("text", res.String(), "unable to retrieve the text format %s: %s", format, err)
+				continue
+			}
+			formats[format] = f
+		}
+
+		value := normalizeText(scalarString(text["value"]), !f.escapes)
+		if processed := scalarString(text["processed"]); processed == "" && value != "" {
+			fail(LevelError, "", "", fmt.Sprintf("the extracted text of '%s' has not been processed", res.label()))
+		} else if f.allowed == nil {
+			report.Counts["unrestricted formats"]++
+		} else {
+			for _, problem := range sanitizationProblems(processed, f, c.baseUrl) {
+				fail(LevelError, "", "", fmt.Sprintf("the processed text of '%s' %s, which the %s format does not permit", res.label(), problem, format))
+			}
+		}
+
+		content, err := c.fileContent(res, "field_media_file")
+		if err != nil {
+			report.add(Finding{
+				Level:   LevelError,
+				Check:   "text",
+				Subject: res.String(),
+				Field:   "field_media_file",
+				Message: fmt.Sprintf("unable to retrieve the extracted text of '%s': %s", res.label(), err),
+			})
+			continue
+		}
+		if !utf8.Valid(content) {
+			report.add(Finding{
+				Level:   LevelError,
+				Check:   "text",
+				Subject: res.String(),
+				Field:   "field_media_file",
+				Message: fmt.Sprintf("the extracted text file of '%s' is not UTF-8", res.label()),
+			})
+			continue
+		}
+
+		extracted := normalizeText(string(content), false)
+		if extracted == value {
+			report.Counts["identical"]++
+			continue
+		}
+		similarity := textSimilarity(extracted, value)
+		level := LevelWarning
+		if similarity < minSimilarity {
+			level = LevelError
+		}
+		report.Counts["edited"]++
+		fail(level, abbreviate(extracted), abbreviate(value),
+			fmt.Sprintf("the edited text of '%s' differs from its extracted text file, %.1f%% of its characters are the same", res.label(), similarity*100))
+	}
+}
+
+// Answers the text format with the id
+func (c *jsonApiClient) textFormat(id string) (*textFormat, error) {
+	formats, err := c.find("filter_format--filter_format", "drupal_internal__format", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(formats) != 1 {
+		return nil, fmt.Errorf("%w: the text format %s", ErrNotFound, id)
+	}
+	return newTextFormat(&formats[0]), nil
+}
+
+// Answers the content of the file the field of a media refers to
+func (c *jsonApiClient) fileContent(media *JsonApiResource, field string) ([]byte, error) {
+	var file *JsonApiResource
+	for _, target := range media.related(field) {
+		res, err := c.resolve(target.JsonApiData)
+		if err != nil {
+			return nil, err
+		}
+		file = res
+	}
+	if file == nil {
+		return nil, fmt.Errorf("%s has no file", field)
+	}
+
+	uri, _ := file.Attributes["uri"].(map[string]interface{})
+	var content bytes.Buffer
+	if _, err := c.download(scalarString(uri["url"]), &content); err != nil {
+		return nil, err
+	}
+	return content.Bytes(), nil
+}
+
+// Answers at most the first 80 characters of text, for reporting
+func abbreviate(text string) string {
+	if r := []rune(text); len(r) > 80 {
+		return string(r[:79]) + "…"
+	}
+	return text
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The allowed_html of the basic_html format of the site
+const basicHtml = "<a href hreflang> <em> <strong> <cite> <blockquote cite> <code> <ul type> <ol start type> <li> <dl> <dt> <dd> <h2 id> <h3 id> <h4 id> <h5 id> <h6 id> <p> <br> <span> <img src alt height width data-entity-type data-entity-uuid data-align data-caption>"
+
+// Answers a filter_format enabling the filters, with the allowed_html of filter_html if it is enabled
+func fakeTextFormat(id, allowedHtml string, filters ...string) fakeResource {
+	enabled := make(map[string]interface{})
+	for _, filter := range filters {
+		enabled[filter] = map[string]interface{}{"id": filter, "status": true, "settings": map[string]interface{}{}}
+	}
+	if allowedHtml != "" {
+		enabled["filter_html"] = map[string]interface{}{"id": "filter_html", "status": true, "settings": map[string]interface{}{"allowed_html": allowedHtml}}
+	}
+	return fakeResource{
+		Type:       "filter_format--filter_format",
+		Id:         id + "-uuid",
+		Attributes: map[string]interface{}{"drupal_internal__format": id, "filters": enabled},
+	}
+}
+
+// Answers an extracted text media of the file, with the edited text
+func fakeExtractedText(id, fileId, value, format, processed string) fakeResource {
+	return fakeFileMedia(extractedTextType, id, fileId, map[string]interface{}{
+		"field_edited_text": map[string]interface{}{"value": value, "format": format, "processed": processed},
+	})
+}
+
+func Test_NormalizeText(t *testing.T) {
+	assert.Equal(t, "Hello, extracted text world!", normalizeText("Hello, extracted text world!<br />\n", true))
+	assert.Equal(t, "Hello, extracted text world!", normalizeText("\uFEFFHello,  extracted\ttext\r\nworld!\n", false))
+	assert.Equal(t, "Hello, extracted text world!", normalizeText("<p>Hello,&nbsp;<em>extracted</em> text</p><!-- comment --><p>world!</p>", true))
+	assert.Equal(t, "Hello world", normalizeText("Hello <script type=\"text/javascript\">x()</script><STYLE>p {}</STYLE>world", true))
+	assert.Equal(t, "<p>1 &lt; 2</p>", normalizeText("<p>1 &lt; 2</p>", false))
+	// a decomposed é is composed
+	assert.Equal(t, "caf\u00e9", normalizeText("cafe\u0301", false))
+}
+
+func Test_TextSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, textSimilarity("", ""))
+	assert.Equal(t, 1.0, textSimilarity("kitten", "kitten"))
+	assert.InDelta(t, 1-3/7.0, textSimilarity("kitten", "sitting"), 1e-9)
+	assert.Equal(t, 0.0, textSimilarity("abc", ""))
+
+	// texts differing in too many characters are compared by their bigrams
+	long := make([]rune, editDistanceLimit+1)
+	for i := range long {
+		long[i] = rune('a' + i%26)
+	}
+	assert.Equal(t, 1.0, bigramSimilarity(string(long), string(long)))
+	assert.InDelta(t, 0.0, textSimilarity(string(long), "ZYXWVU"+string(make([]rune, editDistanceLimit))), 0.01)
+}
+
+func Test_SanitizationProblems(t *testing.T) {
+	basic := &textFormat{id: "basic_html", allowed: parseAllowedHtml(basicHtml), localImages: true}
+	assert.Equal(t, map[string]bool{"href": true, "hreflang": true}, basic.allowed["a"])
+
+	assert.Nil(t, sanitizationProblems(`<p>Hello, <a href="https://example.org">extracted</a> text world!<br></p>`, basic, "https://islandora-idc.traefik.me"))
+	assert.Nil(t, sanitizationProblems(`<img src="/system/files/logo.png" alt="logo"><img src="https://islandora-idc.traefik.me/logo.png">`, basic, "https://islandora-idc.traefik.me"))
+	assert.Equal(t, []string{
+		"contains the element <script>",
+		"contains the element <script>",
+		"contains the attribute onclick of <p>",
+		"contains the attribute style of <span>",
+		"contains the javascript URL 'javascript:alert(1)' in <a>",
+		"contains the image 'http://example.org/tracker.gif' of another site",
+		"contains a comment",
+	}, sanitizationProblems(`<script>alert(1)</script><p onclick="alert(1)"><span style='color: red'>Hi</span>`+
+		`<a href="java&#10;script:alert(1)">there</a><img src=http://example.org/tracker.gif><!-- comment -->`, basic, "https://islandora-idc.traefik.me"))
+
+	plain := &textFormat{id: "plain_text", escapes: true, allowed: escapedTextElements}
+	assert.Nil(t, sanitizationProblems("<p>&lt;b&gt;Hello&lt;/b&gt;<br />\n<a href=\"http://example.org\">http://example.org</a></p>\n", plain, ""))
+	assert.Equal(t, []string{"contains the element <b>", "contains the element <b>"}, sanitizationProblems("<p><b>Hello</b></p>", plain, ""))
+
+	assert.Nil(t, sanitizationProblems("<script>alert(1)</script>", &textFormat{id: "full_html"}, ""))
+}
+
+func Test_VerifyExtractedText_Matches(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	fake.add(
+		fakeTextFormat("basic_html", basicHtml, "filter_html_image_secure"),
+		fakeTextFormat("plain_text", "", "filter_html_escape", "filter_autop", "filter_url"),
+		fakeTextFormat("full_html", "", "filter_htmlcorrector"),
+		fakeStoredFile(fake, "hello-file", "Hello, extracted text world!\n", 29),
+		fakeExtractedText("hello", "hello-file", "Hello, extracted text world!<br />\n", "basic_html", "Hello, extracted text world!<br />"),
+		fakeStoredFile(fake, "plain-file", "1 < 2\r\nand 3 > 2\n", 18),
+		fakeExtractedText("plain", "plain-file", "1 < 2\nand 3 > 2\n", "plain_text", "<p>1 &lt; 2<br />\nand 3 &gt; 2</p>\n"),
+		fakeStoredFile(fake, "full-file", "Scripted", 8),
+		fakeExtractedText("full", "full-file", "<script>x()</script>Scripted", "full_html", "<script>x()</script>Scripted"),
+	)
+
+	report := newReport("text", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyExtractedText(1, report)
+
+	assert.Equal(t, 0, len(report.Findings), "unexpected findings: %v", report.Findings)
+	assert.Equal(t, 3, report.Checked)
+	assert.Equal(t, 3, report.Counts["identical"])
+	assert.Equal(t, 1, report.Counts["unrestricted formats"])
+	assert.Equal(t, 0, report.Counts["edited"])
+}
+
+func Test_VerifyExtractedText_Problems(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	fake.add(
+		fakeTextFormat("basic_html", basicHtml),
+		fakeStoredFile(fake, "edited-file", "Hello, extracted text world!", 28),
+		fakeExtractedText("edited", "edited-file", "Hello, edited text world!", "basic_html", "Hello, edited text world!"),
+		fakeStoredFile(fake, "unsanitized-file", "Hello", 5),
+		fakeExtractedText("unsanitized", "unsanitized-file", "Hello<script>alert(1)</script>", "basic_html", "Hello<script>alert(1)</script>"),
+		fakeStoredFile(fake, "binary-file", "\xff\xfeH\x00i\x00", 6),
+		fakeExtractedText("binary", "binary-file", "Hi", "basic_html", "Hi"),
+		fakeExtractedText("unformatted", "binary-file", "Hi", "", ""),
+		fakeExtractedText("unprocessed", "missing-file", "Hi", "basic_html", ""),
+	)
+
+	report := newReport("text", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyExtractedText(0.9, report)
+	assert.True(t, report.failed())
+
+	problems := findingLines(report)
+	assert.Equal(t, []string{
+		"error: media--extracted_text edited [field_edited_text] the edited text of 'edited' differs from its extracted text file, 82.1% of its characters are the same",
+		"error: media--extracted_text unsanitized [field_edited_text] the processed text of 'unsanitized' contains the element <script>, which the basic_html format does not permit",
+		"error: media--extracted_text unsanitized [field_edited_text] the processed text of 'unsanitized' contains the element <script>, which the basic_html format does not permit",
+		"error: media--extracted_text binary [field_media_file] the extracted text file of 'binary' is not UTF-8",
+		"error: media--extracted_text unformatted [field_edited_text] the extracted text of 'unformatted' has no text format",
+		"error: media--extracted_text unprocessed [field_edited_text] the extracted text of 'unprocessed' has not been processed",
+	}, problems[:len(problems)-1])
+	assert.Contains(t, problems[len(problems)-1],
+		"error: media--extracted_text unprocessed [field_media_file] unable to retrieve the extracted text of 'unprocessed': resource not found")
+
+	// the edit is within the supplied similarity
+	report = newReport("text", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyExtractedText(0.8, report)
+	assert.Equal(t, LevelWarning, report.Findings[0].Level)
+	assert.Equal(t, "Hello, extracted text world!", report.Findings[0].Expected)
+	assert.Equal(t, "Hello, edited text world!", report.Findings[0].Actual)
+	assert.Equal(t, 1, report.Counts["edited"])
+}
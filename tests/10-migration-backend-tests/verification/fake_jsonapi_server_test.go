@@ -0,0 +1,475 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// An in-process stand-in for the Drupal JSONAPI module, used to unit test the JSONAPI client code without a running
+// IDC stack.
+//
+// Resources are served from `/jsonapi/<entity>/<bundle>` (a collection) and `/jsonapi/<entity>/<bundle>/<id>` (an
+// individual resource).  Collections support `filter[<attribute>]=<value>` (including `filter[id]`, and
+// `filter[<relationship>.id]`), `include` of relationships, and pagination using `page[offset]` and `page[limit]`.
+// Error documents and arbitrary (e.g. malformed) response bodies may be served for any path.
+type fakeJsonApi struct {
+	*httptest.Server
+
+	// the number of resources served per page when the request does not supply `page[limit]`
+	pageSize int
+
+	mu        sync.Mutex
+	resources []fakeResource
+	responses map[string]fakeResponse
+	requests  []string
+}
+
+// A JSONAPI resource served by the fakeJsonApi.  Relationships are keyed by field name, and each relationship holds
+// either a single fakeRelationship (a to-one relationship), or a slice of them (a to-many relationship).  A nil
+// relationship is served as `"data": null`.
+type fakeResource struct {
+	Type          DrupalType             `json:"type"`
+	Id            string                 `json:"id"`
+	Attributes    map[string]interface{} `json:"attributes"`
+	Relationships map[string]interface{} `json:"-"`
+}
+
+// The target of a relationship from one fakeResource to another
+type fakeRelationship struct {
+	Type DrupalType             `json:"type"`
+	Id   string                 `json:"id"`
+	Meta map[string]interface{} `json:"meta,omitempty"`
+}
+
+// A canned response served for a path in place of the resources known to the fakeJsonApi
+type fakeResponse struct {
+	status int
+	body   string
+}
+
+// Starts a fake JSONAPI server which is closed when the test completes.  Relationships are resolved against the fake
+// server for the duration of the test.
+func newFakeJsonApi(t *testing.T) *fakeJsonApi {
+	fake := &fakeJsonApi{
+		pageSize:  50,
+		responses: make(map[string]fakeResponse),
+	}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serve))
+
+	origBaseurl := resolveBaseurl
+	resolveBaseurl = fake.URL
+	t.Cleanup(func() {
+		resolveBaseurl = origBaseurl
+		fake.Close()
+	})
+
+	return fake
+}
+
+// Adds resources to the fake server
+func (fake *fakeJsonApi) add(resources ...fakeResource) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.resources = append(fake.resources, resources...)
+}
+
+// Removes the resources with the supplied ids from the fake server, as if they were deleted
+func (fake *fakeJsonApi) remove(ids ...string) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	var remaining []fakeResource
+	for _, res := range fake.resources {
+		if !contains(ids, res.Id) {
+			remaining = append(remaining, res)
+		}
+	}
+	fake.resources = remaining
+}
+
+// Sets an attribute of the resource with the supplied id, e.g. its "drupal_internal__nid"
+func (fake *fakeJsonApi) set(id, attribute string, value interface{}) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	for i := range fake.resources {
+		if fake.resources[i].Id == id {
+			fake.resources[i].Attributes[attribute] = value
+		}
+	}
+}
+
+// Serves a JSONAPI error document with the supplied status and detail for requests to path (e.g.
+// "/jsonapi/node/islandora_object"), or to a request URI including its query
+func (fake *fakeJsonApi) fail(path string, status int, detail string) {
+	doc, _ := json.Marshal(map[string]interface{}{
+		"jsonapi": map[string]string{"version": "1.0"},
+		"errors": []map[string]string{
+			{
+				"title":  http.StatusText(status),
+				"status": strconv.Itoa(status),
+				"detail": detail,
+			},
+		},
+	})
+	fake.respond(path, status, string(doc))
+}
+
+// Serves the body verbatim with the supplied status for requests to path
+func (fake *fakeJsonApi) respond(path string, status int, body string) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.responses[path] = fakeResponse{status: status, body: body}
+}
+
+// Stops serving the canned response for requests to path, e.g. after fail
+func (fake *fakeJsonApi) recover(path string) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	delete(fake.responses, path)
+}
+
+// Answers the request URIs received by the fake server, in order
+func (fake *fakeJsonApi) received() []string {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return append([]string{}, fake.requests...)
+}
+
+// Answers a node of the type with the title, and the supplied attributes if any
+func fakeNode(t DrupalType, id, title string, attributes map[string]interface{}) fakeResource {
+	return fakeNamedResource(t, id, "title", title, attributes)
+}
+
+// Answers a term of the vocabulary with the name, and the supplied attributes if any
+func fakeTaxonomyTerm(vocabulary, id, name string, attributes map[string]interface{}) fakeResource {
+	return fakeNamedResource(DrupalType("taxonomy_term--"+vocabulary), id, "name", name, attributes)
+}
+
+// Answers a resource of the type whose attribute (e.g. title or name) holds the value, along with the supplied
+// attributes if any
+func fakeNamedResource(t DrupalType, id, attribute, value string, attributes map[string]interface{}) fakeResource {
+	if attributes == nil {
+		attributes = make(map[string]interface{})
+	}
+	attributes[attribute] = value
+	return fakeResource{Type: t, Id: id, Attributes: attributes}
+}
+
+// Answers a media of the type whose file field (e.g. field_media_document) refers to the file, named by its id, with
+// the supplied attributes if any
+func fakeFileMedia(t DrupalType, id, fileId string, attributes map[string]interface{}) fakeResource {
+	media := fakeNamedResource(t, id, "name", id, attributes)
+	media.Relationships = map[string]interface{}{fixtureFields[t]["uri"].field: fakeRelationship{Type: "file--file", Id: fileId}}
+	return media
+}
+
+// Answers a file--file resource with the supplied uri and size
+func fakeFile(id, uri string, size int) fakeResource {
+	return fakeResource{
+		Type: "file--file",
+		Id:   id,
+		Attributes: map[string]interface{}{
+			"filename": uri[strings.LastIndex(uri, "/")+1:],
+			"filesize": size,
+			"uri":      map[string]interface{}{"value": uri},
+		},
+	}
+}
+
+// Answers a file--file resource for content, stored under its content-addressed uri, which the fake serves
+func fakeStoredFile(fake *fakeJsonApi, id, content string, size int) fakeResource {
+	sum := fmt.Sprintf("%x", sha1.Sum([]byte(content)))
+	path := fmt.Sprintf("%s/%s/%s/%s", sum[0:2], sum[2:4], sum[4:6], sum[6:])
+	fake.respond("/system/files/"+path, http.StatusOK, content)
+	file := fakeFile(id, "private://"+path, size)
+	file.Attributes["filename"] = id + ".pdf"
+	file.Attributes["uri"].(map[string]interface{})["url"] = "/system/files/" + path
+	return file
+}
+
+// Answers each finding of the report as a line of its level, subject, field and message, for comparison with the
+// expected findings of a test
+func findingLines(report *Report) []string {
+	var lines []string
+	for _, f := range report.Findings {
+		lines = append(lines, fmt.Sprintf("%s: %s [%s] %s", f.Level, f.Subject, f.Field, f.Message))
+	}
+	return lines
+}
+
+func (fake *fakeJsonApi) serve(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.requests = append(fake.requests, r.URL.RequestURI())
+
+	w.Header().Set("Content-Type", "application/vnd.api+json")
+
+	canned, ok := fake.responses[r.URL.RequestURI()]
+	if !ok {
+		canned, ok = fake.responses[r.URL.Path]
+	}
+	if ok {
+		w.WriteHeader(canned.status)
+		_, _ = w.Write([]byte(canned.body))
+		return
+	}
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(segments) == 1 && segments[0] == "jsonapi" {
+		fake.writeIndex(w)
+		return
+	}
+	if len(segments) < 3 || len(segments) > 4 || segments[0] != "jsonapi" {
+		fake.writeError(w, http.StatusNotFound, fmt.Sprintf("No route found for \"GET %s\"", r.URL.Path))
+		return
+	}
+
+	resourceType := DrupalType(segments[1] + "--" + segments[2])
+	var matches []fakeResource
+	for _, res := range fake.resources {
+		if res.Type == resourceType {
+			matches = append(matches, res)
+		}
+	}
+
+	if len(segments) == 4 {
+		for _, res := range matches {
+			if res.Id == segments[3] {
+				fake.writeDocument(w, r, res.document(), fake.included(r, []fakeResource{res}), nil)
+				return
+			}
+		}
+		fake.writeError(w, http.StatusNotFound, fmt.Sprintf("The \"entity\" parameter was not converted for the path \"%s\"", r.URL.Path))
+		return
+	}
+
+	if len(matches) == 0 && !fake.knows(resourceType) {
+		fake.writeError(w, http.StatusNotFound, fmt.Sprintf("No route found for \"GET %s\"", r.URL.Path))
+		return
+	}
+
+	query := r.URL.Query()
+	matches = filterFakeResources(matches, query)
+
+	offset, limit := 0, fake.pageSize
+	if v, err := strconv.Atoi(query.Get("page[offset]")); err == nil && v >= 0 {
+		offset = v
+	}
+	if v, err := strconv.Atoi(query.Get("page[limit]")); err == nil && v > 0 {
+		limit = v
+	}
+
+	links := map[string]interface{}{"self": map[string]string{"href": fake.URL + r.URL.RequestURI()}}
+	page := []fakeResource{}
+	if offset < len(matches) {
+		end := offset + limit
+		if end < len(matches) {
+			next := url.Values{}
+			for k, v := range query {
+				next[k] = v
+			}
+			next.Set("page[offset]", strconv.Itoa(end))
+			next.Set("page[limit]", strconv.Itoa(limit))
+			links["next"] = map[string]string{"href": fmt.Sprintf("%s%s?%s", fake.URL, r.URL.Path, next.Encode())}
+		} else {
+			end = len(matches)
+		}
+		page = matches[offset:end]
+	}
+
+	data := make([]map[string]interface{}, len(page))
+	for i, res := range page {
+		data[i] = res.document()
+	}
+	fake.writeDocument(w, r, data, fake.included(r, page), links)
+}
+
+// Serves the JSONAPI entry point, which links to the collection of every resource type known to the fake server
+func (fake *fakeJsonApi) writeIndex(w http.ResponseWriter) {
+	links := map[string]interface{}{"self": map[string]string{"href": fake.URL + "/jsonapi"}}
+	for _, res := range fake.resources {
+		links[string(res.Type)] = map[string]string{
+			"href": fmt.Sprintf("%s/jsonapi/%s/%s", fake.URL, res.Type.entity(), res.Type.bundle()),
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"jsonapi": map[string]string{"version": "1.0"},
+		"data":    []interface{}{},
+		"links":   links,
+	})
+}
+
+// Answers true if the fake server is configured with any resource of the given type, or any relationship targeting
+// the given type.  Unknown types are answered with a 404, just like Drupal answers for an unknown bundle.
+func (fake *fakeJsonApi) knows(resourceType DrupalType) bool {
+	for _, res := range fake.resources {
+		for _, rel := range res.relationships() {
+			if rel.Type == resourceType {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Answers the resources named by the `include` query parameter of the request
+func (fake *fakeJsonApi) included(r *http.Request, resources []fakeResource) []map[string]interface{} {
+	include := r.URL.Query().Get("include")
+	if include == "" {
+		return nil
+	}
+
+	var (
+		included []map[string]interface{}
+		seen     = make(map[string]bool)
+	)
+	for _, field := range strings.Split(include, ",") {
+		for _, res := range resources {
+			for _, rel := range toFakeRelationships(res.Relationships[field]) {
+				for _, target := range fake.resources {
+					if target.Id == rel.Id && target.Type == rel.Type && !seen[target.Id] {
+						seen[target.Id] = true
+						included = append(included, target.document())
+					}
+				}
+			}
+		}
+	}
+	return included
+}
+
+func (fake *fakeJsonApi) writeDocument(w http.ResponseWriter, r *http.Request, data interface{}, included []map[string]interface{}, links map[string]interface{}) {
+	doc := map[string]interface{}{
+		"jsonapi": map[string]string{"version": "1.0"},
+		"data":    data,
+	}
+	if included != nil {
+		doc["included"] = included
+	}
+	if links != nil {
+		doc["links"] = links
+	}
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(doc)
+}
+
+func (fake *fakeJsonApi) writeError(w http.ResponseWriter, status int, detail string) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"jsonapi": map[string]string{"version": "1.0"},
+		"errors": []map[string]string{
+			{
+				"title":  http.StatusText(status),
+				"status": strconv.Itoa(status),
+				"detail": detail,
+			},
+		},
+	})
+}
+
+// Answers the JSONAPI representation of the resource
+func (res fakeResource) document() map[string]interface{} {
+	doc := map[string]interface{}{
+		"type":       res.Type,
+		"id":         res.Id,
+		"attributes": res.Attributes,
+	}
+
+	if len(res.Relationships) > 0 {
+		rels := make(map[string]interface{})
+		for field, rel := range res.Relationships {
+			rels[field] = map[string]interface{}{"data": rel}
+		}
+		doc["relationships"] = rels
+	}
+
+	return doc
+}
+
+// Answers every relationship target of the resource, ordered by field name
+func (res fakeResource) relationships() []fakeRelationship {
+	var fields []string
+	for field := range res.Relationships {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var rels []fakeRelationship
+	for _, field := range fields {
+		rels = append(rels, toFakeRelationships(res.Relationships[field])...)
+	}
+	return rels
+}
+
+func toFakeRelationships(rel interface{}) []fakeRelationship {
+	switch v := rel.(type) {
+	case fakeRelationship:
+		return []fakeRelationship{v}
+	case []fakeRelationship:
+		return v
+	}
+	return nil
+}
+
+// Answers the resources that satisfy every `filter[<field>]` query parameter.  A filter on a multi-valued attribute
+// is satisfied if any value matches.
+func filterFakeResources(resources []fakeResource, query url.Values) []fakeResource {
+	var filtered []fakeResource
+
+	for _, res := range resources {
+		matches := true
+		for key := range query {
+			if !strings.HasPrefix(key, "filter[") || !strings.HasSuffix(key, "]") {
+				continue
+			}
+			field := strings.TrimSuffix(strings.TrimPrefix(key, "filter["), "]")
+			want := query.Get(key)
+
+			if field == "id" {
+				matches = matches && res.Id == want
+				continue
+			}
+			// the id of the target of a relationship, e.g. filter[field_media_of.id]
+			if strings.HasSuffix(field, ".id") {
+				found := false
+				switch rel := res.Relationships[strings.TrimSuffix(field, ".id")].(type) {
+				case fakeRelationship:
+					found = rel.Id == want
+				case []fakeRelationship:
+					for _, target := range rel {
+						found = found || target.Id == want
+					}
+				}
+				matches = matches && found
+				continue
+			}
+
+			switch v := res.Attributes[field].(type) {
+			case []string:
+				found := false
+				for _, s := range v {
+					found = found || s == want
+				}
+				matches = matches && found
+			default:
+				matches = matches && fmt.Sprintf("%v", v) == want
+			}
+		}
+
+		if matches {
+			filtered = append(filtered, res)
+		}
+	}
+
+	return filtered
+}
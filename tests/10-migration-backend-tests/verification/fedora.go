@@ -0,0 +1,364 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// The Fedora REST endpoint of the local stack, following the traefik routes in docker-compose
+	DefaultFcrepoUrl = "https://fcrepo-idc.traefik.me/fcrepo/rest"
+
+	PremisHasSize = "http://www.loc.gov/premis/rdf/v1#hasSize"
+)
+
+// The resource types Islandora synchronizes with Fedora, mirroring `gemini_pseudo_bundles` in
+// `codebase/config/sync/islandora.settings.yml`
+var fedoraBundles = map[DrupalType]bool{
+	"node--islandora_object": true,
+	"media--audio":           true,
+	"media--file":            true,
+	"media--image":           true,
+	"media--video":           true,
+}
+
+// The entity types crawled to verify synchronization with Fedora
+var fedoraEntities = map[string]bool{
+	"node":  true,
+	"media": true,
+	"file":  true,
+}
+
+// Matches the Drupal URI of an entity as it appears in RDF, e.g. "http://islandora.traefik.me/node/12?_format=jsonld"
+var drupalEntityUri = regexp.MustCompile(`/((?:node|media|taxonomy/term|file)/\d+)(?:\?.*)?$`)
+
+// Answers the path of the entity identified by a Drupal URI (see JsonApiResource.entityPath), or the empty string if
+// the URI does not identify a Drupal entity
+func entityPathOf(uri string) string {
+	if m := drupalEntityUri.FindStringSubmatch(uri); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// Performs requests of the Fedora (LDP) repository Islandora synchronizes with
+type fedoraClient struct {
+	baseUrl  string
+	username string
+	password string
+	http     *http.Client
+}
+
+// Answers a client for the Fedora REST endpoint at baseUrl.  If username is not empty, requests are authenticated
+// using HTTP basic authentication.
+func newFedoraClient(baseUrl, username, password string) *fedoraClient {
+	return &fedoraClient{
+		baseUrl:  strings.TrimSuffix(baseUrl, "/"),
+		username: username,
+		password: password,
+		http:     &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Answers the path, relative to the Fedora REST endpoint, that Gemini mints for an entity: the first eight characters
+// of its UUID split into pairs, followed by the UUID, e.g.:
+//
+//   3c5f8e5d-3f0c-4c6e-9f57-0c0e2d9f2a31 -> 3c/5f/8e/5d/3c5f8e5d-3f0c-4c6e-9f57-0c0e2d9f2a31
+func geminiPath(uuid string) string {
+	if len(uuid) < 8 {
+		return uuid
+	}
+	return fmt.Sprintf("%s/%s/%s/%s/%s", uuid[0:2], uuid[2:4], uuid[4:6], uuid[6:8], uuid)
+}
+
+// Answers the path of the Fedora binary holding the content of a file.  Files on the `fedora://` filesystem are stored
+// at their path within Fedora; any other file is indexed as external content at the path Gemini mints for it.
+func fedoraBinaryPath(file *JsonApiResource) string {
+	if uri := fileUri(file); strings.HasPrefix(uri, "fedora://") {
+		return strings.TrimPrefix(uri, "fedora://")
+	}
+	return geminiPath(file.Id)
+}
+
+// Answers a request of the resource at path, which is relative to the Fedora REST endpoint
+func (f *fedoraClient) newRequest(method, path string) (*http.Request, error) {
+	req, err := http.NewRequest(method, f.baseUrl+"/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if f.username != "" {
+		req.SetBasicAuth(f.username, f.password)
+	}
+	return req, nil
+}
+
+// Answers an error for an unsuccessful response, wrapping ErrNotFound or ErrForbidden as appropriate
+func fedoraError(res *http.Response) error {
+	u := res.Request.URL.String()
+	switch {
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+		return fmt.Errorf("%w: %s", ErrNotFound, u)
+	case res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("%w: %s", ErrForbidden, u)
+	}
+	return fmt.Errorf("%d status encountered when requesting %s", res.StatusCode, u)
+}
+
+// Answers the RDF of the resource at path, requested as N-Triples
+func (f *fedoraClient) triples(path string) ([]triple, error) {
+	req, err := f.newRequest(http.MethodGet, path)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/n-triples")
+
+	res, err := f.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fedoraError(res)
+	}
+
+	triples, err := parseNTriples(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", req.URL, err)
+	}
+	return triples, nil
+}
+
+// Answers the hex encoded SHA-1 Fedora computes for the binary at path, requested using the `Want-Digest` header
+// (RFC 3230).  Fedora may encode the digest either as hex or as base64.
+func (f *fedoraClient) digest(path string) (string, error) {
+	req, err := f.newRequest(http.MethodHead, path)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Want-Digest", "sha")
+
+	res, err := f.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fedoraError(res)
+	}
+
+	for _, header := range res.Header.Values("Digest") {
+		for _, d := range strings.Split(header, ",") {
+			kv := strings.SplitN(strings.TrimSpace(d), "=", 2)
+			if len(kv) != 2 || !strings.EqualFold(kv[0], "sha") {
+				continue
+			}
+			if sha1Hex.MatchString(strings.ToLower(kv[1])) {
+				return strings.ToLower(kv[1]), nil
+			}
+			if b, err := base64.StdEncoding.DecodeString(kv[1]); err == nil && len(b) == 20 {
+				return hex.EncodeToString(b), nil
+			}
+			return "", fmt.Errorf("unrecognized SHA-1 digest '%s' of %s", kv[1], req.URL)
+		}
+	}
+	return "", fmt.Errorf("no SHA-1 digest answered for %s", req.URL)
+}
+
+// Verifies that every node and media Islandora synchronizes with Fedora has been synchronized.  Each resource is
+// located in Fedora at the path Gemini mints for it; the RDF of a media is the description (`fcr:metadata`) of the
+// binary holding its file.  The title of each resource, the `pcdm:memberOf` of nodes, and the `pcdm:fileOf` and
+// `premis:hasSize` of media are compared with JSONAPI.  The SHA-1 Fedora computes for each binary is compared with
+// the SHA-1 its file is addressed by.
+func (c *jsonApiClient) verifyFedora(f *fedoraClient, report *Report) {
+	idx := newCrawlIndex(c.crawl(report, "fedora", fedoraEntities))
+
+	for _, t := range sortedTypes(idx.crawled) {
+		if !fedoraBundles[t] {
+			continue
+		}
+		for i := range idx.crawled[t] {
+			res := &idx.crawled[t][i]
+			report.Checked++
+			if t.entity() == "node" {
+				c.verifyFedoraNode(f, idx, res, report)
+			} else {
+				c.verifyFedoraMedia(f, idx, res, report)
+			}
+		}
+	}
+}
+
+// Compares a node with its Fedora resource
+func (c *jsonApiClient) verifyFedoraNode(f *fedoraClient, idx *crawlIndex, node *JsonApiResource, report *Report) {
+	path := geminiPath(node.Id)
+	triples, err := f.triples(path)
+	if err != nil {
+		report.error("fedora", node.String(), "unable to retrieve %s/%s: %s", f.baseUrl, path, err)
+		return
+	}
+	report.Counts["fedora resources"]++
+
+	compareFedoraTitle(node, "title", triples, report)
+	c.compareFedoraReferences(f, idx, node, "field_member_of", PcdmMemberOf, triples, report)
+}
+
+// Compares a media with the description of its Fedora binary, and the binary with the file of the media
+func (c *jsonApiClient) verifyFedoraMedia(f *fedoraClient, idx *crawlIndex, media *JsonApiResource, report *Report) {
+	var file *JsonApiResource
+	for _, field := range media.relationshipNames() {
+		if field == "thumbnail" {
+			continue
+		}
+		for _, target := range media.related(field) {
+			if target.Type != "file--file" || target.Id == missingId || file != nil {
+				continue
+			}
+			if file = idx.byId[target.Id]; file == nil {
+				if resolved, err := c.resolve(target.JsonApiData); err == nil {
+					file = resolved
+				}
+			}
+		}
+	}
+	if file == nil {
+		report.error("fedora", media.String(), "unable to locate the Fedora binary of '%s': the media has no file", media.label())
+		return
+	}
+
+	binary := fedoraBinaryPath(file)
+	triples, err := f.triples(binary + "/fcr:metadata")
+	if err != nil {
+		report.error("fedora", media.String(), "unable to retrieve %s/%s/fcr:metadata: %s", f.baseUrl, binary, err)
+		return
+	}
+	report.Counts["fedora resources"]++
+
+	compareFedoraTitle(media, "name", triples, report)
+	c.compareFedoraReferences(f, idx, media, "field_media_of", PcdmFileOf, triples, report)
+	if expected := scalarString(media.Attributes["field_file_size"]); expected != "" {
+		var sizes []string
+		for _, o := range objects(triples, PremisHasSize) {
+			sizes = append(sizes, o.Object)
+		}
+		if !contains(sizes, expected) {
+			report.add(Finding{
+				Level:    LevelError,
+				Check:    "fedora",
+				Subject:  media.String(),
+				Field:    "field_file_size",
+				Expected: expected,
+				Actual:   strings.Join(sizes, ", "),
+				Message:  fmt.Sprintf("the premis:hasSize of '%s' in Fedora does not match Drupal", media.label()),
+			})
+		}
+	}
+
+	expected := contentAddress(fileUri(file))
+	if expected == "" {
+		return
+	}
+	actual, err := f.digest(binary)
+	if err != nil {
+		report.error("fedora", media.String(), "unable to retrieve the digest of %s/%s: %s", f.baseUrl, binary, err)
+		return
+	}
+	if actual != expected {
+		report.add(Finding{
+			Level:    LevelError,
+			Check:    "fedora",
+			Subject:  media.String(),
+			Field:    "uri",
+			Expected: expected,
+			Actual:   actual,
+			Message:  fmt.Sprintf("the SHA-1 of the Fedora binary of '%s' does not match its content-addressed uri %s", file.label(), fileUri(file)),
+		})
+	}
+}
+
+// Compares the named attribute of res with the `dcterms:title` of its Fedora resource
+func compareFedoraTitle(res *JsonApiResource, attribute string, triples []triple, report *Report) {
+	var titles []string
+	for _, o := range objects(triples, DctermsTitle) {
+		titles = append(titles, o.Object)
+	}
+	if expected := res.attribute(attribute); !contains(titles, expected) {
+		report.add(Finding{
+			Level:    LevelError,
+			Check:    "fedora",
+			Subject:  res.String(),
+			Field:    attribute,
+			Expected: expected,
+			Actual:   strings.Join(titles, ", "),
+			Message:  fmt.Sprintf("the dcterms:title of '%s' in Fedora does not match Drupal", res.label()),
+		})
+	}
+}
+
+// Compares the nodes referred to by a relationship field of res with the objects of predicate in its Fedora resource.
+// A node may be referred to in Fedora by its Drupal URI, or by the URI of its own Fedora resource.
+func (c *jsonApiClient) compareFedoraReferences(f *fedoraClient, idx *crawlIndex, res *JsonApiResource, field, predicate string,
+	triples []triple, report *Report) {
+
+	// each object of the predicate, keyed by the entity path or UUID it refers to
+	actual := make(map[string]string)
+	for _, o := range objects(triples, predicate) {
+		if path := entityPathOf(o.Object); path != "" {
+			actual[path] = o.Object
+		} else if strings.HasPrefix(o.Object, f.baseUrl+"/") {
+			segments := strings.Split(strings.TrimSuffix(o.Object, "/fcr:metadata"), "/")
+			actual[segments[len(segments)-1]] = o.Object
+		} else {
+			actual[o.Object] = o.Object
+		}
+	}
+
+	var missing []string
+	for _, target := range res.related(field) {
+		if target.Id == missingId {
+			continue
+		}
+		keys := []string{target.Id}
+		node := idx.byId[target.Id]
+		if node == nil {
+			node, _ = c.resolve(target.JsonApiData)
+		}
+		if node != nil && node.entityPath() != "" {
+			keys = append(keys, node.entityPath())
+		}
+
+		found := false
+		for _, key := range keys {
+			if _, ok := actual[key]; ok {
+				found = true
+				delete(actual, key)
+			}
+		}
+		if !found {
+			missing = append(missing, keys[len(keys)-1])
+		}
+	}
+
+	if len(missing) == 0 && len(actual) == 0 {
+		return
+	}
+	var unexpected []string
+	for _, object := range actual {
+		unexpected = append(unexpected, object)
+	}
+	sort.Strings(unexpected)
+	report.add(Finding{
+		Level:    LevelError,
+		Check:    "fedora",
+		Subject:  res.String(),
+		Field:    field,
+		Expected: strings.Join(missing, ", "),
+		Actual:   strings.Join(unexpected, ", "),
+		Message:  fmt.Sprintf("the %s of '%s' in Fedora does not match Drupal: expected references are missing, or unexpected references are present", predicate, res.label()),
+	})
+}
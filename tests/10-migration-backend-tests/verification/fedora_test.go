@@ -0,0 +1,175 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// An in-process stand-in for the Fedora (LDP) repository, serving canned N-Triples and binary digests by path
+type fakeFedora struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	rdf     map[string]string
+	digests map[string]string
+}
+
+// Starts a fake Fedora repository which is closed when the test completes
+func newFakeFedora(t *testing.T) *fakeFedora {
+	fake := &fakeFedora{rdf: make(map[string]string), digests: make(map[string]string)}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// Serves the RDF of the resource at path, formed from the predicate and object pairs of the supplied triples.  Each
+// pair is an N-Triples term, e.g. `<http://purl.org/dc/terms/title>` and `"Title"`.
+func (fake *fakeFedora) describe(path string, pairs ...string) {
+	var b strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		fmt.Fprintf(&b, "<%s/%s> %s %s .\n", fake.URL, path, pairs[i], pairs[i+1])
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.rdf[path] = b.String()
+}
+
+// Serves a binary at path, whose Digest header carries the supplied value
+func (fake *fakeFedora) binary(path, digest string) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.digests[path] = digest
+}
+
+func (fake *fakeFedora) serve(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	if rdf, ok := fake.rdf[path]; ok && r.Method == http.MethodGet && r.Header.Get("Accept") == "application/n-triples" {
+		w.Header().Set("Content-Type", "application/n-triples")
+		fmt.Fprint(w, rdf)
+		return
+	}
+	if digest, ok := fake.digests[path]; ok && r.Method == http.MethodHead {
+		if r.Header.Get("Want-Digest") == "sha" {
+			w.Header().Set("Digest", "sha="+digest)
+		}
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func iri(s string) string {
+	return "<" + s + ">"
+}
+
+func literal(s string) string {
+	return fmt.Sprintf("%q", s)
+}
+
+func Test_GeminiPath(t *testing.T) {
+	assert.Equal(t, "3c/5f/8e/5d/3c5f8e5d-3f0c-4c6e-9f57-0c0e2d9f2a31", geminiPath(objectId))
+	assert.Equal(t, "3c/5f/8e/5d/3c5f8e5d-3f0c-4c6e-9f57-0c0e2d9f2a31", fedoraBinaryPath(&JsonApiResource{Id: objectId}))
+	assert.Equal(t, "2021-04/image.jpg", fedoraBinaryPath(&JsonApiResource{
+		Id:         imageFileId,
+		Attributes: map[string]interface{}{"uri": map[string]interface{}{"value": "fedora://2021-04/image.jpg"}},
+	}))
+}
+
+// Populates the fakes with a repository item, an image, and a file media synchronized with Fedora
+func populateFedoraFakes(fake *fakeJsonApi, fcrepo *fakeFedora) {
+	populateIntegrityFake(fake)
+	stored := fakeStoredFile(fake, "stored-file", "stored content", 14)
+	fake.add(
+		stored,
+		fakeResource{
+			Type:       "media--file",
+			Id:         "file-media",
+			Attributes: map[string]interface{}{"name": "File One", "field_file_size": 14},
+			Relationships: map[string]interface{}{
+				"field_media_of":   []fakeRelationship{{Type: "node--islandora_object", Id: objectId}},
+				"field_media_file": fakeRelationship{Type: "file--file", Id: "stored-file"},
+			},
+		},
+	)
+	fake.set(objectId, "drupal_internal__nid", 12)
+
+	// the collection is referred to by its Fedora URI, and the repository item by its Drupal URI
+	fcrepo.describe(geminiPath(objectId),
+		iri(DctermsTitle), literal("Repository Item One"),
+		iri(PcdmMemberOf), iri(fcrepo.URL+"/"+geminiPath(collectionId)))
+	fcrepo.describe(geminiPath(imageFileId)+"/fcr:metadata",
+		iri(DctermsTitle), literal("Image One"),
+		iri(PcdmFileOf), iri("http://islandora.traefik.me/node/12?_format=jsonld"))
+
+	sum := sha1.Sum([]byte("stored content"))
+	fcrepo.describe(geminiPath("stored-file")+"/fcr:metadata",
+		iri(DctermsTitle), literal("File One"),
+		iri(PcdmFileOf), iri("http://islandora.traefik.me/node/12?_format=jsonld"),
+		iri(PremisHasSize), `"14"^^<http://www.w3.org/2001/XMLSchema#long>`)
+	// Fedora may answer a base64 digest
+	fcrepo.binary(geminiPath("stored-file"), base64.StdEncoding.EncodeToString(sum[:]))
+}
+
+func Test_VerifyFedora_Consistent(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	fcrepo := newFakeFedora(t)
+	populateFedoraFakes(fake, fcrepo)
+
+	report := newReport("fedora", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyFedora(newFedoraClient(fcrepo.URL, "", ""), report)
+
+	assert.Equal(t, 0, len(report.Findings), "unexpected findings: %v", report.Findings)
+	// the collection is not synchronized with Fedora
+	assert.Equal(t, 3, report.Checked)
+	assert.Equal(t, 3, report.Counts["fedora resources"])
+}
+
+func Test_VerifyFedora_Inconsistent(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	fcrepo := newFakeFedora(t)
+	populateFedoraFakes(fake, fcrepo)
+	fake.add(fakeResource{
+		Type:          "node--islandora_object",
+		Id:            "0a1b2c3d-0000-4000-8000-000000000000",
+		Attributes:    map[string]interface{}{"title": "Never Indexed"},
+		Relationships: map[string]interface{}{},
+	})
+
+	// the title and membership of the repository item are stale, the file media has the wrong size and checksum
+	fcrepo.describe(geminiPath(objectId),
+		iri(DctermsTitle), literal("Repository Item"),
+		iri(PcdmMemberOf), iri("http://islandora.traefik.me/node/99?_format=jsonld"))
+	fcrepo.describe(geminiPath("stored-file")+"/fcr:metadata",
+		iri(DctermsTitle), literal("File One"),
+		iri(PcdmFileOf), iri("http://islandora.traefik.me/node/12?_format=jsonld"),
+		iri(PremisHasSize), `"15"`)
+	fcrepo.binary(geminiPath("stored-file"), fmt.Sprintf("%x", sha1.Sum([]byte("other content"))))
+
+	report := newReport("fedora", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyFedora(newFedoraClient(fcrepo.URL, "", ""), report)
+	assert.True(t, report.failed())
+
+	var problems []string
+	for _, f := range report.Findings {
+		assert.Equal(t, "fedora", f.Check)
+		problems = append(problems, fmt.Sprintf("%s %s: %s %s", f.Subject, f.Field, f.Expected, f.Actual))
+	}
+	assert.Equal(t, []string{
+		"media--file file-media field_file_size: 14 15",
+		fmt.Sprintf("media--file file-media uri: %x %x", sha1.Sum([]byte("stored content")), sha1.Sum([]byte("other content"))),
+		"node--islandora_object 3c5f8e5d-3f0c-4c6e-9f57-0c0e2d9f2a31 title: Repository Item One Repository Item",
+		"node--islandora_object 3c5f8e5d-3f0c-4c6e-9f57-0c0e2d9f2a31 field_member_of: " + collectionId + " http://islandora.traefik.me/node/99?_format=jsonld",
+		"node--islandora_object 0a1b2c3d-0000-4000-8000-000000000000 :  ",
+	}, problems)
+	assert.Contains(t, report.Findings[4].Message, "resource not found")
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The checksum algorithms supported by the `fixity` command.  SHA-1 is always computed, because Drupal names the files
+// it stores after their SHA-1.
+var fixityAlgorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"md5":    md5.New,
+}
+
+// The entity types crawled to check fixity
+var fixityEntities = map[string]bool{
+	"media": true,
+	"file":  true,
+}
+
+var sha1Hex = regexp.MustCompile("^[0-9a-f]{40}$")
+
+// Answers the SHA-1 a file uri is addressed by, or the empty string if the uri is not content-addressed.  Files are
+// stored under a path formed by splitting the hex encoded SHA-1 of their content, e.g.:
+//
+//   private://c9/a0/60/c39365820edc5d1a51f221d49e96a8a730 -> c9a060c39365820edc5d1a51f221d49e96a8a730
+func contentAddress(uri string) string {
+	if i := strings.Index(uri, "://"); i >= 0 {
+		uri = uri[i+3:]
+	}
+	address := strings.ToLower(strings.ReplaceAll(uri, "/", ""))
+	if !sha1Hex.MatchString(address) {
+		return ""
+	}
+	return address
+}
+
+// Parses a comma separated list of checksum algorithms (e.g. "sha256,md5"), answering the algorithms in order.  SHA-1
+// is always included.
+func parseAlgorithms(list string) ([]string, error) {
+	algorithms := []string{"sha1"}
+	for _, algorithm := range strings.Split(list, ",") {
+		algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+		if algorithm == "" || algorithm == "sha1" {
+			continue
+		}
+		if _, ok := fixityAlgorithms[algorithm]; !ok {
+			return nil, fmt.Errorf("unsupported checksum algorithm '%s' (expected one of sha1, sha256, md5)", algorithm)
+		}
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms[1:])
+	return algorithms, nil
+}
+
+// Checks the fixity of every file referred to by a media.  The content of each file is streamed, and its checksums
+// computed using the supplied algorithms.  A file fails the check if its content cannot be retrieved, if the SHA-1
+// of its content differs from the SHA-1 it is addressed by, or if the number of bytes retrieved differs from the
+// `filesize` of the File entity, or the `field_file_size` of the media.  A FixityRecord is added to the report for
+// every file.
+func (c *jsonApiClient) checkFixity(report *Report, algorithms []string) {
+	idx := newCrawlIndex(c.crawl(report, "fixity", fixityEntities))
+
+	// the media referring to each file, keyed by file id, with file ids in the order they were first referred to
+	referrers := make(map[string][]*JsonApiResource)
+	var files []RelData
+	for _, t := range sortedTypes(idx.crawled) {
+		if t.entity() != "media" {
+			continue
+		}
+		media := idx.crawled[t]
+		for i := range media {
+			for _, field := range media[i].relationshipNames() {
+				// thumbnails are generated by Drupal, and are not the file whose size is recorded by the media
+				if field == "thumbnail" {
+					continue
+				}
+				for _, target := range media[i].related(field) {
+					if target.Type != "file--file" || target.Id == missingId {
+						continue
+					}
+					if _, seen := referrers[target.Id]; !seen {
+						files = append(files, target)
+					}
+					referrers[target.Id] = append(referrers[target.Id], &media[i])
+				}
+			}
+		}
+	}
+
+	for _, target := range files {
+		c.checkFileFixity(idx, target, referrers[target.Id], algorithms, report)
+	}
+	report.Counts["files"] = len(files)
+}
+
+// Checks the fixity of a single file referred to by media
+func (c *jsonApiClient) checkFileFixity(idx *crawlIndex, target RelData, media []*JsonApiResource, algorithms []string,
+	report *Report) {
+
+	report.Checked++
+	fail := func(field, expected, actual, format string, args ...interface{}) {
+		report.add(Finding{
+			Level:    LevelError,
+			Check:    "fixity",
+			Subject:  fmt.Sprintf("file--file %s", target.Id),
+			Field:    field,
+			Expected: expected,
+			Actual:   actual,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	file, ok := idx.byId[target.Id]
+	if !ok {
+		resolved, err := c.resolve(target.JsonApiData)
+		if err != nil {
+			fail("", "", "", "unable to resolve the file of %s: %s", media[0], err)
+			return
+		}
+		file = resolved
+	}
+
+	record := FixityRecord{
+		File:      file.Id,
+		Uri:       fileUri(file),
+		Checksums: make(map[string]string),
+		Checked:   time.Now(),
+	}
+	defer func() { report.Fixity = append(report.Fixity, record) }()
+
+	uri, _ := file.Attributes["uri"].(map[string]interface{})
+	u := scalarString(uri["url"])
+	if u == "" {
+		fail("uri", "", "", "'%s' has no url", file.label())
+		return
+	}
+
+	hashes := make(map[string]hash.Hash)
+	writers := make([]io.Writer, len(algorithms))
+	for i, algorithm := range algorithms {
+		hashes[algorithm] = fixityAlgorithms[algorithm]()
+		writers[i] = hashes[algorithm]
+	}
+
+	size, err := c.download(u, io.MultiWriter(writers...))
+	if err != nil {
+		fail("uri", "", "", "unable to retrieve the content of '%s': %s", file.label(), err)
+		return
+	}
+	record.Size = size
+	for algorithm, h := range hashes {
+		record.Checksums[algorithm] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	record.Ok = true
+	actualSize := strconv.FormatInt(size, 10)
+	if address := contentAddress(record.Uri); address != "" && address != record.Checksums["sha1"] {
+		record.Ok = false
+		fail("uri", address, record.Checksums["sha1"], "the SHA-1 of '%s' does not match its content-addressed uri %s", file.label(), record.Uri)
+	}
+	if expected := scalarString(file.Attributes["filesize"]); expected != actualSize {
+		record.Ok = false
+		fail("filesize", expected, actualSize, "the size of '%s' does not match the size of the File entity", file.label())
+	}
+	for _, m := range media {
+		if expected := scalarString(m.Attributes["field_file_size"]); expected != "" && expected != actualSize {
+			record.Ok = false
+			fail("field_file_size", expected, actualSize, "the size of '%s' does not match the file size recorded by %s", file.label(), m)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Answers a document media of the file, recording the supplied file size
+func fakeDocument(id, fileId string, size int) fakeResource {
+	media := fakeFileMedia("media--document", id, fileId, map[string]interface{}{"field_file_size": size})
+	media.Relationships["field_media_of"] = []fakeRelationship{{Type: "node--islandora_object", Id: objectId}}
+	return media
+}
+
+func Test_ContentAddress(t *testing.T) {
+	assert.Equal(t, "c9a060c39365820edc5d1a51f221d49e96a8a730", contentAddress("private://c9/a0/60/c39365820edc5d1a51f221d49e96a8a730"))
+	assert.Equal(t, "047f86c0c26cf42ee9c6eb17910599d3802d2f98", contentAddress("private://04/7f/86/c0c26cf42ee9c6eb17910599d3802d2f98"))
+	assert.Equal(t, "", contentAddress("private://2021-04/image.jpg"))
+	assert.Equal(t, "", contentAddress("fedora://3c/5f/8e/5d/3c5f8e5d-3f0c-4c6e-9f57-0c0e2d9f2a31.jpg"))
+}
+
+func Test_ParseAlgorithms(t *testing.T) {
+	algorithms, err := parseAlgorithms("sha1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"sha1"}, algorithms)
+
+	algorithms, err = parseAlgorithms("MD5, sha256")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"sha1", "md5", "sha256"}, algorithms)
+
+	_, err = parseAlgorithms("crc32")
+	assert.NotNil(t, err)
+}
+
+func Test_CheckFixity(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	good := fakeStoredFile(fake, "good", "good content", 12)
+	corrupt := fakeStoredFile(fake, "corrupt", "original content", 16)
+	fake.respond(corrupt.Attributes["uri"].(map[string]interface{})["url"].(string), http.StatusOK, "altered content!")
+	wrongSize := fakeStoredFile(fake, "wrong-size", "some content", 1000)
+	unavailable := fakeStoredFile(fake, "unavailable", "unavailable content", 19)
+	fake.fail(unavailable.Attributes["uri"].(map[string]interface{})["url"].(string), http.StatusNotFound, "Not Found")
+
+	fake.add(
+		good, corrupt, wrongSize, unavailable,
+		fakeDocument("good-media", "good", 12),
+		// refers to the same file as good-media, which is only checked once
+		fakeDocument("good-media-copy", "good", 12),
+		fakeDocument("corrupt-media", "corrupt", 16),
+		fakeDocument("wrong-size-media", "wrong-size", 1000),
+		fakeDocument("unavailable-media", "unavailable", 19),
+		fakeDocument("wrong-media-size", "good", 13),
+	)
+
+	report := newReport("fixity", fake.URL)
+	newJsonApiClient(fake.URL, "", "").checkFixity(report, []string{"sha1", "md5", "sha256"})
+
+	assert.Equal(t, 4, report.Checked)
+	assert.Equal(t, 4, report.Counts["files"])
+	assert.Equal(t, 4, len(report.Fixity))
+
+	records := make(map[string]FixityRecord)
+	for _, fr := range report.Fixity {
+		records[fr.File] = fr
+	}
+	// the size recorded by wrong-media-size fails the check of an otherwise good file
+	assert.False(t, records["good"].Ok)
+	assert.Equal(t, int64(12), records["good"].Size)
+	assert.Equal(t, fmt.Sprintf("%x", sha1.Sum([]byte("good content"))), records["good"].Checksums["sha1"])
+	assert.Equal(t, 64, len(records["good"].Checksums["sha256"]))
+	assert.Equal(t, 32, len(records["good"].Checksums["md5"]))
+	assert.False(t, records["corrupt"].Ok)
+	assert.False(t, records["wrong-size"].Ok)
+	assert.False(t, records["unavailable"].Ok)
+	assert.Equal(t, 0, len(records["unavailable"].Checksums))
+
+	var problems []string
+	for _, f := range report.Findings {
+		problems = append(problems, fmt.Sprintf("%s %s: %s", f.Subject, f.Field, f.Message))
+	}
+	assert.Equal(t, []string{
+		"file--file good field_file_size: the size of 'good.pdf' does not match the file size recorded by media--document wrong-media-size",
+		"file--file corrupt uri: the SHA-1 of 'corrupt.pdf' does not match its content-addressed uri " + records["corrupt"].Uri,
+		"file--file wrong-size filesize: the size of 'wrong-size.pdf' does not match the size of the File entity",
+		"file--file wrong-size field_file_size: the size of 'wrong-size.pdf' does not match the file size recorded by media--document wrong-size-media",
+		"file--file unavailable uri: unable to retrieve the content of 'unavailable.pdf': resource not found: " + fake.URL + unavailable.Attributes["uri"].(map[string]interface{})["url"].(string),
+	}, problems)
+}
@@ -0,0 +1,593 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// How the value of a key in an expected JSON fixture is found in a JSONAPI resource
+type fieldKind int
+
+const (
+	// A single or multi-valued attribute, e.g. `name` or `field_preferred_name_rest`
+	attributeField fieldKind = iota
+	// A single or multi-valued link attribute.  The fixture supplies URIs as strings, or as objects with a `uri`.
+	linkField
+	// A formatted text attribute.  The fixture supplies an object with any of `value`, `format` and `processed`.
+	textField
+	// An authority link attribute.  The fixture supplies objects with a `uri` and a `source` (or `type`).
+	authorityField
+	// An entity reference.  The fixture supplies the names or titles of the referenced entities, or an object with a
+	// `name`.
+	referenceField
+	// A typed relation.  The fixture supplies objects with a `name` and a `rel_type` (or `rel`).
+	typedRelationField
+	// A language value pair.  The fixture supplies objects with a `value` and a `language` code.
+	languageValueField
+	// A file reference.  The fixture supplies an object with the `url` and `value` of the file uri.
+	fileField
+	// The `alt` text held in the meta of an image file reference
+	altTextField
+	// A single or multi-valued EDTF date attribute, e.g. `field_date_created`.  Dates are compared by their meaning
+	// rather than their spelling (see canonicalEdtf).
+	dateField
+)
+
+// Maps a key of an expected JSON fixture to the Drupal field holding its value
+type fixtureField struct {
+	field string
+	kind  fieldKind
+}
+
+// Fields common to every taxonomy term
+var taxonomyFixtureFields = map[string]fixtureField{
+	"name":        {"name", attributeField},
+	"description": {"description", textField},
+	"authority":   {"field_authority_link", authorityField},
+}
+
+// Fields common to every media with a file
+var mediaFixtureFields = map[string]fixtureField{
+	"name":          {"name", attributeField},
+	"original_name": {"field_original_name", attributeField},
+	"size":          {"field_file_size", attributeField},
+	"mime_type":     {"field_mime_type", attributeField},
+	"use":           {"field_media_use", referenceField},
+	"media_of":      {"field_media_of", referenceField},
+}
+
+// Maps the keys of the expected JSON fixtures of each resource type to Drupal fields.  Fixture keys not present here
+// are not verified by idc-verify.
+var fixtureFields = map[DrupalType]map[string]fixtureField{
+	"taxonomy_term--person": withFields(taxonomyFixtureFields, map[string]fixtureField{
+		"primary_name": {"field_primary_part_of_name", attributeField},
+		"rest_of_name": {"field_preferred_name_rest", attributeField},
+		"fuller_form":  {"field_preferred_name_fuller_form", attributeField},
+		"prefix":       {"field_preferred_name_prefix", attributeField},
+		"suffix":       {"field_preferred_name_suffix", attributeField},
+		"number":       {"field_preferred_name_number", attributeField},
+		"alt_name":     {"field_person_alternate_name", attributeField},
+		"date":         {"field_date", dateField},
+		"knows":        {"field_relationships", typedRelationField},
+	}),
+	"taxonomy_term--family": withFields(taxonomyFixtureFields, map[string]fixtureField{
+		"date":                  {"field_date", dateField},
+		"family_name":           {"field_family_name", attributeField},
+		"title_and_other_words": {"field_title_and_other_words", attributeField},
+		"knowsAbout":            {"field_relationships", typedRelationField},
+	}),
+	"taxonomy_term--corporate_body": withFields(taxonomyFixtureFields, map[string]fixtureField{
+		"primary_name":                  {"field_primary_name", attributeField},
+		"subordinate_name":              {"field_subordinate_name", attributeField},
+		"date_of_meeting_or_treaty":     {"field_date_of_meeting_or_treaty", dateField},
+		"location_of_meeting":           {"field_location_of_meeting", attributeField},
+		"num_of_section_or_meet":        {"field_num_of_section_or_meet", attributeField},
+		"corporate_body_alternate_name": {"field_corporate_body_alt_name", attributeField},
+		"date":                          {"field_date", dateField},
+		"relationships":                 {"field_relationships", typedRelationField},
+	}),
+	"taxonomy_term--geo_location": withFields(taxonomyFixtureFields, map[string]fixtureField{
+		"geo_alt_name": {"field_geo_alt_name", attributeField},
+		"broader":      {"field_broader", linkField},
+	}),
+	"taxonomy_term--language": withFields(taxonomyFixtureFields, map[string]fixtureField{
+		"language_code": {"field_language_code", attributeField},
+	}),
+	"taxonomy_term--islandora_access": withFields(taxonomyFixtureFields, map[string]fixtureField{
+		"parent": {"parent", referenceField},
+	}),
+	"taxonomy_term--access_rights":     taxonomyFixtureFields,
+	"taxonomy_term--copyright_and_use": taxonomyFixtureFields,
+	"taxonomy_term--genre":             taxonomyFixtureFields,
+	"taxonomy_term--resource_types":    taxonomyFixtureFields,
+	"taxonomy_term--subject":           taxonomyFixtureFields,
+	"node--collection_object": {
+		"title":             {"title", attributeField},
+		"title_language":    {"field_title_language", referenceField},
+		"alternative_title": {"field_alternative_title", languageValueField},
+		"description":       {"field_description", languageValueField},
+		"contact_email":     {"field_collection_contact_email", attributeField},
+		"contact_name":      {"field_collection_contact_name", attributeField},
+		"collection_number": {"field_collection_number", attributeField},
+		"member_of":         {"field_member_of", referenceField},
+		"access_terms":      {"field_access_terms", referenceField},
+		"finding_aid":       {"field_finding_aid", linkField},
+	},
+	"node--islandora_object": {
+		"title":              {"title", attributeField},
+		"title_language":     {"field_title_language", referenceField},
+		"abstract":           {"field_abstract", languageValueField},
+		"access_rights":      {"field_access_rights", referenceField},
+		"access_terms":       {"field_access_terms", referenceField},
+		"alt_title":          {"field_alternative_title", languageValueField},
+		"catalog_link":       {"field_library_catalog_link", linkField},
+		"collection_number":  {"field_collection_number", attributeField},
+		"contributor":        {"field_contributor", typedRelationField},
+		"copyright_and_use":  {"field_copyright_and_use", referenceField},
+		"copyright_holder":   {"field_copyright_holder", referenceField},
+		"creator":            {"field_creator", typedRelationField},
+		"custodial_history":  {"field_custodial_history", languageValueField},
+		"date_available":     {"field_date_available", dateField},
+		"date_copyrighted":   {"field_date_copyrighted", dateField},
+		"date_created":       {"field_date_created", dateField},
+		"date_published":     {"field_date_published", dateField},
+		"description":        {"field_description", languageValueField},
+		"digital_identifier": {"field_digital_identifier", attributeField},
+		"digital_publisher":  {"field_digital_publisher", referenceField},
+		"display_hints":      {"field_display_hints", referenceField},
+		"dspace_identifier":  {"field_dspace_identifier", linkField},
+		"dspace_itemid":      {"field_dspace_item_id", attributeField},
+		"extent":             {"field_extent", attributeField},
+		"featured_item":      {"field_featured_item", attributeField},
+		"finding_aid":        {"field_finding_aid", linkField},
+		"genre":              {"field_genre", referenceField},
+		"geoportal_link":     {"field_geoportal_link", linkField},
+		"is_part_of":         {"field_is_part_of", linkField},
+		"issn":               {"field_issn", attributeField},
+		"item_barcode":       {"field_item_barcode", attributeField},
+		"jhir":               {"field_jhir", linkField},
+		"language":           {"field_language", referenceField},
+		"member_of":          {"field_member_of", referenceField},
+		"model":              {"field_model", referenceField},
+		"oclc_number":        {"field_oclc_number", attributeField},
+		"publisher":          {"field_publisher", referenceField},
+		"publisher_country":  {"field_publisher_country", referenceField},
+		"resource_type":      {"field_resource_type", referenceField},
+		"spatial_coverage":   {"field_spatial_coverage", referenceField},
+		"subject":            {"field_subject", referenceField},
+		"toc":                {"field_table_of_contents", languageValueField},
+		"years":              {"field_years", dateField},
+	},
+	"media--audio": withFields(mediaFixtureFields, map[string]fixtureField{
+		"uri": {"field_media_audio_file", fileField},
+	}),
+	"media--document": withFields(mediaFixtureFields, map[string]fixtureField{
+		"uri": {"field_media_document", fileField},
+	}),
+	"media--extracted_text": withFields(mediaFixtureFields, map[string]fixtureField{
+		"uri":            {"field_media_file", fileField},
+		"extracted_text": {"field_edited_text", textField},
+	}),
+	"media--file": withFields(mediaFixtureFields, map[string]fixtureField{
+		"uri": {"field_media_file", fileField},
+	}),
+	"media--fits_technical_metadata": withFields(mediaFixtureFields, map[string]fixtureField{
+		"uri": {"field_media_file", fileField},
+	}),
+	"media--image": withFields(mediaFixtureFields, map[string]fixtureField{
+		"uri":      {"field_media_image", fileField},
+		"alt_text": {"field_media_image", altTextField},
+		"height":   {"field_height", attributeField},
+		"width":    {"field_width", attributeField},
+	}),
+	"media--video": withFields(mediaFixtureFields, map[string]fixtureField{
+		"uri": {"field_media_video_file", fileField},
+	}),
+	"media--remote_video": {
+		"name":      {"name", attributeField},
+		"embed_url": {"field_media_oembed_video", attributeField},
+		"media_of":  {"field_media_of", referenceField},
+	},
+}
+
+// Fixture keys which list the names of the entities related by a single type of typed relation, e.g. `knows` lists
+// the persons a person is related to by `schema:knows`.  Relations of other types are not compared.
+var fixtureRelationTypes = map[string]string{
+	"knows":      "schema:knows",
+	"knowsAbout": "schema:knowsAbout",
+}
+
+// Answers a copy of base with the extra fields added
+func withFields(base, extra map[string]fixtureField) map[string]fixtureField {
+	fields := make(map[string]fixtureField, len(base)+len(extra))
+	for k, v := range base {
+		fields[k] = v
+	}
+	for k, v := range extra {
+		fields[k] = v
+	}
+	return fields
+}
+
+// An expected JSON fixture, e.g. one of the files in the `expected` directory
+type fixture struct {
+	// the path the fixture was read from
+	path   string
+	values map[string]interface{}
+}
+
+// Placeholder fixtures like `{}` describe no entity
+var errEmptyFixture = errors.New("fixture is empty")
+
+// Reads the fixture at path
+func readFixture(path string) (*fixture, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &fixture{path: path}
+	if err := json.Unmarshal(b, &f.values); err != nil {
+		return nil, fmt.Errorf("error decoding the content of file %s as JSON: %w", path, err)
+	}
+	if len(f.values) == 0 {
+		return nil, fmt.Errorf("%w: %s", errEmptyFixture, path)
+	}
+	if entity, _ := f.values["type"].(string); entity == "" {
+		return nil, fmt.Errorf("fixture %s must supply a 'type'", path)
+	}
+	if bundle, _ := f.values["bundle"].(string); bundle == "" {
+		return nil, fmt.Errorf("fixture %s must supply a 'bundle'", path)
+	}
+	return f, nil
+}
+
+// Reads every fixture (i.e. every file with a `.json` extension) in the directory.  Empty fixtures are skipped.
+func readFixtures(dir string) ([]*fixture, error) {
+	var fixtures []*fixture
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		f, err := readFixture(path)
+		if errors.Is(err, errEmptyFixture) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fixtures = append(fixtures, f)
+		return nil
+	})
+
+	return fixtures, err
+}
+
+// The type of resource described by the fixture, e.g. "taxonomy_term--person"
+func (f *fixture) resourceType() DrupalType {
+	entity, _ := f.values["type"].(string)
+	bundle, _ := f.values["bundle"].(string)
+	return DrupalType(entity + "--" + bundle)
+}
+
+// The name of the fixture, i.e. its file name
+func (f *fixture) name() string {
+	return filepath.Base(f.path)
+}
+
+// Answers the JSONAPI filter and value identifying the entity described by the fixture: nodes are identified by
+// title, and everything else by name.
+func (f *fixture) identity() (string, string) {
+	key := "name"
+	if f.resourceType().entity() == "node" {
+		key = "title"
+	}
+	v, _ := f.values[key].(string)
+	return key, v
+}
+
+// The outcome of comparing one key of a fixture with the corresponding Drupal field
+type fieldComparison struct {
+	key      string
+	field    string
+	kind     fieldKind
+	expected []string
+	actual   []string
+	// non-nil if the actual value could not be determined
+	err error
+	// true if the fixture key has no known Drupal field
+	unmapped bool
+}
+
+// Answers true if the expected and actual values hold the same elements, irrespective of order
+func (fc fieldComparison) matches() bool {
+	if fc.err != nil || fc.unmapped {
+		return false
+	}
+	return sameValues(fc.kind, fc.expected, fc.actual)
+}
+
+// Answers true if the values of a field of the supplied kind hold the same elements, irrespective of order.  Dates
+// are the same if they have the same meaning, e.g. "2004-06?" and "2004?-?06".
+func sameValues(kind fieldKind, expected, actual []string) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	normalized := func(values []string) []string {
+		n := append([]string{}, values...)
+		if kind == dateField {
+			for i := range n {
+				n[i] = canonicalEdtf(n[i])
+			}
+		}
+		sort.Strings(n)
+		return n
+	}
+	e, a := normalized(expected), normalized(actual)
+	for i := range e {
+		if e[i] != a[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Locates the entity described by the fixture and compares each of the fixture's keys with the entity's fields.
+// Keys are compared in alphabetical order.
+func (c *jsonApiClient) compareFixture(f *fixture) (*JsonApiResource, []fieldComparison, error) {
+	filter, value := f.identity()
+	if value == "" {
+		return nil, nil, fmt.Errorf("fixture %s must supply a '%s'", f.name(), filter)
+	}
+
+	found, err := c.find(f.resourceType(), filter, value)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(found) != 1 {
+		return nil, nil, fmt.Errorf("exactly one %s with %s '%s' is expected, but found %d", f.resourceType(), filter, value, len(found))
+	}
+	res := &found[0]
+
+	var keys []string
+	for k := range f.values {
+		if k != "type" && k != "bundle" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var comparisons []fieldComparison
+	for _, key := range keys {
+		mapping, ok := fixtureFields[f.resourceType()][key]
+		if !ok {
+			comparisons = append(comparisons, fieldComparison{key: key, unmapped: true})
+			continue
+		}
+		fc := fieldComparison{key: key, field: mapping.field, kind: mapping.kind}
+		fc.expected = expectedValues(mapping.kind, f.values[key])
+		fc.actual, fc.err = c.actualValues(res, mapping, fc.expected)
+		if rel, ok := fixtureRelationTypes[key]; ok {
+			fc.expected, fc.actual = relationsOfType(rel, fc.expected, fc.actual)
+		}
+		comparisons = append(comparisons, fc)
+	}
+
+	return res, comparisons, nil
+}
+
+// Answers the expected names as typed relations of the relation type, and the actual typed relations of that type
+func relationsOfType(rel string, names, actual []string) ([]string, []string) {
+	var expected, related []string
+	for _, name := range names {
+		expected = append(expected, rel+" "+name)
+	}
+	for _, a := range actual {
+		if strings.HasPrefix(a, rel+" ") {
+			related = append(related, a)
+		}
+	}
+	return expected, related
+}
+
+// Verifies the entity described by the fixture, adding a finding to the report for every mismatch
+func (c *jsonApiClient) verifyFixture(f *fixture, report *Report) {
+	report.Checked++
+
+	_, comparisons, err := c.compareFixture(f)
+	if err != nil {
+		report.error("verify", f.name(), "%s", err)
+		return
+	}
+
+	for _, fc := range comparisons {
+		switch {
+		case fc.unmapped:
+			report.warning("verify", f.name(), "fixture key '%s' is not mapped to a field of %s, and was not verified", fc.key, f.resourceType())
+		case fc.err != nil:
+			report.add(Finding{Level: LevelError, Check: "verify", Subject: f.name(), Field: fc.field, Message: fc.err.Error()})
+		case !fc.matches():
+			report.add(Finding{
+				Level:    LevelError,
+				Check:    "verify",
+				Subject:  f.name(),
+				Field:    fc.field,
+				Expected: strings.Join(fc.expected, " | "),
+				Actual:   strings.Join(fc.actual, " | "),
+				Message:  fmt.Sprintf("'%s' does not match", fc.key),
+			})
+		}
+	}
+}
+
+// Normalizes the fixture value of a field of the supplied kind to a list of strings
+func expectedValues(kind fieldKind, v interface{}) []string {
+	var values []string
+
+	switch kind {
+	case textField:
+		if obj, ok := v.(map[string]interface{}); ok {
+			for _, member := range []string{"format", "processed", "value"} {
+				if s, ok := obj[member]; ok {
+					values = append(values, fmt.Sprintf("%s=%s", member, scalarString(s)))
+				}
+			}
+		}
+		return values
+	case altTextField:
+		return []string{scalarString(v)}
+	case fileField:
+		if obj, ok := v.(map[string]interface{}); ok {
+			for _, member := range []string{"url", "value"} {
+				if s, ok := obj[member]; ok {
+					values = append(values, fmt.Sprintf("%s=%s", member, scalarString(s)))
+				}
+			}
+		}
+		return values
+	}
+
+	for _, elem := range asList(v) {
+		obj, isObj := elem.(map[string]interface{})
+		switch {
+		case kind == linkField && isObj:
+			values = append(values, scalarString(obj["uri"]))
+		case kind == authorityField && isObj:
+			source := obj["source"]
+			if source == nil {
+				source = obj["type"]
+			}
+			values = append(values, fmt.Sprintf("%s (%s)", scalarString(obj["uri"]), scalarString(source)))
+		case kind == referenceField && isObj:
+			values = append(values, scalarString(obj["name"]))
+		case kind == typedRelationField && isObj:
+			rel := obj["rel_type"]
+			if rel == nil {
+				rel = obj["rel"]
+			}
+			values = append(values, fmt.Sprintf("%s %s", scalarString(rel), scalarString(obj["name"])))
+		case kind == languageValueField && isObj:
+			values = append(values, LanguageString{Value: scalarString(obj["value"]), LangCode: scalarString(obj["language"])}.String())
+		default:
+			values = append(values, scalarString(elem))
+		}
+	}
+	return values
+}
+
+// Answers the value of the mapped field of the resource as a list of strings.  Relationships are resolved using the
+// client.  The expected values are consulted to decide whether a language is represented by its name or its code.
+func (c *jsonApiClient) actualValues(res *JsonApiResource, mapping fixtureField, expected []string) ([]string, error) {
+	var values []string
+	attr := res.Attributes[mapping.field]
+
+	switch mapping.kind {
+	case attributeField, dateField:
+		for _, elem := range asList(attr) {
+			values = append(values, scalarString(elem))
+		}
+	case linkField:
+		for _, elem := range asList(attr) {
+			if obj, ok := elem.(map[string]interface{}); ok {
+				values = append(values, scalarString(obj["uri"]))
+			}
+		}
+	case textField:
+		if obj, ok := attr.(map[string]interface{}); ok {
+			for _, e := range expected {
+				member := strings.SplitN(e, "=", 2)[0]
+				values = append(values, fmt.Sprintf("%s=%s", member, scalarString(obj[member])))
+			}
+		}
+	case authorityField:
+		for _, elem := range asList(attr) {
+			if obj, ok := elem.(map[string]interface{}); ok {
+				values = append(values, fmt.Sprintf("%s (%s)", scalarString(obj["uri"]), scalarString(obj["source"])))
+			}
+		}
+	case altTextField:
+		for _, rel := range res.related(mapping.field) {
+			alt, err := rel.metaString("alt")
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, alt)
+		}
+	default:
+		for _, rel := range res.related(mapping.field) {
+			target, err := c.resolve(rel.JsonApiData)
+			if err != nil {
+				return nil, fmt.Errorf("unable to resolve %s %s: %w", rel.Type, rel.Id, err)
+			}
+			switch mapping.kind {
+			case referenceField:
+				values = append(values, languageOrLabel(target, expected))
+			case typedRelationField:
+				relType, _ := rel.metaString("rel_type")
+				values = append(values, fmt.Sprintf("%s %s", relType, target.label()))
+			case languageValueField:
+				value, _ := rel.metaString("value")
+				values = append(values, LanguageString{Value: value, LangCode: target.attribute("field_language_code")}.String())
+			case fileField:
+				uri, _ := target.Attributes["uri"].(map[string]interface{})
+				for _, e := range expected {
+					member := strings.SplitN(e, "=", 2)[0]
+					values = append(values, fmt.Sprintf("%s=%s", member, scalarString(uri[member])))
+				}
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// Fixtures refer to languages by name (e.g. "English") or by code (e.g. "eng").  Answers the language code of a
+// language term if the code is expected, otherwise the label of the resource.
+func languageOrLabel(target *JsonApiResource, expected []string) string {
+	if code := target.attribute("field_language_code"); code != "" {
+		for _, e := range expected {
+			if e == code {
+				return code
+			}
+		}
+	}
+	return target.label()
+}
+
+// Answers a JSON value as a list: arrays are answered as-is, null as an empty list, and anything else as a single
+// element list
+func asList(v interface{}) []interface{} {
+	switch l := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return l
+	}
+	return []interface{}{v}
+}
+
+// Answers a scalar JSON value as a string.  Whole numbers are formatted without a fractional part.
+func scalarString(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(s)
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -5,4 +5,5 @@ go 1.15
 require (
 	github.com/logrusorgru/aurora/v3 v3.0.0
 	github.com/stretchr/testify v1.6.1
+	golang.org/x/text v0.3.6
 )
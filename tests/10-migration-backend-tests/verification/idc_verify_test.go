@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Unit tests of idc-verify, performed against a fakeJsonApi rather than a running IDC stack.
+
+// Writes the fixtures to a temporary directory, answering the directory
+func writeFixtures(t *testing.T, fixtures map[string]string) string {
+	dir, err := ioutil.TempDir("", "idc-verify")
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	for name, content := range fixtures {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	return dir
+}
+
+func Test_JsonApiClient_Collection(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateFake(fake)
+	fake.pageSize = 1
+
+	c := newJsonApiClient(fake.URL, "", "")
+	persons, err := c.collection(fake.URL + "/jsonapi/taxonomy_term/person")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(persons))
+	assert.Equal(t, personOneName, persons[0].label())
+	assert.Equal(t, personTwoName, persons[1].label())
+	assert.Equal(t, personTwoId, persons[0].related("field_relationships")[0].Id)
+}
+
+func Test_JsonApiClient_Errors(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateFake(fake)
+	fake.fail("/jsonapi/node/islandora_object", http.StatusForbidden, "The current user is not allowed to GET the selected resource.")
+
+	c := newJsonApiClient(fake.URL, "", "")
+	_, err := c.collection(fake.URL + "/jsonapi/node/page")
+	assert.True(t, errors.Is(err, ErrNotFound), "unexpected error: %v", err)
+
+	_, err = c.collection(fake.URL + "/jsonapi/node/islandora_object")
+	assert.True(t, errors.Is(err, ErrForbidden), "unexpected error: %v", err)
+
+	_, err = c.resolve(JsonApiData{Type: "taxonomy_term--person", Id: "does-not-exist"})
+	assert.True(t, errors.Is(err, ErrNotFound), "unexpected error: %v", err)
+}
+
+func Test_JsonApiClient_ResolveIsCached(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateFake(fake)
+
+	c := newJsonApiClient(fake.URL, "", "")
+	for i := 0; i < 3; i++ {
+		res, err := c.resolve(JsonApiData{Type: "taxonomy_term--language", Id: englishId})
+		assert.Nil(t, err)
+		assert.Equal(t, "eng", res.attribute("field_language_code"))
+	}
+	assert.Equal(t, 1, len(fake.received()))
+}
+
+func Test_JsonApiClient_ResourceTypes(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateFake(fake)
+
+	types, err := newJsonApiClient(fake.URL, "", "").resourceTypes()
+	assert.Nil(t, err)
+	assert.Equal(t, []DrupalType{"node--collection_object", "taxonomy_term--language", "taxonomy_term--person"}, types)
+}
+
+func Test_ReadFixtures(t *testing.T) {
+	dir := writeFixtures(t, map[string]string{
+		"person.json":      `{"type": "taxonomy_term", "bundle": "person", "name": "Adams"}`,
+		"placeholder.json": `{}`,
+		"README.md":        `not a fixture`,
+	})
+
+	fixtures, err := readFixtures(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(fixtures))
+	assert.Equal(t, DrupalType("taxonomy_term--person"), fixtures[0].resourceType())
+
+	dir = writeFixtures(t, map[string]string{"untyped.json": `{"name": "Adams"}`})
+	_, err = readFixtures(dir)
+	assert.NotNil(t, err)
+}
+
+func Test_VerifyFixture(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateFake(fake)
+	c := newJsonApiClient(fake.URL, "", "")
+
+	dir := writeFixtures(t, map[string]string{
+		"collection.json": `{
+			"type": "node",
+			"bundle": "collection_object",
+			"title": "Test Collection One",
+			"title_language": "eng",
+			"alternative_title": [{"value": "Alternate Title", "language": "eng"}],
+			"contact_email": "someone@example.org"
+		}`,
+		"person.json": `{
+			"type": "taxonomy_term",
+			"bundle": "person",
+			"name": "Adams, Ansel Easton, 1902-1984",
+			"primary_name": "Adams",
+			"rest_of_name": ["Ansel"],
+			"knows": ["` + personTwoName + `"],
+			"unknown_key": "a key without a Drupal field"
+		}`,
+		"missing.json": `{"type": "taxonomy_term", "bundle": "person", "name": "Nobody"}`,
+	})
+	fixtures, err := readFixtures(dir)
+	assert.Nil(t, err)
+
+	report := newReport("verify", fake.URL)
+	for _, f := range fixtures {
+		c.verifyFixture(f, report)
+	}
+
+	assert.Equal(t, 3, report.Checked)
+	assert.True(t, report.failed())
+	assert.Equal(t, 2, report.count(LevelError))
+	assert.Equal(t, 1, report.count(LevelWarning))
+
+	for _, f := range report.Findings {
+		switch f.Subject {
+		case "collection.json":
+			t.Errorf("unexpected finding for a matching fixture: %s", f)
+		case "missing.json":
+			assert.Contains(t, f.Message, "found 0")
+		case "person.json":
+			if f.Level == LevelError {
+				assert.Equal(t, "field_preferred_name_rest", f.Field)
+				assert.Equal(t, "Ansel", f.Expected)
+				assert.Equal(t, "Ansel Easton", f.Actual)
+			} else {
+				assert.Contains(t, f.Message, "unknown_key")
+			}
+		}
+	}
+}
+
+// Every key of the expected fixtures is mapped to a Drupal field, so that idc-verify compares it
+func Test_FixtureFields_ExpectedMapped(t *testing.T) {
+	// "date:" is a misspelt key of taxonomy-corporatebody-01.json, which is verified by the migration tests as is
+	ignored := map[string]bool{"type": true, "bundle": true, "date:": true}
+
+	fixtures, err := readFixtures("expected")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, fixtures)
+	for _, f := range fixtures {
+		for key := range f.values {
+			if _, ok := fixtureFields[f.resourceType()][key]; !ok && !ignored[key] {
+				t.Errorf("key '%s' of fixture %s is not mapped to a field of %s", key, f.name(), f.resourceType())
+			}
+		}
+	}
+}
+
+func Test_RelationsOfType(t *testing.T) {
+	expected, actual := relationsOfType("schema:knows", []string{"Weston"}, []string{"schema:knows Weston", "schema:spouse Adams"})
+	assert.Equal(t, []string{"schema:knows Weston"}, expected)
+	assert.Equal(t, []string{"schema:knows Weston"}, actual)
+}
+
+func Test_Audit(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateFake(fake)
+	fake.pageSize = 1
+	fake.fail("/jsonapi/node/collection_object", http.StatusForbidden, "The current user is not allowed to GET the selected resource.")
+
+	report := newReport("audit", fake.URL)
+	newJsonApiClient(fake.URL, "", "").audit(report)
+
+	assert.Equal(t, 3, report.Checked)
+	assert.Equal(t, 2, report.Counts["taxonomy_term--person"])
+	assert.Equal(t, 1, report.Counts["taxonomy_term--language"])
+	assert.False(t, report.failed())
+	assert.Equal(t, 1, report.count(LevelWarning))
+	assert.Equal(t, "node--collection_object", report.Findings[0].Subject)
+}
+
+func Test_Run(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateFake(fake)
+
+	var stdout, stderr bytes.Buffer
+
+	assert.Equal(t, ExitUsage, run([]string{}, &stdout, &stderr))
+	assert.Equal(t, ExitUsage, run([]string{"frobnicate"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "unknown command 'frobnicate'")
+	assert.Equal(t, ExitUsage, run([]string{"fetch", "-url", fake.URL}, &stdout, &stderr))
+	assert.Equal(t, ExitUsage, run([]string{"verify", "-url", fake.URL, "-format", "xml"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "unknown output format 'xml'")
+
+	stdout.Reset()
+	assert.Equal(t, 0, run([]string{"fetch", "-url", fake.URL, "taxonomy_term--person", "name", personTwoName}, &stdout, &stderr))
+	var fetched []JsonApiResource
+	assert.Nil(t, json.Unmarshal(stdout.Bytes(), &fetched))
+	assert.Equal(t, 1, len(fetched))
+	assert.Equal(t, personTwoId, fetched[0].Id)
+
+	dir := writeFixtures(t, map[string]string{
+		"language.json": `{"type": "taxonomy_term", "bundle": "language", "name": "English", "language_code": "eng"}`,
+	})
+	reportFile := filepath.Join(dir, "report.json")
+	assert.Equal(t, 0, run([]string{"verify", "-url", fake.URL, "-fixtures", dir, "-format", "json", "-o", reportFile}, &stdout, &stderr))
+
+	stdout.Reset()
+	assert.Equal(t, 0, run([]string{"diff", "-url", fake.URL, filepath.Join(dir, "language.json")}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "  language_code: eng\n")
+	assert.Contains(t, stdout.String(), "PASS: checked 1")
+
+	stdout.Reset()
+	assert.Equal(t, 0, run([]string{"report", reportFile, reportFile}, &stdout, &stderr))
+	assert.True(t, strings.HasSuffix(stdout.String(), "PASS: checked 2, 0 error(s), 0 warning(s)\n"), stdout.String())
+
+	dir = writeFixtures(t, map[string]string{
+		"language.json": `{"type": "taxonomy_term", "bundle": "language", "name": "English", "language_code": "en"}`,
+	})
+	stdout.Reset()
+	assert.Equal(t, ExitFailed, run([]string{"verify", "-url", fake.URL, "-fixtures", dir}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "FAIL: checked 1, 1 error(s)")
+}
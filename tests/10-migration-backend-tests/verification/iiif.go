@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// The IIIF Image API endpoint of Cantaloupe, following `iiif_server` in `islandora_iiif.settings.yml`
+	DefaultIiifUrl = "https://islandora-idc.traefik.me/cantaloupe/iiif/2"
+
+	IiifImageProtocol = "http://iiif.io/api/image"
+)
+
+// The entity types crawled to verify images are served by the IIIF server
+var iiifEntities = map[string]bool{
+	"media": true,
+	"file":  true,
+}
+
+// Requests the IIIF Image API (https://iiif.io/api/image/2.1/) of an image server
+type iiifClient struct {
+	baseUrl string
+	http    *http.Client
+}
+
+func newIiifClient(baseUrl string) *iiifClient {
+	return &iiifClient{
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		http:    &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// The image information document of an image, answered for `{identifier}/info.json`
+type iiifInfo struct {
+	Context  string `json:"@context"`
+	Id       string `json:"@id"`
+	Protocol string `json:"protocol"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+// An image request of the IIIF Image API, and the dimensions of the image expected in response
+type iiifRequest struct {
+	region   string
+	size     string
+	rotation string
+	width    int
+	height   int
+}
+
+func (r iiifRequest) String() string {
+	return fmt.Sprintf("%s/%s/%s/default.jpg", r.region, r.size, r.rotation)
+}
+
+// Answers the image requests that exercise the region, size and rotation parameters of the IIIF Image API for an image
+// of the supplied dimensions
+func iiifRequests(width, height int) []iiifRequest {
+	half := func(n int) int {
+		if n < 2 {
+			return 1
+		}
+		return n / 2
+	}
+	return []iiifRequest{
+		{"full", "full", "0", width, height},
+		{fmt.Sprintf("0,0,%d,%d", half(width), half(height)), "full", "0", half(width), half(height)},
+		{"full", fmt.Sprintf("%d,", half(width)), "0", half(width), 0},
+		{"full", "full", "90", height, width},
+	}
+}
+
+// Answers the IIIF identifier Islandora uses for a file: its absolute URL, URL-encoded
+func iiifIdentifier(fileUrl string) string {
+	return url.QueryEscape(fileUrl)
+}
+
+// Answers the image information document of the image identified by id
+func (s *iiifClient) info(id string) (*iiifInfo, error) {
+	u := fmt.Sprintf("%s/%s/info.json", s.baseUrl, id)
+	res, err := s.http.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%d status encountered when requesting %s", res.StatusCode, u)
+	}
+
+	info := &iiifInfo{}
+	if err := json.NewDecoder(res.Body).Decode(info); err != nil {
+		return nil, fmt.Errorf("error unmarshaling image information from %s: %w", u, err)
+	}
+	return info, nil
+}
+
+// Performs an image request of the image identified by id, answering the dimensions of the image served
+func (s *iiifClient) image(id string, r iiifRequest) (int, int, error) {
+	u := fmt.Sprintf("%s/%s/%s", s.baseUrl, id, r)
+	res, err := s.http.Get(u)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("%d status encountered when requesting %s", res.StatusCode, u)
+	}
+
+	config, format, err := image.DecodeConfig(res.Body)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error decoding the image answered for %s: %w", u, err)
+	}
+	if format != "jpeg" {
+		return 0, 0, fmt.Errorf("a %s image was answered for %s, expected jpeg", format, u)
+	}
+	return config.Width, config.Height, nil
+}
+
+// Verifies that the file of every image media is served by the IIIF server: its image information must report the
+// `field_width` and `field_height` of the media, and region, size and rotation requests must answer images of the
+// expected dimensions.
+func (c *jsonApiClient) verifyIiif(s *iiifClient, report *Report) {
+	idx := newCrawlIndex(c.crawl(report, "iiif", iiifEntities))
+
+	for i := range idx.crawled["media--image"] {
+		media := &idx.crawled["media--image"][i]
+		report.Checked++
+
+		var file *JsonApiResource
+		for _, target := range media.related("field_media_image") {
+			if file = idx.byId[target.Id]; file == nil && target.Id != missingId {
+				file, _ = c.resolve(target.JsonApiData)
+			}
+		}
+		if file == nil {
+			report.error("iiif", media.String(), "'%s' has no image file", media.label())
+			continue
+		}
+
+		uri, _ := file.Attributes["uri"].(map[string]interface{})
+		fileUrl := scalarString(uri["url"])
+		if strings.HasPrefix(fileUrl, "/") {
+			fileUrl = c.baseUrl + fileUrl
+		}
+		id := iiifIdentifier(fileUrl)
+
+		info, err := s.info(id)
+		if err != nil {
+			report.error("iiif", media.String(), "unable to retrieve the image information of '%s': %s", file.label(), err)
+			continue
+		}
+		report.Counts["served"]++
+		if info.Protocol != IiifImageProtocol {
+			report.error("iiif", media.String(), "the image information of '%s' has protocol '%s', expected '%s'", file.label(), info.Protocol, IiifImageProtocol)
+		}
+
+		if info.Width <= 0 || info.Height <= 0 {
+			report.error("iiif", media.String(), "the image information of '%s' has no dimensions", file.label())
+			continue
+		}
+
+		width, height := scalarString(media.Attributes["field_width"]), scalarString(media.Attributes["field_height"])
+		if width == "" || height == "" {
+			report.warning("iiif", media.String(), "'%s' has no field_width or field_height to compare with the image information", media.label())
+		} else if actual := fmt.Sprintf("%dx%d", info.Width, info.Height); actual != width+"x"+height {
+			report.add(Finding{
+				Level:    LevelError,
+				Check:    "iiif",
+				Subject:  media.String(),
+				Field:    "field_width, field_height",
+				Expected: width + "x" + height,
+				Actual:   actual,
+				Message:  fmt.Sprintf("the dimensions of '%s' reported by the IIIF server do not match Drupal", media.label()),
+			})
+		}
+
+		for _, r := range iiifRequests(info.Width, info.Height) {
+			w, h, err := s.image(id, r)
+			if err != nil {
+				report.error("iiif", media.String(), "image request %s of '%s' failed: %s", r, file.label(), err)
+				continue
+			}
+			expected := fmt.Sprintf("%dx%d", r.width, r.height)
+			if r.height == 0 {
+				// the height of a request for a width is proportional, and may be rounded either way
+				if proportional := r.width * info.Height / info.Width; h >= proportional-1 && h <= proportional+1 {
+					expected = fmt.Sprintf("%dx%d", r.width, h)
+				} else {
+					expected = fmt.Sprintf("%dx%d", r.width, proportional)
+				}
+			}
+			if actual := strconv.Itoa(w) + "x" + strconv.Itoa(h); actual != expected {
+				report.add(Finding{
+					Level:    LevelError,
+					Check:    "iiif",
+					Subject:  media.String(),
+					Field:    r.String(),
+					Expected: expected,
+					Actual:   actual,
+					Message:  fmt.Sprintf("image request %s of '%s' answered an image of the wrong dimensions", r, file.label()),
+				})
+			}
+		}
+	}
+}
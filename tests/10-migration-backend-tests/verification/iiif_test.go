@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// An in-process stand-in for a IIIF Image API 2.1 server.  Image information and JPEG images of the requested
+// dimensions are served for the images it knows, keyed by identifier.
+type fakeIiif struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	images map[string]image.Point
+	// if true, rotation is ignored, as by a non-compliant server
+	ignoreRotation bool
+}
+
+func newFakeIiif(t *testing.T) *fakeIiif {
+	fake := &fakeIiif{images: make(map[string]image.Point)}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// Serves an image of the supplied dimensions for the file at the URL
+func (fake *fakeIiif) serveImage(fileUrl string, width, height int) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.images[fileUrl] = image.Pt(width, height)
+}
+
+func (fake *fakeIiif) serve(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	// the identifier is URL-encoded, so the escaped path is split
+	segments := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+	id, _ := url.QueryUnescape(segments[0])
+	full, ok := fake.images[id]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if len(segments) == 2 && segments[1] == "info.json" {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(iiifInfo{
+			Context:  "http://iiif.io/api/image/2/context.json",
+			Id:       fake.URL + "/" + segments[0],
+			Protocol: IiifImageProtocol,
+			Width:    full.X,
+			Height:   full.Y,
+		})
+		return
+	}
+	if len(segments) != 5 {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	size := full
+	if region := segments[1]; region != "full" {
+		xywh := strings.Split(region, ",")
+		size.X, _ = strconv.Atoi(xywh[2])
+		size.Y, _ = strconv.Atoi(xywh[3])
+	}
+	if s := segments[2]; s != "full" && s != "max" {
+		wh := strings.Split(s, ",")
+		width, _ := strconv.Atoi(wh[0])
+		size = image.Pt(width, width*size.Y/size.X)
+	}
+	if rotation := segments[3]; (rotation == "90" || rotation == "270") && !fake.ignoreRotation {
+		size = image.Pt(size.Y, size.X)
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	_ = jpeg.Encode(w, image.NewGray(image.Rect(0, 0, size.X, size.Y)), nil)
+}
+
+func Test_IiifRequests(t *testing.T) {
+	assert.Equal(t, "full/full/0/default.jpg", iiifRequests(100, 80)[0].String())
+	assert.Equal(t, "0,0,50,40/full/0/default.jpg", iiifRequests(100, 80)[1].String())
+	assert.Equal(t, "full/50,/0/default.jpg", iiifRequests(100, 80)[2].String())
+	assert.Equal(t, 80, iiifRequests(100, 80)[3].width)
+	assert.Equal(t, "https%3A%2F%2Fislandora-idc.traefik.me%2Fsystem%2Ffiles%2Fimage.jpg",
+		iiifIdentifier("https://islandora-idc.traefik.me/system/files/image.jpg"))
+}
+
+// Populates the fake with an image media of the supplied dimensions, whose file is served by the IIIF server
+func populateIiifFakes(fake *fakeJsonApi, iiif *fakeIiif, width, height int) {
+	populateIntegrityFake(fake)
+	fake.set(imageId, "field_width", 100)
+	fake.set(imageId, "field_height", 80)
+	iiif.serveImage(fake.URL+"/system/files/2021-04/image.jpg", width, height)
+}
+
+func Test_VerifyIiif_Served(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	iiif := newFakeIiif(t)
+	populateIiifFakes(fake, iiif, 100, 80)
+
+	report := newReport("iiif", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyIiif(newIiifClient(iiif.URL), report)
+
+	assert.Equal(t, 0, len(report.Findings), "unexpected findings: %v", report.Findings)
+	assert.Equal(t, 1, report.Checked)
+	assert.Equal(t, 1, report.Counts["served"])
+}
+
+func Test_VerifyIiif_Inconsistent(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	iiif := newFakeIiif(t)
+	iiif.ignoreRotation = true
+	populateIiifFakes(fake, iiif, 120, 80)
+	fake.add(
+		fakeResource{
+			Type:       "media--image",
+			Id:         "unserved-image",
+			Attributes: map[string]interface{}{"name": "Unserved Image"},
+			Relationships: map[string]interface{}{
+				"field_media_image": fakeRelationship{Type: "file--file", Id: "unserved-file"},
+			},
+		},
+		fakeResource{Type: "file--file", Id: "unserved-file", Attributes: map[string]interface{}{
+			"filename": "unserved.jpg",
+			"uri":      map[string]interface{}{"value": "private://unserved.jpg", "url": "/system/files/unserved.jpg"},
+		}},
+	)
+
+	report := newReport("iiif", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyIiif(newIiifClient(iiif.URL), report)
+	assert.True(t, report.failed())
+
+	var problems []string
+	for _, f := range report.Findings {
+		assert.Equal(t, "iiif", f.Check)
+		problems = append(problems, fmt.Sprintf("%s [%s] [%s] [%s]", f.Subject, f.Field, f.Expected, f.Actual))
+	}
+	assert.Equal(t, []string{
+		"media--image " + imageId + " [field_width, field_height] [100x80] [120x80]",
+		"media--image " + imageId + " [full/full/90/default.jpg] [80x120] [120x80]",
+		"media--image unserved-image [] [] []",
+	}, problems)
+	assert.Contains(t, report.Findings[2].Message, "404 status")
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Constrains the targets of a relationship field of a resource type
+type relationshipRule struct {
+	// the resource types the field may refer to; a type with an empty bundle (e.g. "node--") permits any bundle of
+	// the entity
+	targets []DrupalType
+	// true if the field must refer to at least one resource
+	required bool
+}
+
+// Answers true if the rule permits the field to refer to a resource of type t
+func (rule relationshipRule) permits(t DrupalType) bool {
+	for _, target := range rule.targets {
+		if target == t || (target.bundle() == "" && target.entity() == t.entity()) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	agentTargets    = []DrupalType{"taxonomy_term--corporate_body", "taxonomy_term--family", "taxonomy_term--person"}
+	languageTargets = []DrupalType{"taxonomy_term--language"}
+	accessTargets   = []DrupalType{"taxonomy_term--islandora_access"}
+	mediaUseTargets = []DrupalType{"taxonomy_term--islandora_media_use"}
+	fileTargets     = []DrupalType{"file--file"}
+	nodeTargets     = []DrupalType{"node--"}
+)
+
+// Fields common to every media with a file
+var mediaRelationshipRules = map[string]relationshipRule{
+	"field_media_of":     {nodeTargets, true},
+	"field_media_use":    {mediaUseTargets, false},
+	"field_access_terms": {accessTargets, false},
+	"thumbnail":          {fileTargets, false},
+}
+
+// The relationship rules of each resource type, following the field configuration in `codebase/config/sync`.  Even
+// though Drupal does not require `field_media_of`, a media that is not the media of any node is reported.
+var relationshipRules = map[DrupalType]map[string]relationshipRule{
+	"node--islandora_object": {
+		"field_access_rights":     {[]DrupalType{"taxonomy_term--access_rights"}, true},
+		"field_access_terms":      {accessTargets, false},
+		"field_contributor":       {agentTargets, false},
+		"field_copyright_and_use": {[]DrupalType{"taxonomy_term--copyright_and_use"}, true},
+		"field_copyright_holder":  {agentTargets, false},
+		"field_creator":           {agentTargets, false},
+		"field_digital_publisher": {agentTargets, false},
+		"field_display_hints":     {[]DrupalType{"taxonomy_term--islandora_display"}, false},
+		"field_genre":             {[]DrupalType{"taxonomy_term--genre"}, false},
+		"field_language":          {languageTargets, false},
+		"field_member_of":         {[]DrupalType{"node--collection_object", "node--islandora_object"}, false},
+		"field_model":             {[]DrupalType{"taxonomy_term--islandora_models"}, true},
+		"field_publisher":         {agentTargets, false},
+		"field_publisher_country": {[]DrupalType{"taxonomy_term--geo_location"}, false},
+		"field_resource_type":     {[]DrupalType{"taxonomy_term--resource_types"}, true},
+		"field_spatial_coverage":  {[]DrupalType{"taxonomy_term--geo_location"}, false},
+		"field_subject": {[]DrupalType{"taxonomy_term--corporate_body", "taxonomy_term--family",
+			"taxonomy_term--geo_location", "taxonomy_term--person", "taxonomy_term--subject"}, true},
+		"field_title_language": {languageTargets, false},
+	},
+	"node--collection_object": {
+		"field_access_terms":   {accessTargets, false},
+		"field_member_of":      {[]DrupalType{"node--collection_object"}, false},
+		"field_model":          {[]DrupalType{"taxonomy_term--islandora_models"}, false},
+		"field_title_language": {languageTargets, false},
+	},
+	"media--audio":                   withRules(mediaRelationshipRules, "field_media_audio_file"),
+	"media--document":                withRules(mediaRelationshipRules, "field_media_document"),
+	"media--extracted_text":          withRules(mediaRelationshipRules, "field_media_file"),
+	"media--file":                    withRules(mediaRelationshipRules, "field_media_file"),
+	"media--fits_technical_metadata": withRules(mediaRelationshipRules, "field_media_file"),
+	"media--image":                   withRules(mediaRelationshipRules, "field_media_image"),
+	"media--video":                   withRules(mediaRelationshipRules, "field_media_video_file"),
+	"media--remote_video": {
+		"field_access_terms": {accessTargets, false},
+		"thumbnail":          {fileTargets, false},
+	},
+	"taxonomy_term--corporate_body": {"field_relationships": {agentTargets, false}},
+	"taxonomy_term--family":         {"field_relationships": {agentTargets, false}},
+	"taxonomy_term--person":         {"field_relationships": {agentTargets, false}},
+}
+
+// Answers a copy of the media rules, adding a required relationship from fileField to a file
+func withRules(base map[string]relationshipRule, fileField string) map[string]relationshipRule {
+	rules := make(map[string]relationshipRule, len(base)+1)
+	for k, v := range base {
+		rules[k] = v
+	}
+	rules[fileField] = relationshipRule{fileTargets, true}
+	return rules
+}
+
+// Relationships that refer to configuration (like the bundle of a resource) or to user accounts rather than to
+// repository content, and which are not followed
+var ignoredRelationships = map[string]bool{
+	"node_type":     true,
+	"media_type":    true,
+	"vid":           true,
+	"uid":           true,
+	"revision_uid":  true,
+	"revision_user": true,
+}
+
+const (
+	// The id JSONAPI answers for the target of a reference to an entity that has been deleted
+	missingId = "missing"
+	// The id JSONAPI answers for the parent of a root taxonomy term
+	virtualId = "virtual"
+)
+
+// The resources crawled by an audit, indexed by id
+type crawlIndex struct {
+	crawled map[DrupalType][]JsonApiResource
+	byId    map[string]*JsonApiResource
+}
+
+func newCrawlIndex(crawled map[DrupalType][]JsonApiResource) *crawlIndex {
+	idx := &crawlIndex{crawled: crawled, byId: make(map[string]*JsonApiResource)}
+	for _, resources := range crawled {
+		for i := range resources {
+			idx.byId[resources[i].Id] = &resources[i]
+		}
+	}
+	return idx
+}
+
+// Follows every relationship of the crawled resources, reporting dangling references (references to deleted or
+// inaccessible resources), references to resources of the wrong type, and empty required relationships.
+func (c *jsonApiClient) checkIntegrity(idx *crawlIndex, report *Report) {
+	for _, t := range sortedTypes(idx.crawled) {
+		rules := relationshipRules[t]
+		var ruleFields []string
+		for field := range rules {
+			ruleFields = append(ruleFields, field)
+		}
+		sort.Strings(ruleFields)
+		resources := idx.crawled[t]
+		for i := range resources {
+			res := &resources[i]
+
+			for _, field := range ruleFields {
+				if rules[field].required && len(res.related(field)) == 0 {
+					report.add(Finding{
+						Level:   LevelError,
+						Check:   "integrity",
+						Subject: res.String(),
+						Field:   field,
+						Message: fmt.Sprintf("required relationship of '%s' is empty", res.label()),
+					})
+				}
+			}
+
+			for _, field := range res.relationshipNames() {
+				if ignoredRelationships[field] {
+					continue
+				}
+				for _, target := range res.related(field) {
+					c.checkReference(idx, res, field, target, report)
+				}
+			}
+		}
+	}
+}
+
+// Checks a single reference from res to target
+func (c *jsonApiClient) checkReference(idx *crawlIndex, res *JsonApiResource, field string, target RelData, report *Report) {
+	finding := Finding{
+		Level:   LevelError,
+		Check:   "integrity",
+		Subject: res.String(),
+		Field:   field,
+		Actual:  fmt.Sprintf("%s %s", target.Type, target.Id),
+	}
+
+	if rule, ok := relationshipRules[res.Type][field]; ok && !rule.permits(target.Type) {
+		finding.Expected = joinTypes(rule.targets)
+		finding.Message = fmt.Sprintf("'%s' refers to a %s", res.label(), target.Type)
+		report.add(finding)
+	}
+
+	if dangling := c.dangling(idx, target); dangling != "" {
+		finding.Expected = ""
+		finding.Message = fmt.Sprintf("'%s' refers to %s", res.label(), dangling)
+		report.add(finding)
+	}
+}
+
+// Answers why the target of a reference is dangling (e.g. "a deleted file--file"), or the empty string if the target
+// exists.  Targets of a type that was crawled are looked up among the crawled resources; any other target is
+// resolved using the client.
+func (c *jsonApiClient) dangling(idx *crawlIndex, target RelData) string {
+	switch {
+	case target.Id == virtualId:
+		return ""
+	case target.Id == missingId:
+		return fmt.Sprintf("a deleted %s", target.Type)
+	}
+
+	if _, crawledType := idx.crawled[target.Type]; crawledType {
+		if _, found := idx.byId[target.Id]; !found {
+			return fmt.Sprintf("a %s that does not exist, or is not accessible", target.Type)
+		}
+		return ""
+	}
+
+	if !strings.Contains(string(target.Type), "--") {
+		return ""
+	}
+	_, err := c.resolve(target.JsonApiData)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return fmt.Sprintf("a %s that does not exist", target.Type)
+	case errors.Is(err, ErrForbidden):
+		return fmt.Sprintf("a %s that is not accessible", target.Type)
+	case err != nil:
+		return fmt.Sprintf("a %s that could not be resolved: %s", target.Type, err)
+	}
+	return ""
+}
+
+func joinTypes(types []DrupalType) string {
+	s := make([]string, len(types))
+	for i, t := range types {
+		s[i] = string(t)
+		if t.bundle() == "" {
+			s[i] = t.entity()
+		}
+	}
+	return strings.Join(s, " | ")
+}
+
+// Answers the crawled resource types in order
+func sortedTypes(crawled map[DrupalType][]JsonApiResource) []DrupalType {
+	types := make([]DrupalType, 0, len(crawled))
+	for t := range crawled {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// Answers the names of the relationships of the resource in order
+func (res *JsonApiResource) relationshipNames() []string {
+	var names []string
+	for name := range res.Relationships {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Answers true if values contains v
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	objectId    = "3c5f8e5d-3f0c-4c6e-9f57-0c0e2d9f2a31"
+	modelId     = "a1d9f0a2-6d3a-4f4e-8c3b-7e2f1b0c9d42"
+	imageId     = "9e2b7c11-5a4d-4b8f-a3e6-1f0d2c3b4a53"
+	imageFileId = "c4e8a2f3-1b7d-4e9a-8f6c-5d3b2a1e0f64"
+	deletedId   = "00000000-0000-4000-8000-000000000000"
+)
+
+// Populates the fake with a repository item that is the member of a collection, and an image that is the media of
+// the repository item.  Every required relationship is present.
+func populateIntegrityFake(fake *fakeJsonApi) {
+	populateFake(fake)
+	fake.add(
+		fakeResource{
+			Type:       "taxonomy_term--islandora_models",
+			Id:         modelId,
+			Attributes: map[string]interface{}{"name": "Image"},
+			Relationships: map[string]interface{}{
+				"parent": []fakeRelationship{{Type: "taxonomy_term--islandora_models", Id: virtualId}},
+				"vid":    fakeRelationship{Type: "taxonomy_vocabulary--taxonomy_vocabulary", Id: "islandora_models"},
+			},
+		},
+		fakeResource{
+			Type:       "node--islandora_object",
+			Id:         objectId,
+			Attributes: map[string]interface{}{"title": "Repository Item One"},
+			Relationships: map[string]interface{}{
+				"field_model":             fakeRelationship{Type: "taxonomy_term--islandora_models", Id: modelId},
+				"field_member_of":         []fakeRelationship{{Type: "node--collection_object", Id: collectionId}},
+				"field_access_rights":     []fakeRelationship{{Type: "taxonomy_term--access_rights", Id: "access-rights"}},
+				"field_copyright_and_use": []fakeRelationship{{Type: "taxonomy_term--copyright_and_use", Id: "copyright"}},
+				"field_resource_type":     []fakeRelationship{{Type: "taxonomy_term--resource_types", Id: "resource-type"}},
+				"field_subject":           []fakeRelationship{{Type: "taxonomy_term--person", Id: personOneId}},
+				"uid":                     fakeRelationship{Type: "user--user", Id: "not-crawled-and-not-followed"},
+			},
+		},
+		fakeResource{Type: "taxonomy_term--access_rights", Id: "access-rights", Attributes: map[string]interface{}{"name": "Public"}},
+		fakeResource{Type: "taxonomy_term--copyright_and_use", Id: "copyright", Attributes: map[string]interface{}{"name": "In Copyright"}},
+		fakeResource{Type: "taxonomy_term--resource_types", Id: "resource-type", Attributes: map[string]interface{}{"name": "Image"}},
+		fakeResource{
+			Type:       "media--image",
+			Id:         imageId,
+			Attributes: map[string]interface{}{"name": "Image One"},
+			Relationships: map[string]interface{}{
+				"field_media_of":    []fakeRelationship{{Type: "node--islandora_object", Id: objectId}},
+				"field_media_image": fakeRelationship{Type: "file--file", Id: imageFileId, Meta: map[string]interface{}{"alt": "Alt"}},
+			},
+		},
+		fakeResource{Type: "file--file", Id: imageFileId, Attributes: map[string]interface{}{
+			"filename": "image.jpg",
+			"filesize": 1024,
+			"uri":      map[string]interface{}{"value": "private://2021-04/image.jpg", "url": "/system/files/2021-04/image.jpg"},
+		}},
+	)
+}
+
+func Test_CheckIntegrity_Consistent(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateIntegrityFake(fake)
+
+	report := newReport("audit", fake.URL)
+	newJsonApiClient(fake.URL, "", "").audit(report)
+
+	assert.Equal(t, 0, len(report.Findings), "unexpected findings: %v", report.Findings)
+	assert.Equal(t, 11, report.Checked)
+	assert.Equal(t, 1, report.Counts["media--image"])
+}
+
+func Test_CheckIntegrity_Inconsistent(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateIntegrityFake(fake)
+	fake.add(
+		// a repository item without a model, which is the member of a deleted collection and has a language as
+		// its subject
+		fakeResource{
+			Type:       "node--islandora_object",
+			Id:         "broken-object",
+			Attributes: map[string]interface{}{"title": "Broken Item"},
+			Relationships: map[string]interface{}{
+				"field_model":             nil,
+				"field_member_of":         []fakeRelationship{{Type: "node--collection_object", Id: deletedId}},
+				"field_access_rights":     []fakeRelationship{{Type: "taxonomy_term--access_rights", Id: "access-rights"}},
+				"field_copyright_and_use": []fakeRelationship{{Type: "taxonomy_term--copyright_and_use", Id: "copyright"}},
+				"field_resource_type":     []fakeRelationship{{Type: "taxonomy_term--resource_types", Id: "resource-type"}},
+				"field_subject":           []fakeRelationship{{Type: "taxonomy_term--language", Id: englishId}},
+			},
+		},
+		// an image which is the media of nothing, and whose file has been deleted
+		fakeResource{
+			Type:       "media--image",
+			Id:         "broken-image",
+			Attributes: map[string]interface{}{"name": "Broken Image"},
+			Relationships: map[string]interface{}{
+				"field_media_image": fakeRelationship{Type: "file--file", Id: missingId},
+			},
+		},
+	)
+
+	report := newReport("audit", fake.URL)
+	newJsonApiClient(fake.URL, "", "").audit(report)
+	assert.True(t, report.failed())
+
+	type problem struct{ subject, field, message string }
+	var problems []problem
+	for _, f := range report.Findings {
+		assert.Equal(t, "integrity", f.Check)
+		problems = append(problems, problem{f.Subject, f.Field, f.Message})
+	}
+
+	assert.Equal(t, []problem{
+		{"media--image broken-image", "field_media_of", "required relationship of 'Broken Image' is empty"},
+		{"media--image broken-image", "field_media_image", "'Broken Image' refers to a deleted file--file"},
+		{"node--islandora_object broken-object", "field_model", "required relationship of 'Broken Item' is empty"},
+		{"node--islandora_object broken-object", "field_member_of", "'Broken Item' refers to a node--collection_object that does not exist, or is not accessible"},
+		{"node--islandora_object broken-object", "field_subject", "'Broken Item' refers to a taxonomy_term--language"},
+	}, problems)
+}
+
+func Test_CheckIntegrity_ResolvesUncrawledTypes(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateIntegrityFake(fake)
+
+	// the access rights collection is not accessible, so its terms are resolved individually
+	fake.fail("/jsonapi/taxonomy_term/access_rights", http.StatusForbidden, "The current user is not allowed to GET the selected resource.")
+	fake.fail("/jsonapi/taxonomy_term/access_rights/access-rights", http.StatusNotFound, "The \"entity\" parameter was not converted")
+
+	report := newReport("audit", fake.URL)
+	newJsonApiClient(fake.URL, "", "").audit(report)
+
+	assert.Equal(t, 1, report.count(LevelWarning))
+	assert.Equal(t, 1, report.count(LevelError))
+	for _, f := range report.Findings {
+		if f.Level == LevelError {
+			assert.Equal(t, "field_access_rights", f.Field)
+			assert.Contains(t, f.Message, "does not exist")
+		}
+	}
+}
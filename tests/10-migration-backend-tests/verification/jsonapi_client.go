@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("resource not found")
+var ErrForbidden = errors.New("resource not accessible")
+
+// Performs JSONAPI requests on behalf of idc-verify.  Unlike JsonApiUrl, which asserts on failures for the benefit of
+// `go test`, the client answers errors so that they can be reported.
+type jsonApiClient struct {
+	baseUrl  string
+	username string
+	password string
+	http     *http.Client
+	// downloads files, which may take longer than the timeout of requests for JSONAPI documents
+	transfer *http.Client
+
+	mu       sync.Mutex
+	resolved map[string]*JsonApiResource
+}
+
+// Answers a client for the Drupal instance at baseUrl.  If username is not empty, requests are authenticated using
+// HTTP basic authentication.
+func newJsonApiClient(baseUrl, username, password string) *jsonApiClient {
+	return &jsonApiClient{
+		baseUrl:  strings.TrimSuffix(baseUrl, "/"),
+		username: username,
+		password: password,
+		http:     &http.Client{Timeout: 60 * time.Second},
+		transfer: &http.Client{Timeout: 5 * time.Minute},
+		resolved: make(map[string]*JsonApiResource),
+	}
+}
+
+// A JSONAPI resource of any type, for code that operates on resources generically rather than through one of the
+// bundle-specific types like JsonApiPerson.
+type JsonApiResource struct {
+	Type          DrupalType
+	Id            string
+	Attributes    map[string]interface{}
+	Relationships map[string]JsonApiRelationship
+}
+
+// A relationship of a JsonApiResource.  To-one and to-many relationships are both represented by a slice, which is
+// empty if the relationship has no value.
+type JsonApiRelationship struct {
+	Data []RelData
+}
+
+func (rel *JsonApiRelationship) UnmarshalJSON(b []byte) error {
+	raw := struct {
+		Data json.RawMessage
+	}{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	data := strings.TrimSpace(string(raw.Data))
+	switch {
+	case data == "" || data == "null":
+		rel.Data = []RelData{}
+	case strings.HasPrefix(data, "["):
+		return json.Unmarshal(raw.Data, &rel.Data)
+	default:
+		rel.Data = make([]RelData, 1)
+		return json.Unmarshal(raw.Data, &rel.Data[0])
+	}
+	return nil
+}
+
+// Answers the human-readable label of the resource: the name of a taxonomy term or media, the title of a node, or
+// the filename of a file.
+func (res *JsonApiResource) label() string {
+	for _, key := range []string{"name", "title", "filename"} {
+		if v, ok := res.Attributes[key].(string); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// Answers the string value of an attribute, or the empty string if the attribute is absent or not a string
+func (res *JsonApiResource) attribute(name string) string {
+	v, _ := res.Attributes[name].(string)
+	return v
+}
+
+// Answers the targets of the named relationship, which is empty if the resource has no such relationship
+func (res *JsonApiResource) related(field string) []RelData {
+	return res.Relationships[field].Data
+}
+
+// Answers the path of the resource relative to the Drupal base URL, e.g. "node/12", "media/3" or "taxonomy/term/5",
+// or the empty string if the resource has no internal id.  Drupal uses this path to identify the entity in RDF.
+func (res *JsonApiResource) entityPath() string {
+	for _, p := range []struct{ attribute, path string }{
+		{"drupal_internal__nid", "node"},
+		{"drupal_internal__mid", "media"},
+		{"drupal_internal__tid", "taxonomy/term"},
+		{"drupal_internal__fid", "file"},
+	} {
+		if id := scalarString(res.Attributes[p.attribute]); id != "" {
+			return p.path + "/" + id
+		}
+	}
+	return ""
+}
+
+// Identifies the resource, e.g. "taxonomy_term--person 0e3c4f0e-4a3c-4c1e-9cbb-5d8a4b1f5d11"
+func (res *JsonApiResource) String() string {
+	return fmt.Sprintf("%s %s", res.Type, res.Id)
+}
+
+// Performs an (optionally authenticated) GET request for the JSONAPI document at the URL.  The caller is responsible
+// for closing the response body.
+func (c *jsonApiClient) request(u string) (*http.Response, error) {
+	req, err := c.newRequest(u, "application/vnd.api+json")
+	if err != nil {
+		return nil, err
+	}
+	return c.http.Do(req)
+}
+
+// Answers an (optionally authenticated) GET request for the URL, accepting the supplied media type
+func (c *jsonApiClient) newRequest(u, accept string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	return req, nil
+}
+
+// Answers an HTTP client for the pages of Drupal (e.g. views and administrative pages), which are not accessible using
+// HTTP basic authentication.  If the client has a username, a session is established by logging in, and the cookie
+// identifying the session is sent with every request.
+func (c *jsonApiClient) session(timeout time.Duration) (*http.Client, error) {
+	jar, _ := cookiejar.New(nil)
+	browser := &http.Client{Jar: jar, Timeout: timeout}
+	if c.username == "" {
+		return browser, nil
+	}
+
+	login, _ := json.Marshal(map[string]string{"name": c.username, "pass": c.password})
+	res, err := browser.Post(c.baseUrl+"/user/login?_format=json", "application/json", bytes.NewReader(login))
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %d status encountered logging in as %s", ErrForbidden, res.StatusCode, c.username)
+	}
+	return browser, nil
+}
+
+// Streams the content at the URL to w, answering the number of bytes written.  A relative URL (e.g. the `url` of a
+// file uri, "/system/files/...") is resolved against the base URL of the client.
+func (c *jsonApiClient) download(u string, w io.Writer) (int64, error) {
+	if strings.HasPrefix(u, "/") {
+		u = c.baseUrl + u
+	}
+
+	req, err := c.newRequest(u, "*/*")
+	if err != nil {
+		return 0, err
+	}
+	res, err := c.transfer.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return 0, fmt.Errorf("%w: %s", ErrNotFound, u)
+	case res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusUnauthorized:
+		return 0, fmt.Errorf("%w: %s", ErrForbidden, u)
+	case res.StatusCode != http.StatusOK:
+		return 0, fmt.Errorf("%d status encountered when requesting %s", res.StatusCode, u)
+	}
+
+	n, err := io.Copy(w, res.Body)
+	if err != nil {
+		return n, fmt.Errorf("error reading %s: %w", u, err)
+	}
+	return n, nil
+}
+
+// Answers the JSONAPI document at the URL
+func (c *jsonApiClient) document(u string) (*JsonApiResponse, error) {
+	res, err := c.request(u)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body from %s: %w", u, err)
+	}
+
+	doc := &JsonApiResponse{}
+	err = json.Unmarshal(body, doc)
+
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s (%v)", ErrNotFound, u, err)
+	case res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusUnauthorized:
+		return nil, fmt.Errorf("%w: %s (%v)", ErrForbidden, u, err)
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%d status encountered when requesting %s (%v)", res.StatusCode, u, err)
+	case err != nil:
+		return nil, fmt.Errorf("error unmarshaling JSONAPI response body from %s: %w", u, err)
+	}
+
+	return doc, nil
+}
+
+// Answers every resource in the collection at the URL, following pagination links to the last page
+func (c *jsonApiClient) collection(u string) ([]JsonApiResource, error) {
+	var resources []JsonApiResource
+
+	for next := u; next != ""; {
+		doc, err := c.document(next)
+		if err != nil {
+			return resources, err
+		}
+
+		page := []JsonApiResource{}
+		if b, err := json.Marshal(doc.Data); err != nil {
+			return resources, err
+		} else if err := json.Unmarshal(b, &page); err != nil {
+			return resources, fmt.Errorf("error unmarshaling JSONAPI resources from %s: %w", next, err)
+		}
+		resources = append(resources, page...)
+
+		if doc.Next == next {
+			break
+		}
+		next = doc.Next
+	}
+
+	return resources, nil
+}
+
+// Answers the resources of the given type whose filter field is equal to value
+func (c *jsonApiClient) find(t DrupalType, filter, value string) ([]JsonApiResource, error) {
+	u, err := composeJsonApiUrl(c.baseUrl, t.entity(), t.bundle(), filter, value)
+	if err != nil {
+		return nil, err
+	}
+	return c.collection(u)
+}
+
+// Answers the resource that is the target of a relationship.  Resolved resources are cached for the lifetime of the
+// client.  If the target does not exist, the error wraps ErrNotFound.
+func (c *jsonApiClient) resolve(target JsonApiData) (*JsonApiResource, error) {
+	c.mu.Lock()
+	cached, ok := c.resolved[target.Id]
+	c.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	u, err := composeJsonApiUrl(c.baseUrl, target.Type.entity(), target.Type.bundle(), "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := c.document(fmt.Sprintf("%s/%s", u, target.Id))
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Data) != 1 {
+		return nil, fmt.Errorf("%w: %s %s", ErrNotFound, target.Type, target.Id)
+	}
+
+	res := &JsonApiResource{}
+	if b, err := json.Marshal(doc.Data[0]); err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(b, res); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.resolved[target.Id] = res
+	c.mu.Unlock()
+	return res, nil
+}
+
+// Answers the resource types (e.g. "node--islandora_object") advertised by the JSONAPI entry point
+func (c *jsonApiClient) resourceTypes() ([]DrupalType, error) {
+	res, err := c.request(c.baseUrl + "/jsonapi")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%d status encountered when requesting %s/jsonapi", res.StatusCode, c.baseUrl)
+	}
+
+	index := struct {
+		Links map[string]json.RawMessage
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&index); err != nil {
+		return nil, fmt.Errorf("error unmarshaling the JSONAPI entry point: %w", err)
+	}
+
+	var types []DrupalType
+	for name := range index.Links {
+		if strings.Contains(name, "--") {
+			types = append(types, DrupalType(name))
+		}
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types, nil
+}
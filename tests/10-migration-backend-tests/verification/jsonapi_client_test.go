@@ -0,0 +1,488 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Unit tests of the JSONAPI client code, performed against a fakeJsonApi rather than a running IDC stack.
+
+const (
+	personOneId   = "0e3c4f0e-4a3c-4c1e-9cbb-5d8a4b1f5d11"
+	personTwoId   = "5b3e1c6f-7f3a-4a3e-8b1e-2a9c7a7c0d22"
+	englishId     = "7397e0c4-df0a-4800-95af-afccc6ff64a5"
+	collectionId  = "815a4c04-0be5-44f1-a876-e8ddc11dcf21"
+	personOneName = "Adams, Ansel Easton, 1902-1984"
+	personTwoName = "Weston, Edward, 1886-1958"
+)
+
+// Records the failures reported by assertions so that tests can verify that the client fails (rather than panics)
+// when encountering bad input.
+type recordingT struct {
+	failures []string
+}
+
+func (rt *recordingT) Errorf(format string, args ...interface{}) {
+	rt.failures = append(rt.failures, fmt.Sprintf(format, args...))
+}
+
+// Answers true if any recorded failure contains the supplied string
+func (rt *recordingT) failedWith(s string) bool {
+	for _, f := range rt.failures {
+		if strings.Contains(f, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Populates the fake with two persons who know each other, a language, and a collection
+func populateFake(fake *fakeJsonApi) {
+	fake.add(
+		fakeResource{
+			Type: "taxonomy_term--person",
+			Id:   personOneId,
+			Attributes: map[string]interface{}{
+				"name":                       personOneName,
+				"field_primary_part_of_name": "Adams",
+				"field_preferred_name_rest":  []string{"Ansel Easton"},
+			},
+			Relationships: map[string]interface{}{
+				"field_relationships": []fakeRelationship{
+					{Type: "taxonomy_term--person", Id: personTwoId, Meta: map[string]interface{}{"rel_type": "schema:knows", "weight": 2}},
+				},
+			},
+		},
+		fakeResource{
+			Type: "taxonomy_term--person",
+			Id:   personTwoId,
+			Attributes: map[string]interface{}{
+				"name":                       personTwoName,
+				"field_primary_part_of_name": "Weston",
+			},
+			Relationships: map[string]interface{}{
+				"field_relationships": []fakeRelationship{
+					{Type: "taxonomy_term--person", Id: personOneId, Meta: map[string]interface{}{"rel_type": "schema:knows"}},
+				},
+			},
+		},
+		fakeResource{
+			Type: "taxonomy_term--language",
+			Id:   englishId,
+			Attributes: map[string]interface{}{
+				"name":                "English",
+				"field_language_code": "eng",
+			},
+		},
+		fakeResource{
+			Type: "node--collection_object",
+			Id:   collectionId,
+			Attributes: map[string]interface{}{
+				"title":                          "Test Collection One",
+				"field_collection_contact_email": "someone@example.org",
+			},
+			Relationships: map[string]interface{}{
+				"field_title_language": fakeRelationship{Type: "taxonomy_term--language", Id: englishId},
+				"field_alternative_title": []fakeRelationship{
+					{Type: "taxonomy_term--language", Id: englishId, Meta: map[string]interface{}{"value": "Alternate Title"}},
+				},
+				"field_member_of": nil,
+			},
+		},
+	)
+}
+
+func Test_JsonApiUrl_String(t *testing.T) {
+	u := &JsonApiUrl{
+		t:            t,
+		baseUrl:      "https://example.org",
+		drupalEntity: "taxonomy_term",
+		drupalBundle: "person",
+	}
+	assert.Equal(t, "https://example.org/jsonapi/taxonomy_term/person", u.String())
+
+	// a trailing slash on the base url is tolerated
+	u.baseUrl = "https://example.org/"
+	assert.Equal(t, "https://example.org/jsonapi/taxonomy_term/person", u.String())
+
+	u.filter = "name"
+	u.value = personOneName
+	assert.Equal(t, "https://example.org/jsonapi/taxonomy_term/person?filter[name]=Adams%2C+Ansel+Easton%2C+1902-1984", u.String())
+
+	// reserved characters in the filter value must not be interpreted as query or fragment delimiters
+	u.value = "Smith & Wesson #1"
+	assert.Equal(t, "https://example.org/jsonapi/taxonomy_term/person?filter[name]=Smith+%26+Wesson+%231", u.String())
+}
+
+func Test_JsonApiUrl_StringIncomplete(t *testing.T) {
+	rt := &recordingT{}
+	u := &JsonApiUrl{
+		t:            rt,
+		baseUrl:      "https://example.org",
+		drupalEntity: "taxonomy_term",
+	}
+	_ = u.String()
+	assert.True(t, rt.failedWith("drupal bundle must not be empty"), "failures: %v", rt.failures)
+
+	rt = &recordingT{}
+	u = &JsonApiUrl{
+		t:            rt,
+		drupalEntity: "taxonomy_term",
+		drupalBundle: "person",
+	}
+	_ = u.String()
+	assert.True(t, rt.failedWith("base url must not be empty"), "failures: %v", rt.failures)
+}
+
+func Test_JsonApiResponse_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		expected int
+		err      string
+	}{
+		{"collection", `{"data": [{"id": "1"}, {"id": "2"}]}`, 2, ""},
+		{"empty collection", `{"data": []}`, 0, ""},
+		{"single resource", `{"data": {"id": "1"}}`, 1, ""},
+		{"empty to-one relationship", `{"data": null}`, 0, ""},
+		{"included resources are ignored", `{"data": [{"id": "1"}], "included": [{"id": "2"}], "links": {"next": {"href": "x"}}}`, 1, ""},
+		{"missing data", `{"meta": {"count": 0}}`, 0, "missing 'data' key"},
+		{"scalar data", `{"data": "1"}`, 0, "unable to determine type of JSONAPI key 'data'"},
+		{"non-object element", `{"data": [{"id": "1"}, 2]}`, 0, "unable to determine type of JSONAPI 'data' element 1"},
+		{"error document", `{"errors": [{"status": "403", "title": "Forbidden", "detail": "The current user is not allowed to GET the selected resource."}]}`, 0, "403 Forbidden: The current user is not allowed"},
+		{"malformed", `{"data": [`, 0, "unexpected end of JSON input"},
+		{"not an object", `[]`, 0, "cannot unmarshal array"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := &JsonApiResponse{}
+			err := json.Unmarshal([]byte(test.doc), res)
+			if test.err == "" {
+				assert.Nil(t, err)
+				assert.Equal(t, test.expected, len(res.Data))
+			} else {
+				assert.NotNil(t, err)
+				assert.Contains(t, fmt.Sprintf("%v", err), test.err)
+			}
+		})
+	}
+
+	// error documents are identifiable
+	err := json.Unmarshal([]byte(`{"errors": [{"status": "404", "title": "Not Found"}]}`), &JsonApiResponse{})
+	assert.True(t, errors.Is(err, ErrJsonApi))
+}
+
+func Test_JsonApiUrl_GetSingle(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateFake(fake)
+
+	u := &JsonApiUrl{
+		t:            t,
+		baseUrl:      fake.URL,
+		drupalEntity: "taxonomy_term",
+		drupalBundle: "person",
+		filter:       "name",
+		value:        personOneName,
+	}
+
+	res := &JsonApiPerson{}
+	u.getSingle(res)
+
+	assert.Equal(t, 1, len(res.JsonApiData))
+	actual := res.JsonApiData[0]
+	assert.Equal(t, personOneId, actual.Id)
+	assert.Equal(t, "taxonomy_term", actual.Type.entity())
+	assert.Equal(t, "person", actual.Type.bundle())
+	assert.Equal(t, "Adams", actual.JsonApiAttributes.PrimaryPartOfName)
+	assert.Equal(t, []string{"Ansel Easton"}, actual.JsonApiAttributes.PreferredNameRest)
+	assert.Equal(t, 1, len(actual.JsonApiRelationships.Relationships.Data))
+	assert.Equal(t, "schema:knows", actual.JsonApiRelationships.Relationships.Data[0].Meta["rel_type"])
+
+	assert.Equal(t, []string{"/jsonapi/taxonomy_term/person?filter[name]=Adams%2C+Ansel+Easton%2C+1902-1984"}, fake.received())
+}
+
+func Test_JsonApiUrl_GetSingleMultipleResults(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateFake(fake)
+
+	rt := &recordingT{}
+	u := &JsonApiUrl{
+		t:            rt,
+		baseUrl:      fake.URL,
+		drupalEntity: "taxonomy_term",
+		drupalBundle: "person",
+	}
+
+	res := &JsonApiPerson{}
+	u.getSingle(res)
+	assert.True(t, rt.failedWith("Exactly one JSONAPI data element is expected in the response, but found 2 element(s)"), "failures: %v", rt.failures)
+
+	// get(...) places no constraint on the number of results
+	rt = &recordingT{}
+	u.t = rt
+	res = &JsonApiPerson{}
+	u.get(res)
+	assert.Empty(t, rt.failures)
+	assert.Equal(t, 2, len(res.JsonApiData))
+}
+
+func Test_JsonApiUrl_GetNoResults(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateFake(fake)
+
+	rt := &recordingT{}
+	u := &JsonApiUrl{
+		t:            rt,
+		baseUrl:      fake.URL,
+		drupalEntity: "taxonomy_term",
+		drupalBundle: "person",
+		filter:       "name",
+		value:        "Nobody",
+	}
+
+	res := &JsonApiPerson{}
+	u.getSingle(res)
+	assert.True(t, rt.failedWith("but found 0 element(s)"), "failures: %v", rt.failures)
+	assert.Empty(t, res.JsonApiData)
+}
+
+func Test_JsonApiUrl_GetErrorDocument(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateFake(fake)
+	fake.fail("/jsonapi/node/islandora_object", http.StatusForbidden, "The current user is not allowed to GET the selected resource.")
+
+	rt := &recordingT{}
+	u := &JsonApiUrl{
+		t:            rt,
+		baseUrl:      fake.URL,
+		drupalEntity: "node",
+		drupalBundle: "islandora_object",
+		filter:       "title",
+		value:        "Sample Repository Item",
+	}
+
+	res := &JsonApiIslandoraObj{}
+	u.getSingle(res)
+	assert.True(t, rt.failedWith("403 status encountered"), "failures: %v", rt.failures)
+	assert.True(t, rt.failedWith("403 Forbidden: The current user is not allowed to GET the selected resource."), "failures: %v", rt.failures)
+	assert.Empty(t, res.JsonApiData)
+
+	// unknown bundles are answered with a 404 error document
+	rt = &recordingT{}
+	u.t = rt
+	u.drupalBundle = "no_such_bundle"
+	u.getSingle(res)
+	assert.True(t, rt.failedWith("404 status encountered"), "failures: %v", rt.failures)
+	assert.True(t, rt.failedWith("404 Not Found"), "failures: %v", rt.failures)
+}
+
+func Test_JsonApiUrl_GetMalformedResponse(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	fake.respond("/jsonapi/taxonomy_term/person", http.StatusOK, `{"data": [{"type": "taxonomy_term--person", "id": `)
+
+	rt := &recordingT{}
+	u := &JsonApiUrl{
+		t:            rt,
+		baseUrl:      fake.URL,
+		drupalEntity: "taxonomy_term",
+		drupalBundle: "person",
+	}
+
+	res := &JsonApiPerson{}
+	u.get(res)
+	assert.True(t, rt.failedWith("Error unmarshaling JSONAPI response body"), "failures: %v", rt.failures)
+	assert.Empty(t, res.JsonApiData)
+}
+
+func Test_JsonApiUrl_GetUnreachable(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	baseUrl := fake.URL
+	fake.Close()
+
+	rt := &recordingT{}
+	u := &JsonApiUrl{
+		t:            rt,
+		baseUrl:      baseUrl,
+		drupalEntity: "taxonomy_term",
+		drupalBundle: "person",
+	}
+
+	// a failure to connect must be reported, not panic
+	res := &JsonApiPerson{}
+	u.getSingle(res)
+	assert.True(t, rt.failedWith("encountered error requesting"), "failures: %v", rt.failures)
+	assert.Empty(t, res.JsonApiData)
+}
+
+func Test_JsonApiData_Resolve(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateFake(fake)
+
+	rel := JsonApiData{Type: "taxonomy_term--person", Id: personTwoId}
+	res := &JsonApiPerson{}
+	rel.resolve(t, res)
+
+	assert.Equal(t, 1, len(res.JsonApiData))
+	assert.Equal(t, personTwoName, res.JsonApiData[0].JsonApiAttributes.Name)
+	assert.Equal(t, personOneId, res.JsonApiData[0].JsonApiRelationships.Relationships.Data[0].Id)
+	assert.Equal(t, []string{"/jsonapi/taxonomy_term/person?filter[id]=" + personTwoId}, fake.received())
+}
+
+func Test_JsonApiLanguageValue(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateFake(fake)
+
+	u := &JsonApiUrl{
+		t:            t,
+		baseUrl:      fake.URL,
+		drupalEntity: "node",
+		drupalBundle: "collection_object",
+		filter:       "title",
+		value:        "Test Collection One",
+	}
+
+	res := &JsonApiCollection{}
+	u.getSingle(res)
+	relData := res.JsonApiData[0].JsonApiRelationships
+
+	assert.Equal(t, "eng", relData.TitleLanguage.Data.langCode(t))
+	assert.Equal(t, 1, len(relData.AltTitle.Data))
+	assert.Equal(t, "Alternate Title", relData.AltTitle.Data[0].value())
+	assert.Equal(t, "eng", relData.AltTitle.Data[0].langCode(t))
+	assert.Equal(t, LanguageString{Value: "Alternate Title", LangCode: "eng"}, relData.AltTitle.Data[0].languageString(t))
+
+	// a language term that does not resolve fails the test rather than answering no code
+	fake.remove(relData.AltTitle.Data[0].Id)
+	rt := &recordingT{}
+	assert.Equal(t, "", relData.AltTitle.Data[0].langCode(rt))
+	assert.True(t, rt.failedWith(relData.AltTitle.Data[0].Id), "failures: %v", rt.failures)
+
+	// an empty relationship (`"data": null`) unmarshals to no values
+	assert.Empty(t, relData.MemberOf.Data)
+}
+
+func Test_RelData_Meta(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateFake(fake)
+
+	u := &JsonApiUrl{
+		t:            t,
+		baseUrl:      fake.URL,
+		drupalEntity: "taxonomy_term",
+		drupalBundle: "person",
+		filter:       "id",
+		value:        personOneId,
+	}
+
+	// unmarshal the relationship meta through the client, as numbers in meta are decoded by encoding/json
+	res := &struct {
+		JsonApiData []struct {
+			JsonApiRelationships struct {
+				Relationships struct {
+					Data []RelData
+				} `json:"field_relationships"`
+			} `json:"relationships"`
+		} `json:"data"`
+	}{}
+	u.getSingle(res)
+	rd := res.JsonApiData[0].JsonApiRelationships.Relationships.Data[0]
+
+	relType, err := rd.metaString("rel_type")
+	assert.Nil(t, err)
+	assert.Equal(t, "schema:knows", relType)
+
+	weight, err := rd.metaInt("weight")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, weight)
+
+	_, err = rd.metaInt("rel_type")
+	assert.True(t, errors.Is(err, ErrConversion))
+
+	_, err = rd.metaString("weight")
+	assert.True(t, errors.Is(err, ErrConversion))
+
+	_, err = rd.metaInt("missing")
+	assert.True(t, errors.Is(err, ErrMissing))
+
+	_, err = rd.metaString("missing")
+	assert.True(t, errors.Is(err, ErrMissing))
+
+	// fractional numbers are not integers
+	rd.Meta["ratio"] = 1.5
+	_, err = rd.metaInt("ratio")
+	assert.True(t, errors.Is(err, ErrConversion))
+
+	rd.Meta["count"] = 3
+	count, err := rd.metaInt("count")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func Test_FakeJsonApi_Pagination(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	for i := 0; i < 5; i++ {
+		fake.add(fakeResource{
+			Type:       "taxonomy_term--subject",
+			Id:         fmt.Sprintf("subject-%d", i),
+			Attributes: map[string]interface{}{"name": fmt.Sprintf("Subject %d", i)},
+		})
+	}
+
+	var (
+		ids  []string
+		next = fake.URL + "/jsonapi/taxonomy_term/subject?page[limit]=2"
+	)
+	for pages := 0; next != ""; pages++ {
+		assert.True(t, pages < 3, "too many pages")
+		res, body := getResource(t, next)
+		assert.NotNil(t, res)
+
+		doc := struct {
+			Data []struct {
+				Id string
+			}
+			Links struct {
+				Next struct {
+					Href string
+				}
+			}
+		}{}
+		assert.Nil(t, json.Unmarshal(body, &doc))
+		for _, d := range doc.Data {
+			ids = append(ids, d.Id)
+		}
+		next = doc.Links.Next.Href
+	}
+
+	assert.Equal(t, []string{"subject-0", "subject-1", "subject-2", "subject-3", "subject-4"}, ids)
+}
+
+func Test_FakeJsonApi_Include(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateFake(fake)
+
+	res, body := getResource(t, fake.URL+"/jsonapi/taxonomy_term/person/"+personOneId+"?include=field_relationships")
+	assert.NotNil(t, res)
+
+	doc := struct {
+		Data struct {
+			Id string
+		}
+		Included []struct {
+			Id         string
+			Attributes map[string]interface{}
+		}
+	}{}
+	assert.Nil(t, json.Unmarshal(body, &doc))
+	assert.Equal(t, personOneId, doc.Data.Id)
+	assert.Equal(t, 1, len(doc.Included))
+	assert.Equal(t, personTwoId, doc.Included[0].Id)
+	assert.Equal(t, personTwoName, doc.Included[0].Attributes["name"])
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// The base URL of the test instance of IDC.
+// TODO: consult env
+const DrupalBaseurl = "https://islandora-idc.traefik.me"
+
+// Encapsulates the entity type and bundle of a Drupal resource.
+//
+// DrupalType is parsed from the JSONAPI response, where type is represented, e.g. as:
+//   "type": "taxonomy_term--person"
+type DrupalType string
+
+// The entity (e.g. taxonomy_term, node, etc) encapsulated by this type
+func (t DrupalType) entity() string {
+	return strings.Split(string(t), "--")[0]
+}
+
+// The bundle (e.g. 'person', 'islandora_object', etc) encapsulated by this type
+func (t DrupalType) bundle() string {
+	return strings.Split(string(t), "--")[1]
+}
+
+// Composes the JSONAPI URL of the resources of the entity and bundle, filtered by the value of the filter field if a
+// filter is supplied
+func composeJsonApiUrl(baseUrl, entity, bundle, filter, value string) (string, error) {
+	switch {
+	case baseUrl == "":
+		return "", errors.New("base url must not be empty")
+	case entity == "":
+		return "", errors.New("drupal entity must not be empty")
+	case bundle == "":
+		return "", errors.New("drupal bundle must not be empty")
+	}
+
+	u, err := url.Parse(strings.Join([]string{strings.TrimSuffix(baseUrl, "/"), "jsonapi", entity, bundle}, "/"))
+	if err != nil {
+		return "", err
+	}
+
+	// the filter value is query-escaped so that values containing reserved characters (e.g. '&' or '#') are
+	// sent to Drupal intact
+	if filter != "" {
+		u.RawQuery = fmt.Sprintf("filter[%s]=%s", filter, url.QueryEscape(value))
+	}
+
+	return u.String(), nil
+}
+
+// Encapsulates a generic JSON API response
+type JsonApiResponse struct {
+	Data []map[string]interface{}
+	// The URL of the next page of a paginated collection, empty if there is no next page
+	Next string `json:"-"`
+	// Resources included in the response by the `include` query parameter
+	Included []map[string]interface{} `json:"-"`
+}
+
+// Handles the case where the 'data' key contains an array of objects, or a single object.
+func (jar *JsonApiResponse) UnmarshalJSON(b []byte) error {
+	fullRes := make(map[string]interface{})
+
+	if err := json.Unmarshal(b, &fullRes); err != nil {
+		return err
+	}
+
+	// JSONAPI error documents carry an 'errors' member instead of 'data'
+	if e, ok := fullRes["errors"]; ok {
+		return fmt.Errorf("%w: %s", ErrJsonApi, jsonApiErrors(e))
+	}
+
+	if links, ok := fullRes["links"].(map[string]interface{}); ok {
+		if next, ok := links["next"].(map[string]interface{}); ok {
+			jar.Next, _ = next["href"].(string)
+		}
+	}
+
+	if included, ok := fullRes["included"].([]interface{}); ok {
+		for _, v := range included {
+			if obj, ok := v.(map[string]interface{}); ok {
+				jar.Included = append(jar.Included, obj)
+			}
+		}
+	}
+
+	if e, ok := fullRes["data"]; !ok {
+		return fmt.Errorf("missing 'data' key when unmarshaling JSONAPI response: %v", e)
+	} else {
+		switch e.(type) {
+		case []interface{}:
+			jar.Data = make([]map[string]interface{}, len(e.([]interface{})))
+			for i, v := range e.([]interface{}) {
+				if obj, ok := v.(map[string]interface{}); ok {
+					jar.Data[i] = obj
+				} else {
+					return fmt.Errorf("unable to determine type of JSONAPI 'data' element %d: %v", i, v)
+				}
+			}
+		case map[string]interface{}:
+			jar.Data = make([]map[string]interface{}, 1)
+			jar.Data[0] = e.(map[string]interface{})
+		case nil:
+			// an empty to-one relationship is represented as `"data": null`
+			jar.Data = []map[string]interface{}{}
+		default:
+			return fmt.Errorf("unable to determine type of JSONAPI key 'data': %v", e)
+		}
+	}
+	return nil
+}
+
+// Summarizes the members of a JSONAPI 'errors' array as a single string, e.g.:
+//   404 Not Found: The "entity" parameter was not converted for the path "/jsonapi/node/page/{entity}"
+func jsonApiErrors(e interface{}) string {
+	errs, ok := e.([]interface{})
+	if !ok {
+		return fmt.Sprintf("%v", e)
+	}
+
+	var summary []string
+	for _, err := range errs {
+		obj, ok := err.(map[string]interface{})
+		if !ok {
+			summary = append(summary, fmt.Sprintf("%v", err))
+			continue
+		}
+		msg := strings.TrimSpace(fmt.Sprintf("%v %v", obj["status"], obj["title"]))
+		if detail, ok := obj["detail"]; ok {
+			msg = fmt.Sprintf("%s: %v", msg, detail)
+		}
+		summary = append(summary, msg)
+	}
+
+	return strings.Join(summary, "; ")
+}
+
+type JsonApiData struct {
+	Type DrupalType
+	Id   string
+}
+
+type RelData struct {
+	JsonApiData
+	Meta map[string]interface{}
+}
+
+var ErrConversion = errors.New("cannot convert type")
+var ErrMissing = errors.New("missing field from meta")
+var ErrJsonApi = errors.New("JSONAPI error response")
+
+func (rd RelData) metaString(field string) (string, error) {
+	if value, exists := rd.Meta[field]; exists {
+		if strValue, ok := value.(string); ok {
+			return strValue, nil
+		} else {
+			return "", fmt.Errorf("%w: %v to string", ErrConversion, value)
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrMissing, field)
+}
+
+func (rd RelData) metaInt(field string) (int, error) {
+	if value, exists := rd.Meta[field]; exists {
+		switch v := value.(type) {
+		case int:
+			return v, nil
+		case float64:
+			// encoding/json unmarshals every JSON number held by an interface{} as a float64
+			if v == float64(int(v)) {
+				return int(v), nil
+			}
+		}
+		return -1, fmt.Errorf("%w: %v to int", ErrConversion, value)
+	}
+
+	return -1, fmt.Errorf("%w: %s", ErrMissing, field)
+}
@@ -2,16 +2,17 @@ package main
 
 import (
 	"encoding/json"
-	"errors"
-	"fmt"
+	"io/ioutil"
 	"log"
-	"net/url"
-	"strings"
-	"testing"
+	"net/http"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// The base URL used to resolve relationships between JSONAPI resources.  Unit tests point this at a fake JSONAPI
+// server.
+var resolveBaseurl = DrupalBaseurl
+
 // Encapsulates the relevant components of a URL which executes a JSON API request against Drupal
 type JsonApiUrl struct {
 	t            assert.TestingT
@@ -24,72 +25,36 @@ type JsonApiUrl struct {
 
 // Compose and return the JSONAPI URL
 func (json *JsonApiUrl) String() string {
-	var u *url.URL
-	var err error
-
-	assert.NotEmpty(json.t, json.baseUrl, "error generating a JsonAPI URL from %v: %s", json, "base url must not be empty")
-	assert.NotEmpty(json.t, json.drupalEntity, "error generating a JsonAPI URL from %v: %s", json, "drupal entity must not be empty")
-	assert.NotEmpty(json.t, json.drupalBundle, "error generating a JsonAPI URL from %v: %s", json, "drupal bundle must not be empty")
-
-	u, err = url.Parse(fmt.Sprintf("%s", strings.Join([]string{DrupalBaseurl, "jsonapi", json.drupalEntity, json.drupalBundle}, "/")))
-	assert.Nil(json.t, err, "error generating a JsonAPI URL from %v: %s", json, err)
-
-	if json.filter != "" {
-		u, err = url.Parse(fmt.Sprintf("%s?filter[%s]=%s", u.String(), json.filter, json.value))
-	}
+	u, err := json.compose()
+	assert.Nil(json.t, err, "error generating a JsonAPI URL from %v: %s", *json, err)
+	return u
+}
 
-	assert.Nil(json.t, err, "error generating a JsonAPI URL from %v: %s", json, err)
-	return u.String()
+// Compose the JSONAPI URL, answering an error if it cannot be composed.  Unlike String(), no assertions are made.
+func (json *JsonApiUrl) compose() (string, error) {
+	return composeJsonApiUrl(json.baseUrl, json.drupalEntity, json.drupalBundle, json.filter, json.value)
 }
 
 // Get the JSON API content from the URL and unmarshal the response into the supplied interface (which must be a
 // pointer).  This method asserts that there is a single object in the `data` element of the JSON response.
 func (jar *JsonApiUrl) getSingle(v interface{}) {
 	// retrieve json of the migrated entity from the jsonapi and unmarshal the single response
-	res, body := getResource(jar.t.(*testing.T), jar.String())
-	defer func() { _ = res.Close }()
-	unmarshalSingleResponse(jar.t.(*testing.T), body, res, &JsonApiResponse{}).to(v)
+	res, body := getResource(jar.t, jar.String())
+	if res == nil {
+		return
+	}
+	unmarshalSingleResponse(jar.t, body, res, &JsonApiResponse{}).to(v)
 }
 
 // Get the JSON API content from the URL and unmarshal the response into the supplied interface (which must be a
 // pointer).
 func (jar *JsonApiUrl) get(v interface{}) {
 	// retrieve json of the migrated entity from the jsonapi and unmarshal the single response
-	res, body := getResource(jar.t.(*testing.T), jar.String())
-	defer func() { _ = res.Close }()
-	unmarshalResponse(jar.t.(*testing.T), body, res, &JsonApiResponse{}, nil).to(v)
-}
-
-// Encapsulates a generic JSON API response
-type JsonApiResponse struct {
-	Data []map[string]interface{}
-}
-
-// Handles the case where the 'data' key contains an array of objects, or a single object.
-func (jar *JsonApiResponse) UnmarshalJSON(b []byte) error {
-	fullRes := make(map[string]interface{})
-
-	if err := json.Unmarshal(b, &fullRes); err != nil {
-		return err
-	}
-
-	if e, ok := fullRes["data"]; !ok {
-		return fmt.Errorf("missing 'data' key when unmarshaling JSONAPI response: %v", e)
-	} else {
-		switch e.(type) {
-		case []interface{}:
-			jar.Data = make([]map[string]interface{}, len(e.([]interface{})))
-			for i, v := range e.([]interface{}) {
-				jar.Data[i] = v.(map[string]interface{})
-			}
-		case map[string]interface{}:
-			jar.Data = make([]map[string]interface{}, 1)
-			jar.Data[0] = e.(map[string]interface{})
-		default:
-			return fmt.Errorf("unable to determine type of JSONAPI key 'data': %v", e)
-		}
+	res, body := getResource(jar.t, jar.String())
+	if res == nil {
+		return
 	}
-	return nil
+	unmarshalResponse(jar.t, body, res, &JsonApiResponse{}, nil).to(v)
 }
 
 // Adapts the generic JsonApiResponse to a higher-fidelity type
@@ -101,15 +66,10 @@ func (jar *JsonApiResponse) to(v interface{}) {
 	}
 }
 
-type JsonApiData struct {
-	Type DrupalType
-	Id   string
-}
-
-func (jad *JsonApiData) resolve(t *testing.T, v interface{}) {
+func (jad *JsonApiData) resolve(t assert.TestingT, v interface{}) {
 	u := JsonApiUrl{
 		t:            t,
-		baseUrl:      DrupalBaseurl,
+		baseUrl:      resolveBaseurl,
 		drupalEntity: jad.Type.entity(),
 		drupalBundle: jad.Type.bundle(),
 		filter:       "id",
@@ -568,12 +528,29 @@ type JsonApiLanguageValue struct {
 
 // Answers the language code of the value string by resolving the Language Taxonomy entity identified in the
 // JsonApiLanguageValue
-func (lv JsonApiLanguageValue) langCode(t *testing.T) string {
+func (lv JsonApiLanguageValue) langCode(t assert.TestingT) string {
 	jsonApiLang := JsonApiLanguage{}
 	lv.resolve(t, &jsonApiLang)
+	if !assert.Equal(t, 1, len(jsonApiLang.JsonApiData), "expected exactly one %s %s", lv.Type, lv.Id) {
+		return ""
+	}
 	return jsonApiLang.JsonApiData[0].JsonApiAttributes.LanguageCode
 }
 
+// Answers the value string along with its language code
+func (lv JsonApiLanguageValue) languageString(t assert.TestingT) LanguageString {
+	return LanguageString{Value: lv.value(), LangCode: lv.langCode(t)}
+}
+
+// Answers the value strings along with their language codes
+func languageStrings(t assert.TestingT, lvs []JsonApiLanguageValue) []LanguageString {
+	var values []LanguageString
+	for _, lv := range lvs {
+		values = append(values, lv.languageString(t))
+	}
+	return values
+}
+
 // Answers the value of the string, the language of which is provided by langCode(...)
 func (lv JsonApiLanguageValue) value() string {
 	return lv.Meta.Value
@@ -653,42 +630,10 @@ type JsonApiIslandoraDisplay struct {
 	} `json:"data"`
 }
 
-type RelData struct {
-	JsonApiData
-	Meta map[string]interface{}
-}
-
 type RelContributor struct {
 	Data []RelData
 }
 
-var ErrConversion = errors.New("cannot convert type")
-var ErrMissing = errors.New("missing field from meta")
-
-func (rd RelData) metaString(field string) (string, error) {
-	if value, exists := rd.Meta[field]; exists {
-		if strValue, ok := value.(string); ok {
-			return strValue, nil
-		} else {
-			return "", fmt.Errorf("%w: %v to string", ErrConversion, value)
-		}
-	}
-
-	return "", fmt.Errorf("%w: %s", ErrMissing, field)
-}
-
-func (rd RelData) metaInt(field string) (int, error) {
-	if value, exists := rd.Meta[field]; exists {
-		if intVal, ok := value.(int); ok {
-			return intVal, nil
-		} else {
-			return -1, fmt.Errorf("%w: %v to int", ErrConversion, value)
-		}
-	}
-
-	return -1, fmt.Errorf("%w: %s", ErrMissing, field)
-}
-
 // https://islandora-idc.traefik.me/jsonapi/media/image?filter[id]=090690a5-4db5-4d72-a94e-3b26a90b516b
 type JsonApiImageMedia struct {
 	JsonApiData []struct {
@@ -869,3 +814,36 @@ type JsonApiMediaUse struct {
 		} `json:"relationships"`
 	} `json:"data"`
 }
+
+// Unmarshal a JSONAPI response body and assert that exactly one data element is present
+func unmarshalSingleResponse(t assert.TestingT, body []byte, res *http.Response, value *JsonApiResponse) *JsonApiResponse {
+	unmarshalResponse(t, body, res, value, func(value *JsonApiResponse) {
+		assert.Equal(t, 1, len(value.Data), "Exactly one JSONAPI data element is expected in the response, but found %d element(s)", len(value.Data))
+	})
+	return value
+}
+
+// Unmarshal a JSONAPI response body and perform supplied assertions on the response
+func unmarshalResponse(t assert.TestingT, body []byte, res *http.Response, value *JsonApiResponse, responseAssertions func(res *JsonApiResponse)) *JsonApiResponse {
+	err := json.Unmarshal(body, value)
+	assert.Nil(t, err, "Error unmarshaling JSONAPI response body: %s", err)
+	if responseAssertions != nil {
+		responseAssertions(value)
+	}
+	return value
+}
+
+// Successfully GET the content at the URL and return the response and body.  If the request cannot be performed at
+// all, the failure is asserted and a nil response is returned.
+func getResource(t assert.TestingT, u string) (*http.Response, []byte) {
+	res, err := http.Get(u)
+	log.Printf("Retrieving %s", u)
+	if !assert.Nil(t, err, "encountered error requesting %s: %s", u, err) {
+		return nil, nil
+	}
+	defer res.Body.Close()
+	assert.Equal(t, 200, res.StatusCode, "%d status encountered when requesting %s", res.StatusCode, u)
+	body, err := ioutil.ReadAll(res.Body)
+	assert.Nil(t, err, "error encountered reading response body from %s: %s", u, err)
+	return res, body
+}
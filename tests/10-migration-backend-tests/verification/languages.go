@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The language vocabulary, whose terms tag language values with their field_language_code
+const languageType DrupalType = "taxonomy_term--language"
+
+// The ISO 639-2/B codes deprecated by the ISO 639-2 registration authority, with the codes replacing them
+var deprecatedLanguageCodes = map[string]string{
+	"mol": "rum", // Moldavian, deprecated in 2008
+	"scc": "srp", // Serbian, deprecated in 2008
+	"scr": "hrv", // Croatian, deprecated in 2008
+}
+
+var (
+	iso6393Once sync.Once
+	iso6393     map[string]bool
+)
+
+// Answers true if the code is an ISO 639-3 code
+func isIso6393(code string) bool {
+	iso6393Once.Do(func() {
+		iso6393 = make(map[string]bool)
+		for _, c := range strings.Fields(iso6393Codes) {
+			iso6393[c] = true
+		}
+	})
+	return iso6393[code]
+}
+
+// A string tagged with the language of its text, like an alternative title of a repository item.  In Drupal the
+// language is a taxonomy_term--language related by the value (in its meta), and in fixtures the code of the language,
+// e.g.
+//
+//   {"value": "Salida de la luna sobre Hernández", "language": "spa"}
+type LanguageString struct {
+	Value    string
+	LangCode string `json:"language"`
+}
+
+// Answers the value and its language, e.g. "Alternate Title (eng)"
+func (ls LanguageString) String() string {
+	return fmt.Sprintf("%s (%s)", ls.Value, ls.LangCode)
+}
+
+// Answers true if the language strings hold the same values with the same languages, irrespective of order
+func sameLanguageStrings(a, b []LanguageString) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sorted := func(values []LanguageString) []string {
+		var s []string
+		for _, ls := range values {
+			s = append(s, ls.String())
+		}
+		sort.Strings(s)
+		return s
+	}
+	sa, sb := sorted(a), sorted(b)
+	for i := range sa {
+		if sa[i] != sb[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Validates a language code against the ISO 639 tables, answering the level of the problem with it (LevelError or
+// LevelWarning) and a description, or the empty string if the code is valid.  The language vocabulary uses ISO 639-2/B
+// codes, but the codes of ISO 639-3 are valid too.  Codes that are unknown are errors, while deprecated codes, the
+// 639-2/T codes of languages with a different 639-2/B code, and codes reserved for local use are warnings.
+func languageCodeProblem(code string) (string, string) {
+	switch {
+	case code == "":
+		return LevelError, "has no language code"
+	case deprecatedLanguageCodes[code] != "":
+		return LevelWarning, fmt.Sprintf("has the deprecated language code '%s', which is replaced by '%s'", code, deprecatedLanguageCodes[code])
+	case iso6392T[code] != "":
+		return LevelWarning, fmt.Sprintf("has the ISO 639-2/T language code '%s' rather than the ISO 639-2/B code '%s'", code, iso6392T[code])
+	case iso6392B[code] != "" || isIso6393(code):
+		return "", ""
+	case len(code) == 3 && code >= "qaa" && code <= "qtz":
+		return LevelWarning, fmt.Sprintf("has the language code '%s', which is reserved for local use", code)
+	case code != strings.ToLower(code) && (iso6392B[strings.ToLower(code)] != "" || isIso6393(strings.ToLower(code))):
+		return LevelWarning, fmt.Sprintf("has the language code '%s', which should be lower case", code)
+	}
+	return LevelError, fmt.Sprintf("has the unknown language code '%s'", code)
+}
+
+// Answers the language strings of the language value field of a resource.  The language terms are resolved by the
+// client, which caches them, so each language is requested once.
+func (c *jsonApiClient) languageStrings(res *JsonApiResource, field string) ([]LanguageString, error) {
+	var values []LanguageString
+	for _, rel := range res.related(field) {
+		value, _ := rel.metaString("value")
+		term, err := c.resolve(rel.JsonApiData)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve the language of '%s': %w", value, err)
+		}
+		values = append(values, LanguageString{Value: value, LangCode: term.attribute("field_language_code")})
+	}
+	return values, nil
+}
+
+// Answers the fields of the resource type that are tagged with, or refer to, languages: the language value fields of
+// its fixtures, and the relationships to the language vocabulary, in alphabetical order
+func languageFields(t DrupalType) []string {
+	fields := make(map[string]bool)
+	for _, mapping := range fixtureFields[t] {
+		if mapping.kind == languageValueField {
+			fields[mapping.field] = true
+		}
+	}
+	for field, rule := range relationshipRules[t] {
+		if rule.permits(languageType) {
+			fields[field] = true
+		}
+	}
+	var names []string
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Verifies the language codes of the language vocabulary, and the languages of every node.  The field_language_code
+// of each language term must be a known ISO 639 code that is not deprecated (see languageCodeProblem), and no two
+// terms may have the same code.  Every value of a language value field (e.g. field_alternative_title) must be tagged
+// with a language, and it and every language referred to by a node (e.g. field_language) must have a valid code.
+func (c *jsonApiClient) verifyLanguages(report *Report) {
+	terms, err := c.collection(fmt.Sprintf("%s/jsonapi/%s/%s", c.baseUrl, languageType.entity(), languageType.bundle()))
+	if err != nil {
+		report.error("languages", string(languageType), "unable to retrieve the languages: %s", err)
+		return
+	}
+	byCode := make(map[string][]string)
+	for i := range terms {
+		term := &terms[i]
+		report.Counts["languages"]++
+		code := term.attribute("field_language_code")
+		byCode[code] = append(byCode[code], term.Id)
+		if level, problem := languageCodeProblem(code); level != "" {
+			report.add(Finding{
+				Level:   level,
+				Check:   "languages",
+				Subject: term.String(),
+				Field:   "field_language_code",
+				Actual:  code,
+				Message: fmt.Sprintf("the language '%s' %s", term.label(), problem),
+			})
+		}
+	}
+	var codes []string
+	for code := range byCode {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if ids := byCode[code]; code != "" && len(ids) > 1 {
+			report.error("languages", string(languageType), "%d languages have the code '%s', so values tagged with it are ambiguous: %s",
+				len(ids), code, strings.Join(ids, ", "))
+		}
+	}
+
+	crawled := c.crawl(report, "languages", map[string]bool{"node": true})
+	for _, t := range sortedTypes(crawled) {
+		fields := languageFields(t)
+		for i := range crawled[t] {
+			res := &crawled[t][i]
+			report.Checked++
+			for _, field := range fields {
+				c.verifyLanguageField(res, field, report)
+			}
+		}
+	}
+}
+
+// Verifies the languages of a field of a resource
+func (c *jsonApiClient) verifyLanguageField(res *JsonApiResource, field string, report *Report) {
+	fail := func(level, actual, format string, args ...interface{}) {
+		report.add(Finding{
+			Level:   level,
+			Check:   "languages",
+			Subject: res.String(),
+			Field:   field,
+			Actual:  actual,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, rel := range res.related(field) {
+		value, untagged := rel.metaString("value")
+		if rel.Id == missingId {
+			fail(LevelError, "", "a language of '%s' is missing", res.label())
+			continue
+		}
+		term, err := c.resolve(rel.JsonApiData)
+		if err != nil {
+			fail(LevelError, "", "unable to resolve the language %s of '%s': %s", rel.Id, res.label(), err)
+			continue
+		}
+		if term.Type != languageType {
+			fail(LevelError, string(term.Type), "'%s' refers to the %s '%s' rather than a language", res.label(), term.Type, term.label())
+			continue
+		}
+
+		code := term.attribute("field_language_code")
+		subject := fmt.Sprintf("the language '%s'", term.label())
+		if untagged == nil {
+			report.Counts["tagged values"]++
+			subject = fmt.Sprintf("the language of '%s'", value)
+		}
+		if level, problem := languageCodeProblem(code); level != "" {
+			fail(level, code, "%s of '%s' %s", subject, res.label(), problem)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeLanguage(id, name, code string) fakeResource {
+	return fakeTaxonomyTerm(languageType.bundle(), id, name, map[string]interface{}{"field_language_code": code})
+}
+
+func Test_LanguageCodeProblem(t *testing.T) {
+	for _, valid := range []string{"eng", "spa", "ger", "chi", "zxx", "und", "cmn", "yue"} {
+		level, problem := languageCodeProblem(valid)
+		assert.Equal(t, "", level, "%s %s", valid, problem)
+	}
+
+	for code, expected := range map[string]string{
+		"":    "error: has no language code",
+		"xyz": "error: has the unknown language code 'xyz'",
+		"en":  "error: has the unknown language code 'en'",
+		"scc": "warning: has the deprecated language code 'scc', which is replaced by 'srp'",
+		"mol": "warning: has the deprecated language code 'mol', which is replaced by 'rum'",
+		"deu": "warning: has the ISO 639-2/T language code 'deu' rather than the ISO 639-2/B code 'ger'",
+		"qab": "warning: has the language code 'qab', which is reserved for local use",
+		"ENG": "warning: has the language code 'ENG', which should be lower case",
+	} {
+		level, problem := languageCodeProblem(code)
+		assert.Equal(t, expected, level+": "+problem, code)
+	}
+
+	// the range of codes reserved for local use, which the iso-codes table lists as one code, is not a code
+	_, listed := iso6392B["qaa-qtz"]
+	assert.False(t, listed)
+	assert.Equal(t, "English", iso6392B["eng"])
+	assert.Equal(t, 7910, len(strings.Fields(iso6393Codes)))
+}
+
+func Test_SameLanguageStrings(t *testing.T) {
+	titles := []LanguageString{{"Moonrise, Hernandez", "eng"}, {"Salida de la luna sobre Hernández", "spa"}}
+	assert.True(t, sameLanguageStrings(titles, []LanguageString{titles[1], titles[0]}))
+	assert.False(t, sameLanguageStrings(titles, []LanguageString{{"Moonrise, Hernandez", "spa"}, {"Salida de la luna sobre Hernández", "eng"}}))
+	assert.False(t, sameLanguageStrings(titles, titles[:1]))
+	assert.Equal(t, "Moonrise, Hernandez (eng)", titles[0].String())
+}
+
+func Test_VerifyLanguages(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	fake.add(
+		fakeLanguage("english", "English", "eng"),
+		fakeLanguage("spanish", "Spanish", "spa"),
+		fakeLanguage("german", "German", "deu"),
+		fakeLanguage("klingon", "Klingon", "xyz"),
+		fakeLanguage("english-again", "English (again)", "eng"),
+		fakeResource{
+			Type:       "node--islandora_object",
+			Id:         "moonrise",
+			Attributes: map[string]interface{}{"title": "Moonrise"},
+			Relationships: map[string]interface{}{
+				"field_title_language": fakeRelationship{Type: languageType, Id: "english"},
+				"field_language":       []fakeRelationship{{Type: languageType, Id: "german"}},
+				"field_alternative_title": []fakeRelationship{
+					{Type: languageType, Id: "spanish", Meta: map[string]interface{}{"value": "Salida de la luna"}},
+					{Type: languageType, Id: "klingon", Meta: map[string]interface{}{"value": "maS Hu'"}},
+				},
+				"field_description": []fakeRelationship{
+					{Type: "taxonomy_term--subject", Id: "moon", Meta: map[string]interface{}{"value": "The moon"}},
+				},
+			},
+		},
+		fakeTaxonomyTerm("subject", "moon", "Moon", nil),
+	)
+
+	report := newReport("languages", fake.URL)
+	c := newJsonApiClient(fake.URL, "", "")
+	c.verifyLanguages(report)
+
+	assert.Equal(t, []string{
+		"warning: taxonomy_term--language german [field_language_code] the language 'German' has the ISO 639-2/T language code 'deu' rather than the ISO 639-2/B code 'ger'",
+		"error: taxonomy_term--language klingon [field_language_code] the language 'Klingon' has the unknown language code 'xyz'",
+		"error: taxonomy_term--language [] 2 languages have the code 'eng', so values tagged with it are ambiguous: english, english-again",
+		"error: node--islandora_object moonrise [field_alternative_title] the language of 'maS Hu'' of 'Moonrise' has the unknown language code 'xyz'",
+		"error: node--islandora_object moonrise [field_description] 'Moonrise' refers to the taxonomy_term--subject 'Moon' rather than a language",
+		"warning: node--islandora_object moonrise [field_language] the language 'German' of 'Moonrise' has the ISO 639-2/T language code 'deu' rather than the ISO 639-2/B code 'ger'",
+	}, findingLines(report))
+	assert.Equal(t, 5, report.Counts["languages"])
+	assert.Equal(t, 2, report.Counts["tagged values"])
+	assert.Equal(t, 1, report.Checked)
+
+	values, err := c.languageStrings(&JsonApiResource{Relationships: map[string]JsonApiRelationship{
+		"field_alternative_title": {Data: []RelData{
+			{JsonApiData: JsonApiData{Type: languageType, Id: "spanish"}, Meta: map[string]interface{}{"value": "Salida de la luna"}},
+		}},
+	}}, "field_alternative_title")
+	assert.Nil(t, err)
+	assert.Equal(t, []LanguageString{{"Salida de la luna", "spa"}}, values)
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// The number of links requested at once by default
+	DefaultLinkConcurrency = 8
+
+	// The redirects followed before a link is considered broken
+	maxLinkRedirects = 10
+)
+
+// The schemes of Drupal link fields that refer to the site itself rather than to an external resource, e.g.
+// "internal:/node/1" or "entity:node/1"
+var internalLinkSchemes = []string{"internal", "entity", "route", "base"}
+
+// Answers the link fields of the resource, in alphabetical order.  Link fields are found by the shape of their values
+// rather than by name, so that every link field of every bundle is verified: each value of a link field is an object
+// with just a `uri`, a `title` and `options`.  Authority links, which also have a `source`, are verified by
+// verifyAuthorityLinks.
+func linkFields(res *JsonApiResource) []string {
+	var fields []string
+	for field, v := range res.Attributes {
+		values := asList(v)
+		isLink := len(values) > 0
+		for _, elem := range values {
+			isLink = isLink && isLinkValue(elem)
+		}
+		if isLink {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Answers true if the value of an attribute is the value of a link field
+func isLinkValue(v interface{}) bool {
+	obj, ok := v.(map[string]interface{})
+	if !ok || len(obj) != 3 {
+		return false
+	}
+	for _, member := range []string{"uri", "title", "options"} {
+		if _, ok := obj[member]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Answers the URIs of a link field of the resource
+func linkUris(res *JsonApiResource, field string) []string {
+	var uris []string
+	for _, elem := range asList(res.Attributes[field]) {
+		if obj, ok := elem.(map[string]interface{}); ok {
+			uris = append(uris, scalarString(obj["uri"]))
+		}
+	}
+	return uris
+}
+
+// Answers true if the URI of a link refers to the site itself
+func isInternalLink(uri string) bool {
+	for _, scheme := range internalLinkSchemes {
+		if strings.HasPrefix(uri, scheme+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// The outcome of requesting a link
+type linkStatus struct {
+	// the status of the last response, after following any redirects
+	status int
+	// the URLs the link redirected to, in order
+	redirects []string
+	err       error
+}
+
+// Answers the URL the link finally redirected to, or the empty string if it did not redirect
+func (s linkStatus) location() string {
+	if len(s.redirects) == 0 {
+		return ""
+	}
+	return s.redirects[len(s.redirects)-1]
+}
+
+// Requests links, following their redirects.  Links are requested with HEAD, and with GET by servers that do not
+// support HEAD.  Each link is requested once, however many entities link to it.
+type linkChecker struct {
+	http        *http.Client
+	concurrency int
+
+	mu       sync.Mutex
+	statuses map[string]linkStatus
+}
+
+// Answers a checker requesting concurrency links at a time, at least one
+func newLinkChecker(concurrency int) *linkChecker {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &linkChecker{
+		http: &http.Client{
+			Timeout: 30 * time.Second,
+			// redirects are followed by check, so they are recorded
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		concurrency: concurrency,
+		statuses:    make(map[string]linkStatus),
+	}
+}
+
+// Requests each of the links not yet requested, concurrency at a time
+func (lc *linkChecker) checkAll(links []string) {
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < lc.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for link := range queue {
+				status := lc.check(link)
+				lc.mu.Lock()
+				lc.statuses[link] = status
+				lc.mu.Unlock()
+			}
+		}()
+	}
+
+	requested := make(map[string]bool)
+	for _, link := range links {
+		lc.mu.Lock()
+		_, done := lc.statuses[link]
+		lc.mu.Unlock()
+		if !done && !requested[link] {
+			requested[link] = true
+			queue <- link
+		}
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// Answers the status of a link requested by checkAll
+func (lc *linkChecker) status(link string) (linkStatus, bool) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	status, ok := lc.statuses[link]
+	return status, ok
+}
+
+// Requests the link, following its redirects
+func (lc *linkChecker) check(link string) linkStatus {
+	var s linkStatus
+	current := link
+	for len(s.redirects) <= maxLinkRedirects {
+		status, location, err := lc.request(current)
+		if err != nil {
+			s.err = err
+			return s
+		}
+		if status < 300 || status >= 400 || location == "" {
+			s.status = status
+			return s
+		}
+		base, _ := url.Parse(current)
+		next, err := base.Parse(location)
+		if err != nil {
+			s.err = fmt.Errorf("the redirect of %s to '%s' is not a URL", current, location)
+			return s
+		}
+		current = next.String()
+		s.redirects = append(s.redirects, current)
+	}
+	s.err = fmt.Errorf("more than %d redirects were encountered", maxLinkRedirects)
+	return s
+}
+
+// Requests the URL, answering the status and the Location of the response
+func (lc *linkChecker) request(u string) (int, string, error) {
+	res, err := lc.http.Head(u)
+	if err == nil && (res.StatusCode == http.StatusMethodNotAllowed || res.StatusCode == http.StatusNotImplemented) {
+		res.Body.Close()
+		res, err = lc.http.Get(u)
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	res.Body.Close()
+	return res.StatusCode, res.Header.Get("Location"), nil
+}
+
+// Verifies the links of every node and taxonomy term.  The URI of every link field (e.g. field_finding_aid) must be a
+// well-formed http or https URI, or refer to the site itself (e.g. "internal:/node/1"), and links that are not https
+// are insecure, a warning.  If checker is not nil, every external link is also requested: links that fail, or answer
+// (after following their redirects) a status other than 2xx, are broken, an error, and links that redirect are a
+// warning.
+func (c *jsonApiClient) verifyLinks(checker *linkChecker, report *Report) {
+	type link struct {
+		res   *JsonApiResource
+		field string
+		uri   string
+	}
+	var links []link
+
+	crawled := c.crawl(report, "links", map[string]bool{"node": true, "taxonomy_term": true})
+	for _, t := range sortedTypes(crawled) {
+		for i := range crawled[t] {
+			res := &crawled[t][i]
+			var found bool
+			for _, field := range linkFields(res) {
+				for _, uri := range linkUris(res, field) {
+					links = append(links, link{res, field, uri})
+					found = true
+				}
+			}
+			if found {
+				report.Checked++
+			}
+		}
+	}
+
+	var external []string
+	for _, l := range links {
+		report.Counts["links"]++
+		fail := func(level, format string, args ...interface{}) {
+			report.add(Finding{
+				Level:   level,
+				Check:   "links",
+				Subject: l.res.String(),
+				Field:   l.field,
+				Actual:  l.uri,
+				Message: fmt.Sprintf("the link '%s' of '%s' ", l.uri, l.res.label()) + fmt.Sprintf(format, args...),
+			})
+		}
+
+		if isInternalLink(l.uri) {
+			report.Counts["internal links"]++
+			continue
+		}
+		if problem := malformedUri(l.uri); problem != "" {
+			fail(LevelError, "%s", problem)
+			continue
+		}
+		if strings.HasPrefix(l.uri, "http:") {
+			report.Counts["insecure links"]++
+			fail(LevelWarning, "is insecure, it is not https")
+		}
+		external = append(external, l.uri)
+	}
+
+	if checker == nil {
+		return
+	}
+	checker.checkAll(external)
+	requested := make(map[string]bool)
+	for _, l := range links {
+		status, ok := checker.status(l.uri)
+		if !ok {
+			continue
+		}
+		fail := func(level, format string, args ...interface{}) {
+			report.add(Finding{
+				Level:    level,
+				Check:    "links",
+				Subject:  l.res.String(),
+				Field:    l.field,
+				Expected: l.uri,
+				Actual:   status.location(),
+				Message:  fmt.Sprintf("the link '%s' of '%s' ", l.uri, l.res.label()) + fmt.Sprintf(format, args...),
+			})
+		}
+
+		if !requested[l.uri] {
+			requested[l.uri] = true
+			report.Counts["requested links"]++
+		}
+		switch {
+		case status.err != nil:
+			report.Counts["broken links"]++
+			fail(LevelError, "is broken: %s", status.err)
+		case status.status < 200 || status.status >= 300:
+			report.Counts["broken links"]++
+			if status.location() != "" {
+				fail(LevelError, "is broken: it redirects to %s, which answers %d", status.location(), status.status)
+			} else {
+				fail(LevelError, "is broken: it answers %d", status.status)
+			}
+		case status.location() != "":
+			report.Counts["redirected links"]++
+			fail(LevelWarning, "redirects to %s", status.location())
+		}
+	}
+}
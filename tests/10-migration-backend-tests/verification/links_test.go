@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// An in-process stand-in for the sites linked to.  /ok answers 200, /moved redirects to /ok, /gone redirects to
+// /missing, /loop redirects to itself, and /get-only answers 405 to HEAD.  Anything else answers 404.
+type fakeLinkedSite struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func newFakeLinkedSite(t *testing.T) *fakeLinkedSite {
+	fake := &fakeLinkedSite{}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (fake *fakeLinkedSite) serve(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	fake.requests = append(fake.requests, r.Method+" "+r.URL.Path)
+	fake.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/ok":
+	case "/moved":
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	case "/gone":
+		http.Redirect(w, r, "/missing", http.StatusFound)
+	case "/loop":
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	case "/get-only":
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Answers link field values of the URIs
+func fakeLinks(uris ...string) []interface{} {
+	var links []interface{}
+	for _, uri := range uris {
+		links = append(links, map[string]interface{}{"uri": uri, "title": "", "options": []interface{}{}})
+	}
+	return links
+}
+
+func Test_LinkFields(t *testing.T) {
+	node := &JsonApiResource{Type: "node--islandora_object", Attributes: map[string]interface{}{
+		"title":              "Moonrise",
+		"field_citable_url":  fakeLinks("https://jscholarship.library.jhu.edu/handle/1774.2/1"),
+		"field_finding_aid":  fakeLinks("https://example.org/a", "internal:/node/1"),
+		"field_jhir":         []interface{}{},
+		"field_description":  []interface{}{map[string]interface{}{"value": "Moonrise", "format": nil, "processed": "Moonrise"}},
+		"field_years":        []interface{}{"1941"},
+		"field_dspace_title": nil,
+	}}
+	assert.Equal(t, []string{"field_citable_url", "field_finding_aid"}, linkFields(node))
+
+	// authority links are verified by verifyAuthorityLinks
+	term := &JsonApiResource{Type: "taxonomy_term--islandora_models", Attributes: map[string]interface{}{
+		"name":                 "Image",
+		"field_external_uri":   fakeLinks("http://purl.org/coar/resource_type/c_c513")[0],
+		"field_authority_link": []interface{}{map[string]interface{}{"uri": "http://vocab.getty.edu/aat/300054152", "title": "", "options": []interface{}{}, "source": "aat"}},
+	}}
+	assert.Equal(t, []string{"field_external_uri"}, linkFields(term))
+
+	assert.True(t, isInternalLink("internal:/node/1"))
+	assert.True(t, isInternalLink("entity:node/1"))
+	assert.False(t, isInternalLink("https://jscholarship.library.jhu.edu"))
+}
+
+func Test_NewLinkChecker(t *testing.T) {
+	assert.Equal(t, 1, newLinkChecker(0).concurrency)
+	assert.Equal(t, 3, newLinkChecker(3).concurrency)
+}
+
+func Test_VerifyLinks(t *testing.T) {
+	site := newFakeLinkedSite(t)
+	fake := newFakeJsonApi(t)
+	fake.add(
+		fakeNode("node--islandora_object", "moonrise", "Moonrise", map[string]interface{}{
+			"field_finding_aid":          fakeLinks(site.URL+"/ok", "internal:/node/1"),
+			"field_library_catalog_link": fakeLinks(site.URL+"/moved", site.URL+"/get-only"),
+			"field_jhir":                 fakeLinks(site.URL + "/gone"),
+			"field_is_part_of":           fakeLinks("en.wikipedia.org/wiki/San_Rafael_Reef"),
+		}),
+		fakeNode("node--collection_object", "images", "Images", map[string]interface{}{"field_finding_aid": fakeLinks(site.URL+"/ok", site.URL+"/loop")}),
+		fakeNode("node--collection_object", "unlinked", "Unlinked", nil),
+		fakeTaxonomyTerm("islandora_display", "display", "Open Seadragon", map[string]interface{}{"field_external_uri": fakeLinks(site.URL + "/ok")[0]}),
+	)
+
+	// without requests only the syntax and scheme of the links are verified
+	report := newReport("links", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyLinks(nil, report)
+	assert.True(t, report.failed())
+	assert.Equal(t, 3, report.Checked)
+	assert.Equal(t, 9, report.Counts["links"])
+	assert.Equal(t, 1, report.Counts["internal links"])
+	// the fake site is served over http
+	assert.Equal(t, 7, report.Counts["insecure links"])
+	assert.Equal(t, 8, report.count(LevelWarning)+report.count(LevelError))
+
+	report = newReport("links", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyLinks(newLinkChecker(3), report)
+
+	var problems []string
+	for _, line := range findingLines(report) {
+		if !strings.HasSuffix(line, "is insecure, it is not https") {
+			problems = append(problems, line)
+		}
+	}
+	assert.Equal(t, []string{
+		"error: node--islandora_object moonrise [field_is_part_of] the link 'en.wikipedia.org/wiki/San_Rafael_Reef' of 'Moonrise' is not an http or https URI",
+		"error: node--collection_object images [field_finding_aid] the link '" + site.URL + "/loop' of 'Images' is broken: more than 10 redirects were encountered",
+		"error: node--islandora_object moonrise [field_jhir] the link '" + site.URL + "/gone' of 'Moonrise' is broken: it redirects to " + site.URL + "/missing, which answers 404",
+		"warning: node--islandora_object moonrise [field_library_catalog_link] the link '" + site.URL + "/moved' of 'Moonrise' redirects to " + site.URL + "/ok",
+	}, problems)
+	assert.Equal(t, 5, report.Counts["requested links"])
+	assert.Equal(t, 2, report.Counts["broken links"])
+	assert.Equal(t, 1, report.Counts["redirected links"])
+
+	// /ok is linked three times, but requested once (and once more following /moved); /get-only is requested with GET after
+	// HEAD is refused
+	requests := make(map[string]int)
+	for _, r := range site.requests {
+		requests[r]++
+	}
+	assert.Equal(t, 2, requests["HEAD /ok"])
+	assert.Equal(t, 1, requests["HEAD /get-only"])
+	assert.Equal(t, 1, requests["GET /get-only"])
+	assert.Equal(t, 11, requests["HEAD /loop"])
+}
@@ -0,0 +1,708 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// idc-verify verifies a migration against a running IDC instance without the `go test` harness, e.g.:
+//
+//   go build -o idc-verify . && ./idc-verify verify -url https://idc.example.org -fixtures ./my-batch
+//
+// Each command writes a report to stdout (or to the file named by -o) and exits with a non-zero status if the report
+// contains errors.
+
+const (
+	// Env var names consulted for defaults of the common flags
+	EnvBaseUrl  = "IDC_VERIFY_URL"
+	EnvUser     = "IDC_VERIFY_USER"
+	EnvPassword = "IDC_VERIFY_PASSWORD"
+	EnvFcrepo   = "IDC_VERIFY_FCREPO"
+	EnvSparql   = "IDC_VERIFY_SPARQL"
+	EnvSolr     = "IDC_VERIFY_SOLR"
+	EnvIiif     = "IDC_VERIFY_IIIF"
+	EnvS3       = "IDC_VERIFY_S3"
+	EnvS3Access = "IDC_VERIFY_S3_ACCESS_KEY"
+	EnvS3Secret = "IDC_VERIFY_S3_SECRET_KEY"
+	EnvMirror   = "IDC_VERIFY_AUTHORITY_MIRROR"
+
+	// Exit status when verification fails, or when idc-verify is invoked incorrectly
+	ExitFailed = 1
+	ExitUsage  = 2
+)
+
+var errUsage = errors.New("usage")
+
+// An idc-verify command, e.g. `verify`
+type command struct {
+	name    string
+	summary string
+	run     func(opts *options, args []string) (*Report, error)
+	// registers flags particular to the command, may be nil
+	flags func(opts *options)
+}
+
+// The commands supported by idc-verify, in the order they are listed by the usage message
+var commands = []command{
+	{"verify", "verify the entities described by the fixtures in -fixtures", runVerify, nil},
+	{"audit", "crawl every node, media, file and taxonomy term", runAudit, nil},
+	{"orphans", "find media that are not the media of any node, and files not referred to by any media", runOrphans, nil},
+	{"duplicates", "find likely duplicate terms in the vocabularies used for entity resolution", runDuplicates, nil},
+	{"fixity", "check the checksum and size of every file referred to by a media", runFixity, fixityFlags},
+	{"fedora", "compare every node and media synchronized with Fedora with its Fedora resource", runFedora, fedoraFlags},
+	{"triplestore", "check the triples of every node and agent are indexed in the triplestore", runTriplestore, triplestoreFlags},
+	{"solr", "check every node is in the Solr index with the fields it has in Drupal", runSolr, solrFlags},
+	{"derivatives", "check the derivatives of every Original File have been generated", runDerivatives, derivativesFlags},
+	{"iiif", "check the IIIF server serves the file of every image media", runIiif, iiifFlags},
+	{"s3", "check the object of every file is in the S3 bucket, and find objects of no file", runS3, s3Flags},
+	{"contacts", "check the contact emails of collections and contact forms: contacts [<csv>...]", runContacts, nil},
+	{"techmd", "check the technical metadata of media match the content of their files", runTechnicalMetadata, nil},
+	{"text", "check the edited text of extracted text media matches their files, and is sanitized", runExtractedText, textFlags},
+	{"languages", "check the language codes of the language vocabulary and of the languages of every node", runLanguages, nil},
+	{"dates", "check the date fields of every node and taxonomy term are valid EDTF", runDates, nil},
+	{"authorities", "check the authority links of every taxonomy term match the URIs of their sources", runAuthorities, authoritiesFlags},
+	{"links", "check the link fields of every node and taxonomy term, and optionally request them", runLinks, linksFlags},
+	{"migrations", "check the rows of CSVs were imported: migrations <migration>=<csv>[,<csv>...]...", runMigrations, migrationsFlags},
+	{"deletion", "check the media of fixtures were deleted along with their files: deletion <fixture.json>...", runDeletion, nil},
+	{"resolution", "check the entity lookups of ingest CSVs resolved to the intended bundles: resolution <ingest.csv>...", runResolution, updateFlags},
+	{"snapshot", "record every node, media, file and taxonomy term: snapshot <snapshot.json>", runSnapshot, nil},
+	{"rollback", "check a rollback removed what a migration created: rollback <before.json> <after.json>", runRollback, nil},
+	{"update", "check re-ingesting a modified CSV updated its nodes: update <before.json> <original.csv> <modified.csv>", runUpdate, updateFlags},
+	{"roundtrip", "compare the Export Metadata view with the CSVs it was ingested from: roundtrip <ingest.csv>...", runRoundTrip, roundTripFlags},
+	{"fetch", "print a JSONAPI resource: fetch <entity--bundle> [<filter> <value>]", runFetch, nil},
+	{"diff", "compare fixtures with their entities field by field: diff <fixture.json>...", runDiff, nil},
+	{"report", "combine reports written with -format json: report <report.json>...", runReport, nil},
+}
+
+// Flags common to every command
+type options struct {
+	flags    *flag.FlagSet
+	baseUrl  string
+	user     string
+	password string
+	fixtures string
+	format   string
+	output   string
+	// where commands that print resources, like fetch and diff, print them
+	stdout io.Writer
+
+	// flags of the fixity command
+	checksums string
+
+	// flags of the fedora command
+	fcrepo         string
+	fcrepoUser     string
+	fcrepoPassword string
+
+	// flags of the triplestore command
+	sparql string
+
+	// flags of the solr command
+	solr string
+
+	// flags of the derivatives command
+	timeout  time.Duration
+	interval time.Duration
+
+	// flags of the iiif command
+	iiif string
+
+	// flags of the s3 command
+	s3           string
+	s3Bucket     string
+	s3Region     string
+	s3AccessKey  string
+	s3SecretKey  string
+	s3RootFolder string
+	s3Private    string
+	s3Public     string
+
+	// flags of the text command
+	similarity float64
+
+	// flags of the authorities command
+	mirror string
+
+	// flags of the links command
+	requestLinks bool
+	concurrency  int
+
+	// flags of the migrations command
+	group string
+
+	// flags of the update and resolution commands
+	bundle string
+
+	// flags of the roundtrip command
+	view   string
+	query  string
+	export string
+}
+
+func (o *options) client() *jsonApiClient {
+	return newJsonApiClient(o.baseUrl, o.user, o.password)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// Runs the command named by the first argument, answering the exit status
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "help" {
+		usage(stderr)
+		return ExitUsage
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == args[0] {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "idc-verify: unknown command '%s'\n", args[0])
+		usage(stderr)
+		return ExitUsage
+	}
+
+	opts := &options{flags: flag.NewFlagSet(cmd.name, flag.ContinueOnError), stdout: stdout}
+	opts.flags.SetOutput(stderr)
+	opts.flags.StringVar(&opts.baseUrl, "url", envOr(EnvBaseUrl, DrupalBaseurl), "base URL of the IDC instance (env "+EnvBaseUrl+")")
+	opts.flags.StringVar(&opts.user, "user", os.Getenv(EnvUser), "Drupal user for HTTP basic authentication (env "+EnvUser+")")
+	opts.flags.StringVar(&opts.password, "password", os.Getenv(EnvPassword), "password of the Drupal user (env "+EnvPassword+")")
+	opts.flags.StringVar(&opts.fixtures, "fixtures", "expected", "directory of expected JSON fixtures")
+	opts.flags.StringVar(&opts.format, "format", "text", "report format: text or json")
+	opts.flags.StringVar(&opts.output, "o", "", "write the report to this file rather than stdout")
+	if cmd.flags != nil {
+		cmd.flags(opts)
+	}
+	if err := opts.flags.Parse(args[1:]); err != nil {
+		return ExitUsage
+	}
+	if opts.format != "text" && opts.format != "json" {
+		fmt.Fprintf(stderr, "idc-verify %s: unknown output format '%s' (expected 'text' or 'json')\n", cmd.name, opts.format)
+		return ExitUsage
+	}
+
+	report, err := cmd.run(opts, opts.flags.Args())
+	if errors.Is(err, errUsage) {
+		opts.flags.Usage()
+		return ExitUsage
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "idc-verify %s: %s\n", cmd.name, err)
+		return ExitFailed
+	}
+	if report == nil {
+		return 0
+	}
+
+	if err := writeReport(report, opts, stdout); err != nil {
+		fmt.Fprintf(stderr, "idc-verify %s: %s\n", cmd.name, err)
+		return ExitFailed
+	}
+
+	if report.failed() {
+		return ExitFailed
+	}
+	return 0
+}
+
+// Writes the report in the format of the options to their output file, or to stdout if they name none.  The output
+// file must be closed without error for the report to have been written.
+func writeReport(report *Report, opts *options, stdout io.Writer) (err error) {
+	if opts.output == "" {
+		return report.write(stdout, opts.format)
+	}
+
+	f, err := os.Create(opts.output)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	return report.write(f, opts.format)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: idc-verify <command> [flags] [args]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nrun 'idc-verify <command> -h' for the flags of a command\n")
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+func runVerify(opts *options, args []string) (*Report, error) {
+	fixtures, err := readFixtures(opts.fixtures)
+	if err != nil {
+		return nil, err
+	}
+	if len(fixtures) == 0 {
+		return nil, fmt.Errorf("no fixtures found in %s", opts.fixtures)
+	}
+
+	c := opts.client()
+	report := newReport("verify", opts.baseUrl)
+	for _, f := range fixtures {
+		c.verifyFixture(f, report)
+	}
+	return report, nil
+}
+
+func runAudit(opts *options, args []string) (*Report, error) {
+	report := newReport("audit", opts.baseUrl)
+	opts.client().audit(report)
+	return report, nil
+}
+
+func runOrphans(opts *options, args []string) (*Report, error) {
+	report := newReport("orphans", opts.baseUrl)
+	opts.client().findOrphans(report)
+	return report, nil
+}
+
+func runDuplicates(opts *options, args []string) (*Report, error) {
+	report := newReport("duplicates", opts.baseUrl)
+	opts.client().findDuplicateTerms(report)
+	return report, nil
+}
+
+func fixityFlags(opts *options) {
+	opts.flags.StringVar(&opts.checksums, "checksums", "sha1", "comma separated checksum algorithms to compute in addition to sha1: sha256, md5")
+}
+
+func runFixity(opts *options, args []string) (*Report, error) {
+	algorithms, err := parseAlgorithms(opts.checksums)
+	if err != nil {
+		return nil, err
+	}
+	report := newReport("fixity", opts.baseUrl)
+	opts.client().checkFixity(report, algorithms)
+	return report, nil
+}
+
+func fedoraFlags(opts *options) {
+	opts.flags.StringVar(&opts.fcrepo, "fcrepo", envOr(EnvFcrepo, DefaultFcrepoUrl), "Fedora REST endpoint (env "+EnvFcrepo+")")
+	opts.flags.StringVar(&opts.fcrepoUser, "fcrepo-user", "", "Fedora user for HTTP basic authentication")
+	opts.flags.StringVar(&opts.fcrepoPassword, "fcrepo-password", "", "password of the Fedora user")
+}
+
+func runFedora(opts *options, args []string) (*Report, error) {
+	report := newReport("fedora", opts.baseUrl)
+	opts.client().verifyFedora(newFedoraClient(opts.fcrepo, opts.fcrepoUser, opts.fcrepoPassword), report)
+	return report, nil
+}
+
+func triplestoreFlags(opts *options) {
+	opts.flags.StringVar(&opts.sparql, "sparql", envOr(EnvSparql, DefaultSparqlUrl), "SPARQL endpoint of the triplestore (env "+EnvSparql+")")
+}
+
+func runTriplestore(opts *options, args []string) (*Report, error) {
+	report := newReport("triplestore", opts.baseUrl)
+	opts.client().verifyTriplestore(newSparqlClient(opts.sparql, "", ""), report)
+	return report, nil
+}
+
+func solrFlags(opts *options) {
+	opts.flags.StringVar(&opts.solr, "solr", envOr(EnvSolr, DefaultSolrUrl), "URL of the Solr core (env "+EnvSolr+")")
+}
+
+func runSolr(opts *options, args []string) (*Report, error) {
+	report := newReport("solr", opts.baseUrl)
+	opts.client().verifySolr(newSolrClient(opts.solr), report)
+	return report, nil
+}
+
+func derivativesFlags(opts *options) {
+	opts.flags.DurationVar(&opts.timeout, "timeout", 5*time.Minute, "how long to wait for derivatives to be generated; 0 checks once")
+	opts.flags.DurationVar(&opts.interval, "interval", 10*time.Second, "how often to check for derivatives that have not been found")
+}
+
+func runDerivatives(opts *options, args []string) (*Report, error) {
+	report := newReport("derivatives", opts.baseUrl)
+	opts.client().verifyDerivatives(report, opts.timeout, opts.interval)
+	return report, nil
+}
+
+func iiifFlags(opts *options) {
+	opts.flags.StringVar(&opts.iiif, "iiif", envOr(EnvIiif, DefaultIiifUrl), "IIIF Image API endpoint (env "+EnvIiif+")")
+}
+
+func runIiif(opts *options, args []string) (*Report, error) {
+	report := newReport("iiif", opts.baseUrl)
+	opts.client().verifyIiif(newIiifClient(opts.iiif), report)
+	return report, nil
+}
+
+func s3Flags(opts *options) {
+	opts.flags.StringVar(&opts.s3, "s3", envOr(EnvS3, DefaultS3Url), "endpoint of the S3-compatible storage (env "+EnvS3+")")
+	opts.flags.StringVar(&opts.s3Bucket, "bucket", DefaultS3Bucket, "the bucket s3fs stores files in")
+	opts.flags.StringVar(&opts.s3Region, "region", DefaultS3Region, "the region of the bucket")
+	opts.flags.StringVar(&opts.s3AccessKey, "access-key", os.Getenv(EnvS3Access), "access key of the storage; requests are anonymous if none is supplied (env "+EnvS3Access+")")
+	opts.flags.StringVar(&opts.s3SecretKey, "secret-key", os.Getenv(EnvS3Secret), "secret key of the storage (env "+EnvS3Secret+")")
+	opts.flags.StringVar(&opts.s3RootFolder, "root-folder", "", "the root folder of s3fs in the bucket")
+	opts.flags.StringVar(&opts.s3Private, "private-folder", DefaultS3PrivateFolder, "the folder s3fs stores private:// files in")
+	opts.flags.StringVar(&opts.s3Public, "public-folder", DefaultS3PublicFolder, "the folder s3fs stores public:// files in")
+}
+
+func runS3(opts *options, args []string) (*Report, error) {
+	s := newS3Client(opts.s3, opts.s3Bucket, opts.s3Region, opts.s3AccessKey, opts.s3SecretKey,
+		s3Folders{root: opts.s3RootFolder, private: opts.s3Private, public: opts.s3Public})
+	report := newReport("s3", opts.baseUrl)
+	opts.client().verifyObjectStorage(s, report)
+	return report, nil
+}
+
+func runContacts(opts *options, args []string) (*Report, error) {
+	tables, err := readIngestCsvs(args)
+	if err != nil {
+		return nil, err
+	}
+	report := newReport("contacts", opts.baseUrl)
+	opts.client().verifyContactEmails(newJsonApiClient(opts.baseUrl, "", ""), tables, report)
+	return report, nil
+}
+
+func runTechnicalMetadata(opts *options, args []string) (*Report, error) {
+	report := newReport("techmd", opts.baseUrl)
+	opts.client().verifyTechnicalMetadata(report)
+	return report, nil
+}
+
+func textFlags(opts *options) {
+	opts.flags.Float64Var(&opts.similarity, "similarity", 1, "the least similarity of edited text to its extracted text, between 0 and 1, below which differences are errors rather than warnings")
+}
+
+func runExtractedText(opts *options, args []string) (*Report, error) {
+	if opts.similarity < 0 || opts.similarity > 1 {
+		return nil, errUsage
+	}
+	report := newReport("text", opts.baseUrl)
+	opts.client().verifyExtractedText(opts.similarity, report)
+	return report, nil
+}
+
+func runLanguages(opts *options, args []string) (*Report, error) {
+	report := newReport("languages", opts.baseUrl)
+	opts.client().verifyLanguages(report)
+	return report, nil
+}
+
+func runDates(opts *options, args []string) (*Report, error) {
+	report := newReport("dates", opts.baseUrl)
+	opts.client().verifyDates(report)
+	return report, nil
+}
+
+func authoritiesFlags(opts *options) {
+	opts.flags.StringVar(&opts.mirror, "mirror", os.Getenv(EnvMirror), "a mirror of the authorities to resolve authority links against; links are not resolved if none is supplied (env "+EnvMirror+")")
+}
+
+func runAuthorities(opts *options, args []string) (*Report, error) {
+	var resolver *authorityResolver
+	if opts.mirror != "" {
+		resolver = newAuthorityResolver(opts.mirror)
+	}
+	report := newReport("authorities", opts.baseUrl)
+	opts.client().verifyAuthorityLinks(resolver, report)
+	return report, nil
+}
+
+func linksFlags(opts *options) {
+	opts.flags.BoolVar(&opts.requestLinks, "request", false, "request every external link, reporting broken and redirected links")
+	opts.flags.IntVar(&opts.concurrency, "concurrency", DefaultLinkConcurrency, "the number of links requested at once")
+}
+
+func runLinks(opts *options, args []string) (*Report, error) {
+	if opts.concurrency < 1 {
+		return nil, errUsage
+	}
+	var checker *linkChecker
+	if opts.requestLinks {
+		checker = newLinkChecker(opts.concurrency)
+	}
+	report := newReport("links", opts.baseUrl)
+	opts.client().verifyLinks(checker, report)
+	return report, nil
+}
+
+func migrationsFlags(opts *options) {
+	opts.flags.StringVar(&opts.group, "group", DefaultMigrationGroup, "the migration group of the migrations")
+}
+
+func runMigrations(opts *options, args []string) (*Report, error) {
+	if len(args) == 0 {
+		return nil, errUsage
+	}
+	csvs := make(map[string][]*csvTable)
+	for _, arg := range args {
+		i := strings.Index(arg, "=")
+		if i <= 0 {
+			return nil, errUsage
+		}
+		tables, err := readIngestCsvs(strings.Split(arg[i+1:], ","))
+		if err != nil {
+			return nil, err
+		}
+		csvs[arg[:i]] = append(csvs[arg[:i]], tables...)
+	}
+
+	report := newReport("migrations", opts.baseUrl)
+	opts.client().verifyMigrations(opts.group, csvs, report)
+	return report, nil
+}
+
+func runDeletion(opts *options, args []string) (*Report, error) {
+	if len(args) == 0 {
+		return nil, errUsage
+	}
+	c := opts.client()
+	report := newReport("deletion", opts.baseUrl)
+	for _, path := range args {
+		f, err := readFixture(path)
+		if err != nil {
+			return nil, err
+		}
+		c.verifyFileDeletion(f, report)
+	}
+	return report, nil
+}
+
+func runResolution(opts *options, args []string) (*Report, error) {
+	if len(args) == 0 {
+		return nil, errUsage
+	}
+	if _, ok := relationshipRules[DrupalType(opts.bundle)]; !ok || !strings.HasPrefix(opts.bundle, "node--") {
+		return nil, fmt.Errorf("the fields of %s are not known, expected node--islandora_object or node--collection_object", opts.bundle)
+	}
+	tables, err := readIngestCsvs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	report := newReport("resolution", opts.baseUrl)
+	opts.client().verifyEntityResolution(DrupalType(opts.bundle), tables, report)
+	return report, nil
+}
+
+func runSnapshot(opts *options, args []string) (*Report, error) {
+	if len(args) != 1 {
+		return nil, errUsage
+	}
+	report := newReport("snapshot", opts.baseUrl)
+	s := opts.client().snapshot(report)
+	report.Checked = len(s.Entities)
+	if err := writeSnapshot(s, args[0]); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func runRollback(opts *options, args []string) (*Report, error) {
+	if len(args) != 2 {
+		return nil, errUsage
+	}
+	before, err := readSnapshot(args[0])
+	if err != nil {
+		return nil, err
+	}
+	after, err := readSnapshot(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	report := newReport("rollback", opts.baseUrl)
+	opts.client().verifyRollback(before, after, report)
+	return report, nil
+}
+
+func updateFlags(opts *options) {
+	opts.flags.StringVar(&opts.bundle, "bundle", "node--islandora_object", "the resource type of the nodes ingested from the CSVs")
+}
+
+func runUpdate(opts *options, args []string) (*Report, error) {
+	if len(args) != 3 {
+		return nil, errUsage
+	}
+	if _, ok := fixtureFields[DrupalType(opts.bundle)]; !ok || !strings.HasPrefix(opts.bundle, "node--") {
+		return nil, fmt.Errorf("the fields of %s are not known, expected node--islandora_object or node--collection_object", opts.bundle)
+	}
+	snapshot, err := readSnapshot(args[0])
+	if err != nil {
+		return nil, err
+	}
+	tables, err := readIngestCsvs(args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	report := newReport("update", opts.baseUrl)
+	opts.client().verifyUpdate(DrupalType(opts.bundle), snapshot, tables[0], tables[1], report)
+	return report, nil
+}
+
+func roundTripFlags(opts *options) {
+	opts.flags.StringVar(&opts.view, "view", "export_items", "path of the Export Metadata display: export_items or export_collections")
+	opts.flags.StringVar(&opts.query, "query", "", "fulltext query filtering the exported entities")
+	opts.flags.StringVar(&opts.export, "export", "", "a CSV already exported by the Export Metadata view, instead of exporting with -view")
+}
+
+func runRoundTrip(opts *options, args []string) (*Report, error) {
+	if len(args) == 0 {
+		return nil, errUsage
+	}
+	ingested, err := readIngestCsvs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	var content []byte
+	source := opts.export
+	if source != "" {
+		content, err = ioutil.ReadFile(source)
+	} else {
+		source = opts.baseUrl + "/" + opts.view
+		content, err = opts.client().exportCsv(opts.view, opts.query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	exported, err := readCsv(source, bytes.NewReader(content), exportColumnAliases)
+	if err != nil {
+		return nil, err
+	}
+
+	report := newReport("roundtrip", opts.baseUrl)
+	compareRoundTrip(exported, ingested, report)
+	return report, nil
+}
+
+// Prints the resources of the named type, optionally filtered.  No report is produced.
+func runFetch(opts *options, args []string) (*Report, error) {
+	if len(args) != 1 && len(args) != 3 {
+		return nil, errUsage
+	}
+	t := DrupalType(args[0])
+	if !strings.Contains(args[0], "--") {
+		return nil, fmt.Errorf("resource type must be of the form <entity>--<bundle>, e.g. taxonomy_term--person")
+	}
+
+	c := opts.client()
+	var (
+		resources []JsonApiResource
+		err       error
+	)
+	if len(args) == 3 {
+		resources, err = c.find(t, args[1], args[2])
+	} else {
+		resources, err = c.collection(fmt.Sprintf("%s/jsonapi/%s/%s", c.baseUrl, t.entity(), t.bundle()))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	enc := json.NewEncoder(opts.stdout)
+	enc.SetIndent("", "  ")
+	return nil, enc.Encode(resources)
+}
+
+// Prints every fixture key alongside the value of its Drupal field, marking differences, followed by a report
+// containing the same findings as `verify` would for the fixtures.
+func runDiff(opts *options, args []string) (*Report, error) {
+	if len(args) == 0 {
+		return nil, errUsage
+	}
+
+	c := opts.client()
+	report := newReport("diff", opts.baseUrl)
+	for _, path := range args {
+		f, err := readFixture(path)
+		if err != nil {
+			return nil, err
+		}
+
+		res, comparisons, err := c.compareFixture(f)
+		if err != nil {
+			report.error("diff", f.name(), "%s", err)
+			continue
+		}
+
+		fmt.Fprintf(opts.stdout, "--- %s\n+++ %s\n", f.path, res)
+		for _, fc := range comparisons {
+			switch {
+			case fc.unmapped:
+				fmt.Fprintf(opts.stdout, "? %s: %s\n", fc.key, "(not mapped to a Drupal field)")
+			case fc.err != nil:
+				fmt.Fprintf(opts.stdout, "! %s (%s): %s\n", fc.key, fc.field, fc.err)
+			case fc.matches():
+				fmt.Fprintf(opts.stdout, "  %s: %s\n", fc.key, strings.Join(fc.expected, " | "))
+			default:
+				fmt.Fprintf(opts.stdout, "- %s: %s\n+ %s: %s\n", fc.key, strings.Join(fc.expected, " | "), fc.key, strings.Join(fc.actual, " | "))
+			}
+		}
+		c.verifyFixture(f, report)
+	}
+	return report, nil
+}
+
+// Combines reports previously written with `-format json` into a single report
+func runReport(opts *options, args []string) (*Report, error) {
+	if len(args) == 0 {
+		return nil, errUsage
+	}
+
+	var (
+		combined = newReport("report", "")
+		targets  []string
+		seen     = make(map[string]bool)
+	)
+	for _, path := range args {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		r := &Report{}
+		if err := json.Unmarshal(b, r); err != nil {
+			return nil, fmt.Errorf("%s is not a JSON report: %w", path, err)
+		}
+
+		if !seen[r.Target] {
+			seen[r.Target] = true
+			targets = append(targets, r.Target)
+		}
+		combined.Checked += r.Checked
+		combined.Findings = append(combined.Findings, r.Findings...)
+		combined.Fixity = append(combined.Fixity, r.Fixity...)
+		combined.Technical = append(combined.Technical, r.Technical...)
+		for k, v := range r.Counts {
+			combined.Counts[fmt.Sprintf("%s: %s", r.Command, k)] += v
+		}
+		if r.Started.Before(combined.Started) {
+			combined.Started = r.Started
+		}
+	}
+
+	sort.Strings(targets)
+	combined.Target = strings.Join(targets, ", ")
+	return combined, nil
+}
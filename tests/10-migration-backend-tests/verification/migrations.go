@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The migration group of the IDC ingest migrations, following `migrate_plus.migration_group.idc_ingest.yml`
+const DefaultMigrationGroup = "idc_ingest"
+
+// The source ids of the ingest migrations that are not identified by local_id alone, following the `source.ids` of
+// `migrate_plus.migration.idc_ingest_*.yml`
+var migrationSourceIds = map[string][]string{
+	"idc_ingest_contact_email":  {"email_id"},
+	"idc_ingest_new_collection": {"local_id", "node_id"},
+	"idc_ingest_new_items":      {"local_id", "node_id"},
+}
+
+// Answers the CSV columns that identify a source row of the migration
+func sourceIdsOf(migration string) []string {
+	if ids, ok := migrationSourceIds[migration]; ok {
+		return ids
+	}
+	return []string{"local_id"}
+}
+
+// A message recorded by a migration for a source row, e.g. an exception thrown by a process plugin
+type migrateMessage struct {
+	SourceIds string
+	Level     string
+	Message   string
+}
+
+// Answers true if the message was recorded at the error level, which is displayed as its name or its number (1)
+func (m migrateMessage) isError() bool {
+	return m.Level == "1" || strings.Contains(strings.ToLower(m.Level), "error")
+}
+
+// The bookkeeping of a migration, as displayed by migrate_tools
+type migrationStatus struct {
+	Migration string
+	// the source rows imported
+	Imported int
+	// the source rows with an error message, which failed to import
+	Failed int
+	// the source rows with only messages of other levels, which were ignored (skipped) by the migration
+	Ignored  int
+	Messages []migrateMessage
+}
+
+// Matches the rows and cells of an HTML table, and markup within a cell
+var (
+	htmlTableRow  = regexp.MustCompile(`(?is)<tr[^>]*>(.*?)</tr>`)
+	htmlTableCell = regexp.MustCompile(`(?is)<t[hd][^>]*>(.*?)</t[hd]>`)
+	htmlTag       = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlNextPage  = regexp.MustCompile(`rel="next"`)
+)
+
+// Answers the text of the cells of each row of the HTML tables in body, the first of which is the header
+func htmlTableRows(body []byte) [][]string {
+	var rows [][]string
+	for _, tr := range htmlTableRow.FindAllSubmatch(body, -1) {
+		var cells []string
+		for _, td := range htmlTableCell.FindAllSubmatch(tr[1], -1) {
+			text := html.UnescapeString(htmlTag.ReplaceAllString(string(td[1]), ""))
+			cells = append(cells, strings.Join(strings.Fields(text), " "))
+		}
+		rows = append(rows, cells)
+	}
+	return rows
+}
+
+// Answers the index of the first header whose text contains name (case insensitive), or -1
+func columnOf(header []string, name string) int {
+	for i, h := range header {
+		if strings.Contains(strings.ToLower(h), name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Answers the content of the Drupal page at the URL
+func getPage(browser *http.Client, u string) ([]byte, error) {
+	res, err := browser.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, u)
+	case res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusUnauthorized:
+		return nil, fmt.Errorf("%w: %s", ErrForbidden, u)
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%d status encountered when requesting %s", res.StatusCode, u)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", u, err)
+	}
+	return body, nil
+}
+
+// Answers the bookkeeping of the migrations of a group, keyed by migration id, from the migration overview and the
+// migrate messages pages of migrate_tools.  The imported count is read from the overview; failed and ignored rows are
+// counted from the messages recorded for them, so rows skipped without a message are only evident as a shortfall of
+// imported rows.
+func (c *jsonApiClient) migrationStatuses(browser *http.Client, group string, migrations []string) (map[string]*migrationStatus, error) {
+	u := fmt.Sprintf("%s/admin/structure/migrate/manage/%s/migrations", c.baseUrl, url.PathEscape(group))
+	body, err := getPage(browser, u)
+	if err != nil {
+		return nil, err
+	}
+	rows := htmlTableRows(body)
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no migrations are listed by %s", u)
+	}
+	idColumn, importedColumn := columnOf(rows[0], "machine name"), columnOf(rows[0], "imported")
+	if idColumn < 0 || importedColumn < 0 {
+		return nil, fmt.Errorf("the migrations listed by %s have no machine name or imported count", u)
+	}
+
+	statuses := make(map[string]*migrationStatus)
+	for _, row := range rows[1:] {
+		if len(row) <= idColumn || len(row) <= importedColumn || !contains(migrations, row[idColumn]) {
+			continue
+		}
+		imported, err := strconv.Atoi(row[importedColumn])
+		if err != nil {
+			return nil, fmt.Errorf("the imported count of %s listed by %s is not a number: '%s'", row[idColumn], u, row[importedColumn])
+		}
+		statuses[row[idColumn]] = &migrationStatus{Migration: row[idColumn], Imported: imported}
+	}
+
+	for _, status := range statuses {
+		if status.Messages, err = c.migrateMessages(browser, group, status.Migration); err != nil {
+			return nil, err
+		}
+		failed, ignored := make(map[string]bool), make(map[string]bool)
+		for _, m := range status.Messages {
+			if m.isError() {
+				failed[m.SourceIds] = true
+			} else {
+				ignored[m.SourceIds] = true
+			}
+		}
+		for ids := range failed {
+			delete(ignored, ids)
+		}
+		status.Failed, status.Ignored = len(failed), len(ignored)
+	}
+	return statuses, nil
+}
+
+// Answers the messages recorded by a migration, following the pages of its messages
+func (c *jsonApiClient) migrateMessages(browser *http.Client, group, migration string) ([]migrateMessage, error) {
+	var messages []migrateMessage
+	for page := 0; ; page++ {
+		u := fmt.Sprintf("%s/admin/structure/migrate/manage/%s/migrations/%s/messages?page=%d", c.baseUrl,
+			url.PathEscape(group), url.PathEscape(migration), page)
+		body, err := getPage(browser, u)
+		if err != nil {
+			return nil, err
+		}
+		rows := htmlTableRows(body)
+		if len(rows) < 2 {
+			return messages, nil
+		}
+		source, level, message := columnOf(rows[0], "source"), columnOf(rows[0], "level"), columnOf(rows[0], "message")
+		if level < 0 || message < 0 {
+			return nil, fmt.Errorf("the messages listed by %s have no level or message", u)
+		}
+		for _, row := range rows[1:] {
+			// an empty table has a single row saying so
+			if len(row) <= level || len(row) <= message {
+				continue
+			}
+			m := migrateMessage{Level: row[level], Message: row[message]}
+			if source >= 0 {
+				m.SourceIds = row[source]
+			}
+			messages = append(messages, m)
+		}
+		if !htmlNextPage.Match(body) {
+			return messages, nil
+		}
+	}
+}
+
+// Answers the number of distinct source rows of the CSVs, identified by the source ids of the migration
+func countSourceRows(migration string, tables []*csvTable) int {
+	ids := make(map[string]bool)
+	for _, table := range tables {
+		for _, row := range table.rows {
+			var values []string
+			for _, column := range sourceIdsOf(migration) {
+				values = append(values, strings.TrimSpace(row[column]))
+			}
+			ids[strings.Join(values, "\x00")] = true
+		}
+	}
+	return len(ids)
+}
+
+// Verifies that every source row of the CSVs ingested by each migration was imported, according to the bookkeeping
+// of the migration.  A migration that imported fewer rows than its CSVs contain is an error, reported with the
+// messages the migration recorded; messages of migrations that imported every row are reported as warnings.
+func (c *jsonApiClient) verifyMigrations(group string, csvs map[string][]*csvTable, report *Report) {
+	browser, err := c.session(60 * time.Second)
+	if err != nil {
+		report.error("migrations", c.baseUrl, "unable to log in: %s", err)
+		return
+	}
+
+	var migrations []string
+	for migration := range csvs {
+		migrations = append(migrations, migration)
+	}
+	sort.Strings(migrations)
+
+	statuses, err := c.migrationStatuses(browser, group, migrations)
+	if err != nil {
+		report.error("migrations", group, "unable to read the status of the migrations: %s", err)
+		return
+	}
+
+	for _, migration := range migrations {
+		report.Checked++
+		status, ok := statuses[migration]
+		if !ok {
+			report.error("migrations", migration, "the migration is not in the %s group", group)
+			continue
+		}
+		report.Counts["imported"] += status.Imported
+		report.Counts["failed"] += status.Failed
+		report.Counts["ignored"] += status.Ignored
+
+		expected := countSourceRows(migration, csvs[migration])
+		level := LevelWarning
+		if status.Imported < expected {
+			level = LevelError
+			report.add(Finding{
+				Level:    LevelError,
+				Check:    "migrations",
+				Subject:  migration,
+				Field:    "imported",
+				Expected: strconv.Itoa(expected),
+				Actual:   strconv.Itoa(status.Imported),
+				Message: fmt.Sprintf("%d of %d source rows were not imported (%d failed, %d ignored)", expected-status.Imported,
+					expected, status.Failed, status.Ignored),
+			})
+		} else if status.Imported > expected {
+			report.warning("migrations", migration, "%d rows were imported, but the CSVs only contain %d: rows were imported from other CSVs",
+				status.Imported, expected)
+		}
+
+		for _, m := range status.Messages {
+			report.add(Finding{
+				Level:   level,
+				Check:   "migrations",
+				Subject: migration,
+				Field:   m.SourceIds,
+				Actual:  m.Level,
+				Message: m.Message,
+			})
+		}
+	}
+}
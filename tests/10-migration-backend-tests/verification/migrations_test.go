@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// An in-process stand-in for the migration overview and migrate messages pages of migrate_tools.  The pages are only
+// served to a logged in session.  Messages are served two to a page.
+type fakeMigrate struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	imported map[string]int
+	messages map[string][]migrateMessage
+}
+
+func newFakeMigrate(t *testing.T) *fakeMigrate {
+	fake := &fakeMigrate{imported: make(map[string]int), messages: make(map[string][]migrateMessage)}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// Records the imported count and messages of a migration
+func (fake *fakeMigrate) migrated(migration string, imported int, messages ...migrateMessage) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.imported[migration] = imported
+	fake.messages[migration] = messages
+}
+
+func (fake *fakeMigrate) serve(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if r.URL.Path == "/user/login" {
+		http.SetCookie(w, &http.Cookie{Name: "SESS", Value: "session", Path: "/"})
+		return
+	}
+	if _, err := r.Cookie("SESS"); err != nil {
+		http.Error(w, "access denied", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.URL.Path == "/admin/structure/migrate/manage/idc_ingest/migrations":
+		fmt.Fprint(w, "<table><thead><tr><th>Migration</th><th>Machine Name</th><th>Status</th><th>Total</th>"+
+			"<th>Imported</th><th>Unprocessed</th><th>Messages</th></tr></thead><tbody>")
+		for migration, imported := range fake.imported {
+			fmt.Fprintf(w, `<tr><td>%s</td><td>%s</td><td>Idle</td><td>N/A</td><td>%d</td><td>N/A</td><td><a href="#">%d</a></td></tr>`,
+				migration, migration, imported, len(fake.messages[migration]))
+		}
+		fmt.Fprint(w, "</tbody></table>")
+	case len(segments) == 8 && segments[7] == "messages":
+		messages, ok := fake.messages[segments[6]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		var page int
+		fmt.Sscanf(r.URL.Query().Get("page"), "%d", &page)
+		fmt.Fprint(w, "<table><thead><tr><th>Source ID(s) hash</th><th>Severity level</th><th>Message</th></tr></thead><tbody>")
+		if len(messages) == 0 {
+			fmt.Fprint(w, `<tr><td colspan="3">No messages for this migration.</td></tr>`)
+		}
+		for i := page * 2; i < len(messages) && i < page*2+2; i++ {
+			m := messages[i]
+			fmt.Fprintf(w, "<tr><td>%s</td><td>%s</td><td>%s</td></tr>", m.SourceIds, m.Level, m.Message)
+		}
+		fmt.Fprint(w, "</tbody></table>")
+		if len(messages) > page*2+2 {
+			fmt.Fprintf(w, `<a href="?page=%d" rel="next">Next</a>`, page+1)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Answers the CSVs of a migration, read from their content
+func fakeCsvs(t *testing.T, contents ...string) []*csvTable {
+	var tables []*csvTable
+	for i, content := range contents {
+		table, err := readCsv(fmt.Sprintf("%d.csv", i), strings.NewReader(content), nil)
+		assert.Nil(t, err)
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+func Test_CountSourceRows(t *testing.T) {
+	persons := fakeCsvs(t, "local_id,name\np1,Adams\np2,Baker\n", "local_id,name\np2,Baker Jr.\np3,Clark\n")
+	assert.Equal(t, 3, countSourceRows("idc_ingest_taxonomy_persons", persons))
+
+	items := fakeCsvs(t, "node_id,local_id,title\n,i1,One\n,i2,Two\n", "node_id,local_id,title\n,i1,One\n12,i1,One\n")
+	assert.Equal(t, 3, countSourceRows("idc_ingest_new_items", items))
+}
+
+func Test_VerifyMigrations_Imported(t *testing.T) {
+	fake := newFakeMigrate(t)
+	fake.migrated("idc_ingest_taxonomy_persons", 3)
+	fake.migrated("idc_ingest_new_items", 2, migrateMessage{"abc", "Informational", "Deprecated value"})
+	fake.migrated("idc_ingest_media_image", 0)
+
+	report := newReport("migrations", fake.URL)
+	newJsonApiClient(fake.URL, "admin", "password").verifyMigrations(DefaultMigrationGroup, map[string][]*csvTable{
+		"idc_ingest_taxonomy_persons": fakeCsvs(t, "local_id,name\np1,Adams\np2,Baker\n", "local_id,name\np2,Baker Jr.\np3,Clark\n"),
+		"idc_ingest_new_items":        fakeCsvs(t, "node_id,local_id,title\n,i1,One\n,i2,Two\n"),
+	}, report)
+
+	assert.False(t, report.failed(), "unexpected findings: %v", report.Findings)
+	assert.Equal(t, 1, len(report.Findings))
+	assert.Equal(t, "Deprecated value", report.Findings[0].Message)
+	assert.Equal(t, 2, report.Checked)
+	assert.Equal(t, 5, report.Counts["imported"])
+	assert.Equal(t, 1, report.Counts["ignored"])
+}
+
+func Test_VerifyMigrations_NotImported(t *testing.T) {
+	fake := newFakeMigrate(t)
+	fake.migrated("idc_ingest_new_items", 1,
+		migrateMessage{"abc", "Error", "Missing collection: Collection B"},
+		migrateMessage{"abc", "Warning", "Empty title"},
+		migrateMessage{"def", "1", "Invalid EDTF date: 2021-13"},
+	)
+
+	report := newReport("migrations", fake.URL)
+	newJsonApiClient(fake.URL, "admin", "password").verifyMigrations(DefaultMigrationGroup, map[string][]*csvTable{
+		"idc_ingest_new_items":  fakeCsvs(t, "node_id,local_id,title\n,i1,One\n,i2,Two\n,i3,Three\n"),
+		"idc_ingest_new_genres": fakeCsvs(t, "local_id,name\ng1,Poetry\n"),
+	}, report)
+	assert.True(t, report.failed())
+
+	var problems []string
+	for _, f := range report.Findings {
+		problems = append(problems, fmt.Sprintf("%s: %s [%s] [%s] [%s] %s", f.Level, f.Subject, f.Field, f.Expected, f.Actual, f.Message))
+	}
+	assert.Equal(t, []string{
+		"error: idc_ingest_new_genres [] [] [] the migration is not in the idc_ingest group",
+		"error: idc_ingest_new_items [imported] [3] [1] 2 of 3 source rows were not imported (2 failed, 0 ignored)",
+		"error: idc_ingest_new_items [abc] [] [Error] Missing collection: Collection B",
+		"error: idc_ingest_new_items [abc] [] [Warning] Empty title",
+		"error: idc_ingest_new_items [def] [] [1] Invalid EDTF date: 2021-13",
+	}, problems)
+	assert.Equal(t, 2, report.Checked)
+	assert.Equal(t, 2, report.Counts["failed"])
+}
+
+func Test_VerifyMigrations_Forbidden(t *testing.T) {
+	fake := newFakeMigrate(t)
+
+	report := newReport("migrations", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyMigrations(DefaultMigrationGroup, map[string][]*csvTable{
+		"idc_ingest_new_items": fakeCsvs(t, "node_id,local_id,title\n,i1,One\n"),
+	}, report)
+
+	assert.True(t, report.failed())
+	assert.Equal(t, 1, len(report.Findings))
+	assert.Contains(t, report.Findings[0].Message, ErrForbidden.Error())
+}
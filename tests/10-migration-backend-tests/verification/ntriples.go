@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Predicates, as full IRIs, of the RDF produced by Islandora for Drupal entities
+const (
+	DctermsTitle = "http://purl.org/dc/terms/title"
+	PcdmMemberOf = "http://pcdm.org/models#memberOf"
+	PcdmFileOf   = "http://pcdm.org/models#fileOf"
+	SchemaKnows  = "http://schema.org/knows"
+	RdfType      = "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
+)
+
+// A single RDF statement.  The subject and predicate are IRIs (or, for the subject, a blank node label like "_:b0").
+// If Literal is true, the object is the lexical form of a literal, otherwise it is an IRI or blank node label.
+type triple struct {
+	Subject   string
+	Predicate string
+	Object    string
+	Literal   bool
+	Lang      string
+	Datatype  string
+}
+
+// Answers the objects of every triple with the supplied predicate
+func objects(triples []triple, predicate string) []triple {
+	var matches []triple
+	for _, t := range triples {
+		if t.Predicate == predicate {
+			matches = append(matches, t)
+		}
+	}
+	return matches
+}
+
+// Parses an N-Triples document (https://www.w3.org/TR/n-triples/).  Comments and blank lines are ignored.
+func parseNTriples(r io.Reader) ([]triple, error) {
+	var (
+		triples []triple
+		scanner = bufio.NewScanner(r)
+		line    = 0
+	)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line++
+		s := strings.TrimSpace(scanner.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+
+		t, err := parseNTriple(s)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing N-Triples line %d: %w", line, err)
+		}
+		triples = append(triples, t)
+	}
+
+	return triples, scanner.Err()
+}
+
+// Parses a single N-Triples statement, e.g. `<http://a> <http://b> "c"@en .`
+func parseNTriple(s string) (triple, error) {
+	var (
+		t   triple
+		err error
+	)
+
+	if t.Subject, s, err = parseNTriplesTerm(s); err != nil {
+		return t, err
+	}
+	if t.Predicate, s, err = parseNTriplesTerm(s); err != nil {
+		return t, err
+	}
+
+	if strings.HasPrefix(s, "\"") {
+		t.Literal = true
+		if t.Object, s, err = parseNTriplesLiteral(s); err != nil {
+			return t, err
+		}
+		switch {
+		case strings.HasPrefix(s, "@"):
+			end := strings.IndexAny(s, " \t.")
+			if end < 0 {
+				return t, fmt.Errorf("unterminated language tag")
+			}
+			t.Lang, s = s[1:end], strings.TrimSpace(s[end:])
+		case strings.HasPrefix(s, "^^"):
+			if t.Datatype, s, err = parseNTriplesTerm(s[2:]); err != nil {
+				return t, err
+			}
+		}
+	} else if t.Object, s, err = parseNTriplesTerm(s); err != nil {
+		return t, err
+	}
+
+	if s != "." {
+		return t, fmt.Errorf("expected '.' at end of statement, found '%s'", s)
+	}
+	return t, nil
+}
+
+// Parses an IRI (`<...>`) or blank node label (`_:...`) from the start of s, answering the term and the remainder of s
+func parseNTriplesTerm(s string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(s, "<"):
+		end := strings.Index(s, ">")
+		if end < 0 {
+			return "", s, fmt.Errorf("unterminated IRI")
+		}
+		iri, err := unescapeNTriples(s[1:end])
+		return iri, strings.TrimSpace(s[end+1:]), err
+	case strings.HasPrefix(s, "_:"):
+		end := strings.IndexAny(s, " \t")
+		if end < 0 {
+			return "", s, fmt.Errorf("unterminated blank node label")
+		}
+		return s[:end], strings.TrimSpace(s[end:]), nil
+	}
+	return "", s, fmt.Errorf("expected an IRI or blank node, found '%s'", s)
+}
+
+// Parses a quoted literal from the start of s, answering its unescaped lexical form and the remainder of s
+func parseNTriplesLiteral(s string) (string, string, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			lexical, err := unescapeNTriples(s[1:i])
+			return lexical, strings.TrimSpace(s[i+1:]), err
+		}
+	}
+	return "", s, fmt.Errorf("unterminated literal")
+}
+
+// Replaces the escape sequences permitted by N-Triples (e.g. `\n`, `\"`, `\u00e9`)
+func unescapeNTriples(s string) (string, error) {
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'b':
+			b.WriteByte('\b')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case '"', '\'', '\\':
+			b.WriteByte(s[i])
+		case 'u', 'U':
+			n := 4
+			if s[i] == 'U' {
+				n = 8
+			}
+			if i+n >= len(s) {
+				return "", fmt.Errorf("truncated escape sequence in '%s'", s)
+			}
+			r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape sequence in '%s': %w", s, err)
+			}
+			b.WriteRune(rune(r))
+			i += n
+		default:
+			return "", fmt.Errorf("invalid escape sequence '\\%c'", s[i])
+		}
+	}
+	return b.String(), nil
+}
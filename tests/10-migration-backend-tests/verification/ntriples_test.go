@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseNTriples(t *testing.T) {
+	doc := `# a comment
+<http://fcrepo/rest/3c/5f> <http://purl.org/dc/terms/title> "Repository Item \"One\" caf\u00e9"@en .
+
+<http://fcrepo/rest/3c/5f> <http://pcdm.org/models#memberOf> <http://islandora/node/1?_format=jsonld> .
+<http://fcrepo/rest/3c/5f> <http://www.loc.gov/premis/rdf/v1#hasSize> "1024"^^<http://www.w3.org/2001/XMLSchema#integer> .
+_:b0 <http://schema.org/knows> _:b1 .
+`
+	triples, err := parseNTriples(strings.NewReader(doc))
+	assert.Nil(t, err)
+	assert.Equal(t, []triple{
+		{Subject: "http://fcrepo/rest/3c/5f", Predicate: DctermsTitle, Object: "Repository Item \"One\" café", Literal: true, Lang: "en"},
+		{Subject: "http://fcrepo/rest/3c/5f", Predicate: PcdmMemberOf, Object: "http://islandora/node/1?_format=jsonld"},
+		{Subject: "http://fcrepo/rest/3c/5f", Predicate: PremisHasSize, Object: "1024", Literal: true, Datatype: "http://www.w3.org/2001/XMLSchema#integer"},
+		{Subject: "_:b0", Predicate: SchemaKnows, Object: "_:b1"},
+	}, triples)
+	assert.Equal(t, 1, len(objects(triples, PcdmMemberOf)))
+}
+
+func Test_ParseNTriples_Malformed(t *testing.T) {
+	for _, doc := range []string{
+		`<http://a> <http://b> "unterminated .`,
+		`<http://a> <http://b> <http://c>`,
+		`<http://a> "literal subject" <http://c> .`,
+		`<http://a> <http://b> "bad escape \q" .`,
+		`<http://a> <http://b> "truncated \u00" .`,
+	} {
+		_, err := parseNTriples(strings.NewReader(doc))
+		assert.NotNil(t, err, "expected an error parsing %s", doc)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+)
+
+// The entity types crawled to find orphans
+var orphanEntities = map[string]bool{
+	"node":  true,
+	"media": true,
+	"file":  true,
+}
+
+// Answers the size of a file resource in bytes, or zero if it is unknown
+func fileSize(file *JsonApiResource) int64 {
+	if size, ok := file.Attributes["filesize"].(float64); ok {
+		return int64(size)
+	}
+	return 0
+}
+
+// Answers the uri of a file resource, e.g. "private://04/7f/86/c0c26cf42ee9c6eb17910599d3802d2f98"
+func fileUri(file *JsonApiResource) string {
+	uri, _ := file.Attributes["uri"].(map[string]interface{})
+	return scalarString(uri["value"])
+}
+
+// Finds orphaned media and files, reporting each orphan with its size.
+//
+// A media is orphaned if it is not the media of any node that exists: its `field_media_of` is empty, or refers only to
+// deleted nodes.  A file is orphaned if no media refers to it.  Files that are referred to only by orphaned media are
+// counted toward the storage reclaimed by deleting the media.  Many File entities may share a single uri (see
+// `Test_VerifyDuplicateMediaAndFile`), so storage is only counted as reclaimable if no remaining file shares its uri.
+//
+// Unpublished nodes are not accessible to anonymous users, so orphans should be found as an administrator.
+func (c *jsonApiClient) findOrphans(report *Report) {
+	idx := newCrawlIndex(c.crawl(report, "orphans", orphanEntities))
+
+	var (
+		// media keyed by the id of each file they refer to
+		referrers = make(map[string][]*JsonApiResource)
+		orphaned  = make(map[string]bool)
+		media     []*JsonApiResource
+	)
+	for _, t := range sortedTypes(idx.crawled) {
+		if t.entity() != "media" {
+			continue
+		}
+		for i := range idx.crawled[t] {
+			res := &idx.crawled[t][i]
+			media = append(media, res)
+			for _, field := range res.relationshipNames() {
+				for _, target := range res.related(field) {
+					if target.Type == "file--file" {
+						referrers[target.Id] = append(referrers[target.Id], res)
+					}
+				}
+			}
+			if _, hasMediaOf := relationshipRules[t]["field_media_of"]; hasMediaOf && !c.isMediaOfNode(idx, res) {
+				orphaned[res.Id] = true
+			}
+		}
+	}
+
+	// the uris of files that remain after the orphans are deleted
+	retained := make(map[string]bool)
+	for id, refs := range referrers {
+		for _, ref := range refs {
+			if file, ok := idx.byId[id]; ok && !orphaned[ref.Id] && fileUri(file) != "" {
+				retained[fileUri(file)] = true
+			}
+		}
+	}
+
+	// bytes are only counted once for each uri
+	reclaimed := make(map[string]bool)
+	reclaim := func(file *JsonApiResource) int64 {
+		uri := fileUri(file)
+		if uri == "" {
+			return fileSize(file)
+		}
+		if retained[uri] || reclaimed[uri] {
+			return 0
+		}
+		reclaimed[uri] = true
+		return fileSize(file)
+	}
+
+	var reclaimable int64
+	for _, res := range media {
+		report.Checked++
+		if !orphaned[res.Id] {
+			continue
+		}
+
+		var size int64
+		for _, field := range res.relationshipNames() {
+			for _, target := range res.related(field) {
+				if file, ok := idx.byId[target.Id]; ok && target.Type == "file--file" {
+					size += reclaim(file)
+				}
+			}
+		}
+		reclaimable += size
+		report.Counts["orphaned media"]++
+
+		message := fmt.Sprintf("'%s' is not the media of any node", res.label())
+		if len(res.related("field_media_of")) > 0 {
+			message = fmt.Sprintf("'%s' is the media of a deleted node", res.label())
+		}
+		report.add(Finding{
+			Level:   LevelWarning,
+			Check:   "orphans",
+			Subject: res.String(),
+			Field:   "field_media_of",
+			Message: fmt.Sprintf("%s (%d bytes reclaimable)", message, size),
+		})
+	}
+
+	files := idx.crawled["file--file"]
+	for i := range files {
+		file := &files[i]
+		report.Checked++
+		if len(referrers[file.Id]) > 0 {
+			continue
+		}
+
+		size := reclaim(file)
+		reclaimable += size
+		report.Counts["orphaned files"]++
+
+		report.add(Finding{
+			Level:   LevelWarning,
+			Check:   "orphans",
+			Subject: file.String(),
+			Message: fmt.Sprintf("'%s' (%s, %d bytes) is not referred to by any media (%d bytes reclaimable)",
+				file.label(), fileUri(file), fileSize(file), size),
+		})
+	}
+
+	report.Counts["reclaimable bytes"] = int(reclaimable)
+}
+
+// Answers true if the media is the media of at least one node that exists
+func (c *jsonApiClient) isMediaOfNode(idx *crawlIndex, media *JsonApiResource) bool {
+	for _, target := range media.related("field_media_of") {
+		if c.dangling(idx, target) == "" {
+			return true
+		}
+	}
+	return false
+}
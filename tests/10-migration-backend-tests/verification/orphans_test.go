@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FindOrphans_None(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateIntegrityFake(fake)
+
+	report := newReport("orphans", fake.URL)
+	newJsonApiClient(fake.URL, "", "").findOrphans(report)
+
+	assert.Equal(t, 0, len(report.Findings), "unexpected findings: %v", report.Findings)
+	assert.Equal(t, 2, report.Checked)
+	assert.Equal(t, 0, report.Counts["reclaimable bytes"])
+}
+
+func Test_FindOrphans(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateIntegrityFake(fake)
+	fake.add(
+		fakeResource{
+			Type:       "media--document",
+			Id:         "orphaned-document",
+			Attributes: map[string]interface{}{"name": "Orphaned Document"},
+			Relationships: map[string]interface{}{
+				"field_media_of":       []fakeRelationship{},
+				"field_media_document": fakeRelationship{Type: "file--file", Id: "document-file"},
+			},
+		},
+		fakeResource{
+			Type:       "media--image",
+			Id:         "deleted-parent",
+			Attributes: map[string]interface{}{"name": "Image of a Deleted Node"},
+			Relationships: map[string]interface{}{
+				"field_media_of":    []fakeRelationship{{Type: "node--islandora_object", Id: missingId}},
+				"field_media_image": fakeRelationship{Type: "file--file", Id: "image-file"},
+				// shared with the media of Repository Item One, which remains
+				"thumbnail": fakeRelationship{Type: "file--file", Id: imageFileId},
+			},
+		},
+		fakeFile("document-file", "private://2021-04/document.pdf", 100),
+		fakeFile("image-file", "private://2021-04/deleted.jpg", 200),
+		fakeFile("unreferenced-file", "private://2021-04/unreferenced.tiff", 300),
+		// shares its uri with the file of Image One, so no storage is reclaimed by deleting it
+		fakeFile("duplicate-file", "private://2021-04/image.jpg", 1024),
+	)
+
+	report := newReport("orphans", fake.URL)
+	newJsonApiClient(fake.URL, "", "").findOrphans(report)
+
+	assert.False(t, report.failed())
+	assert.Equal(t, 8, report.Checked)
+	assert.Equal(t, 2, report.Counts["orphaned media"])
+	assert.Equal(t, 2, report.Counts["orphaned files"])
+	assert.Equal(t, 600, report.Counts["reclaimable bytes"])
+
+	var messages []string
+	for _, f := range report.Findings {
+		assert.Equal(t, LevelWarning, f.Level)
+		messages = append(messages, f.Subject+": "+f.Message)
+	}
+	assert.Equal(t, []string{
+		"media--document orphaned-document: 'Orphaned Document' is not the media of any node (100 bytes reclaimable)",
+		"media--image deleted-parent: 'Image of a Deleted Node' is the media of a deleted node (200 bytes reclaimable)",
+		"file--file unreferenced-file: 'unreferenced.tiff' (private://2021-04/unreferenced.tiff, 300 bytes) is not referred to by any media (300 bytes reclaimable)",
+		"file--file duplicate-file: 'image.jpg' (private://2021-04/image.jpg, 1024 bytes) is not referred to by any media (0 bytes reclaimable)",
+	}, messages)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// A finding that fails verification
+	LevelError = "error"
+	// A finding worth a human's attention that does not fail verification
+	LevelWarning = "warning"
+)
+
+// A single problem discovered by idc-verify
+type Finding struct {
+	Level string `json:"level"`
+	// The check that produced the finding, e.g. "verify" or "audit"
+	Check string `json:"check"`
+	// What was checked, e.g. the name of a fixture, or the type and id of a resource
+	Subject  string `json:"subject"`
+	Field    string `json:"field,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (f Finding) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s [%s] %s", strings.ToUpper(f.Level), f.Check, f.Subject)
+	if f.Field != "" {
+		fmt.Fprintf(&b, " (%s)", f.Field)
+	}
+	fmt.Fprintf(&b, ": %s", f.Message)
+	if f.Expected != "" || f.Actual != "" {
+		fmt.Fprintf(&b, "\n    expected: %s\n    actual:   %s", f.Expected, f.Actual)
+	}
+	return b.String()
+}
+
+// The outcome of an idc-verify command.  Reports are written as text for humans, or as JSON so that they can be
+// archived and later summarized by the `report` command.
+type Report struct {
+	Command string    `json:"command"`
+	Target  string    `json:"target"`
+	Started time.Time `json:"started"`
+	// The number of subjects (fixtures, resources, files, ...) that were checked
+	Checked  int       `json:"checked"`
+	Findings []Finding `json:"findings"`
+	// Tallies kept by the command, e.g. the number of resources crawled per resource type
+	Counts map[string]int `json:"counts,omitempty"`
+	// The fixity of every file checked by the `fixity` command
+	Fixity []FixityRecord `json:"fixity,omitempty"`
+	// The technical metadata of every media checked by the `techmd` command
+	Technical []TechnicalRecord `json:"technical,omitempty"`
+}
+
+// The outcome of checking the fixity of a single file.  A record is kept whether or not the check succeeds, so that
+// the report may serve as an audit trail.
+type FixityRecord struct {
+	// The id of the File entity
+	File string `json:"file"`
+	// The uri of the file, e.g. "private://c9/a0/60/c39365820edc5d1a51f221d49e96a8a730"
+	Uri  string `json:"uri"`
+	Size int64  `json:"size"`
+	// Hex encoded checksums of the content of the file, keyed by algorithm (e.g. "sha1")
+	Checksums map[string]string `json:"checksums"`
+	Checked   time.Time         `json:"checked"`
+	// True if the content of the file was retrieved, and its checksum and size agree with Drupal
+	Ok bool `json:"ok"`
+}
+
+// The technical metadata of the file of a media, as computed from its content
+type TechnicalRecord struct {
+	// The ids of the media and its File entity
+	Media string `json:"media"`
+	File  string `json:"file"`
+	Size  int64  `json:"size"`
+	// The MIME type identified from the content of the file
+	MimeType string `json:"mime_type"`
+	// The dimensions of images and videos
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+	// The duration in seconds, and the codecs, of audio and video
+	Duration float64 `json:"duration,omitempty"`
+	Codec    string  `json:"codec,omitempty"`
+	// True if the technical metadata agrees with Drupal
+	Ok bool `json:"ok"`
+}
+
+func newReport(command, target string) *Report {
+	return &Report{
+		Command:  command,
+		Target:   target,
+		Started:  time.Now(),
+		Findings: []Finding{},
+		Counts:   make(map[string]int),
+	}
+}
+
+func (r *Report) error(check, subject, format string, args ...interface{}) {
+	r.Findings = append(r.Findings, Finding{Level: LevelError, Check: check, Subject: subject, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) warning(check, subject, format string, args ...interface{}) {
+	r.Findings = append(r.Findings, Finding{Level: LevelWarning, Check: check, Subject: subject, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) add(f Finding) {
+	r.Findings = append(r.Findings, f)
+}
+
+// Answers true if the report contains a finding at LevelError
+func (r *Report) failed() bool {
+	for _, f := range r.Findings {
+		if f.Level == LevelError {
+			return true
+		}
+	}
+	return false
+}
+
+// Answers the number of findings at the given level
+func (r *Report) count(level string) int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Level == level {
+			n++
+		}
+	}
+	return n
+}
+
+// Writes the report in the named format, either "text" or "json"
+func (r *Report) write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case "text":
+		return r.writeText(w)
+	}
+	return fmt.Errorf("unknown output format '%s' (expected 'text' or 'json')", format)
+}
+
+func (r *Report) writeText(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "idc-verify %s of %s, started %s\n", r.Command, r.Target, r.Started.Format(time.RFC3339))
+	for _, f := range r.Findings {
+		fmt.Fprintf(&b, "%s\n", f)
+	}
+
+	if len(r.Counts) > 0 {
+		var keys []string
+		for k := range r.Counts {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%8d %s\n", r.Counts[k], k)
+		}
+	}
+
+	for _, fr := range r.Fixity {
+		status := "ok"
+		if !fr.Ok {
+			status = "FAILED"
+		}
+		var algorithms []string
+		for algorithm := range fr.Checksums {
+			algorithms = append(algorithms, algorithm)
+		}
+		sort.Strings(algorithms)
+		fmt.Fprintf(&b, "%-6s %s %12d", status, fr.File, fr.Size)
+		for _, algorithm := range algorithms {
+			fmt.Fprintf(&b, " %s:%s", algorithm, fr.Checksums[algorithm])
+		}
+		fmt.Fprintf(&b, " %s\n", fr.Uri)
+	}
+
+	for _, tr := range r.Technical {
+		status := "ok"
+		if !tr.Ok {
+			status = "FAILED"
+		}
+		fmt.Fprintf(&b, "%-6s %s %s %12d %s", status, tr.Media, tr.File, tr.Size, tr.MimeType)
+		if tr.Width > 0 || tr.Height > 0 {
+			fmt.Fprintf(&b, " %dx%d", tr.Width, tr.Height)
+		}
+		if tr.Duration > 0 {
+			fmt.Fprintf(&b, " %.3fs", tr.Duration)
+		}
+		if tr.Codec != "" {
+			fmt.Fprintf(&b, " %s", tr.Codec)
+		}
+		fmt.Fprintf(&b, "\n")
+	}
+
+	status := "PASS"
+	if r.failed() {
+		status = "FAIL"
+	}
+	fmt.Fprintf(&b, "%s: checked %d, %d error(s), %d warning(s)\n", status, r.Checked, r.count(LevelError), r.count(LevelWarning))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Answers the entities of the resource type whose field is equal to value.  A resource type that is not found has no
+// entities at all.
+func (c *jsonApiClient) findAny(t DrupalType, field, value string) ([]JsonApiResource, error) {
+	resources, err := c.find(t, field, value)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return resources, err
+}
+
+// Answers the quads of a cell of an entity lookup column, with the defaults of the column applied.  The relator of a
+// typed relation (e.g. "relators:art;") is dropped.
+func cellQuads(column, cell string) []entityQuad {
+	var quads []entityQuad
+	for _, v := range strings.Split(cell, csvValueSeparator) {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		if i := strings.Index(v, ";"); typedRelationColumns[column] && i >= 0 {
+			v = v[i+1:]
+		}
+		quads = append(quads, parseQuad(v, quadDefaults[column]))
+	}
+	return quads
+}
+
+// Verifies the entity references of the nodes migrated from ingest CSVs, as resolved by `parse_entity_lookup` (see
+// `13-migration-entity-resolution`).  Each node of the resource type is found by the title in its row.  For every quad
+// in an entity lookup column (e.g. `::name:My Subject` in subject), exactly one entity of the intended bundle must have
+// the value, otherwise the lookup is ambiguous or a stub was created, and the field of the node must refer to it.
+// Entities of the other bundles the field permits that have the same value (e.g. a geo_location named like a subject)
+// are counted, and referring to one is an error: the quad resolved to the wrong bundle.  The field must not refer to
+// anything other than the entities its quads resolve to.
+func (c *jsonApiClient) verifyEntityResolution(t DrupalType, tables []*csvTable, report *Report) {
+	for _, table := range tables {
+		var columns []string
+		for _, column := range table.columns {
+			if _, ok := quadDefaults[column]; ok {
+				columns = append(columns, column)
+			}
+		}
+		if len(columns) == 0 {
+			report.warning("resolution", table.path, "the CSV has no entity lookup columns, and was not compared")
+			continue
+		}
+
+		for _, row := range table.rows {
+			subject := fmt.Sprintf("%s %s", table.path, rowKey(row))
+			nodes, err := c.find(t, "title", row["title"])
+			if err != nil {
+				report.error("resolution", subject, "unable to find the %s '%s': %s", t, row["title"], err)
+				continue
+			}
+			if len(nodes) != 1 {
+				report.error("resolution", subject, "%d %s are titled '%s', expected 1", len(nodes), t, row["title"])
+				continue
+			}
+			report.Checked++
+
+			for _, column := range columns {
+				if strings.TrimSpace(row[column]) == "" {
+					continue
+				}
+				mapping, ok := csvColumnField(t, column)
+				if !ok {
+					report.warning("resolution", table.path, "the column '%s' is not mapped to a field of %s, and was not compared", column, t)
+					continue
+				}
+				c.verifyResolvedField(&nodes[0], column, mapping.field, cellQuads(column, row[column]), report)
+			}
+		}
+	}
+}
+
+// Verifies the targets of the field of a node are the entities the quads of its column resolve to
+func (c *jsonApiClient) verifyResolvedField(node *JsonApiResource, column, field string, quads []entityQuad, report *Report) {
+	targets := node.related(field)
+	referred := func(id string) bool {
+		for _, target := range targets {
+			if target.Id == id {
+				return true
+			}
+		}
+		return false
+	}
+	fail := func(expected, actual, format string, args ...interface{}) {
+		report.add(Finding{
+			Level:    LevelError,
+			Check:    "resolution",
+			Subject:  node.String(),
+			Field:    field,
+			Expected: expected,
+			Actual:   actual,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	resolved := make(map[string]bool)
+	for _, q := range quads {
+		report.Counts["lookups"]++
+		intended := DrupalType(q.entityType + "--" + q.bundle)
+		candidates, err := c.findAny(intended, q.valueKey, q.value)
+		if err != nil {
+			fail("", "", "unable to find the %s with %s '%s': %s", intended, q.valueKey, q.value, err)
+			continue
+		}
+
+		// entities of the other bundles the field may refer to, with the same value
+		for _, other := range relationshipRules[node.Type][field].targets {
+			if other == intended || other.bundle() == "" || other.entity() != intended.entity() {
+				continue
+			}
+			homonyms, err := c.findAny(other, q.valueKey, q.value)
+			if err != nil {
+				fail("", "", "unable to find the %s with %s '%s': %s", other, q.valueKey, q.value, err)
+				continue
+			}
+			for _, h := range homonyms {
+				report.Counts["homonyms"]++
+				if referred(h.Id) {
+					resolved[h.Id] = true
+					fail(string(intended), string(other), "the %s '%s' of '%s' resolved to a %s rather than a %s", column, q, node.label(), other, intended)
+				}
+			}
+		}
+
+		switch {
+		case len(candidates) == 0:
+			fail(q.String(), "", "no %s has the %s '%s' of '%s' as its %s", intended, column, q.value, node.label(), q.valueKey)
+		case len(candidates) > 1:
+			var ids []string
+			for _, candidate := range candidates {
+				ids = append(ids, candidate.Id)
+				resolved[candidate.Id] = true
+			}
+			fail("1", fmt.Sprint(len(candidates)), "%d %s have the %s '%s' of '%s' as their %s, the lookup is ambiguous or stubs were created: %s",
+				len(candidates), intended, column, q.value, node.label(), q.valueKey, strings.Join(ids, ", "))
+		case !referred(candidates[0].Id):
+			fail(candidates[0].String(), "", "the %s '%s' of '%s' was not resolved to the %s '%s'", column, q, node.label(), intended, candidates[0].label())
+		default:
+			resolved[candidates[0].Id] = true
+			report.Counts["resolved"]++
+		}
+	}
+
+	for _, target := range targets {
+		if resolved[target.Id] || target.Id == missingId {
+			continue
+		}
+		label := target.Id
+		if res, err := c.resolve(target.JsonApiData); err == nil {
+			label = res.label()
+		}
+		fail("", fmt.Sprintf("%s %s", target.Type, target.Id), "'%s' refers to the %s '%s', which no %s of its row resolves to: it may be a stub",
+			node.label(), target.Type, label, column)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The repository item CSV of 13-migration-entity-resolution
+const resolutionCsv = `node_id,local_id,title,description,member_of,subject
+,io_01,Sample Repository Item,Test repository object;eng,::title:Images Collection,::name:My Subject
+`
+
+// Populates the fake with the entities migrated by 13-migration-entity-resolution, along with a geo_location named
+// like the subject, and a repository item referring to the supplied collection and subjects
+func populateResolutionFake(fake *fakeJsonApi, memberOf string, subjects ...fakeRelationship) {
+	fake.add(
+		fakeNode("node--collection_object", "images-collection", "Images Collection", nil),
+		fakeTaxonomyTerm("subject", "my-subject", "My Subject", nil),
+		fakeTaxonomyTerm("geo_location", "my-subject-place", "My Subject", nil),
+		fakeResource{
+			Type:       "node--islandora_object",
+			Id:         "sample-item",
+			Attributes: map[string]interface{}{"title": "Sample Repository Item"},
+			Relationships: map[string]interface{}{
+				"field_member_of": []fakeRelationship{{Type: "node--collection_object", Id: memberOf}},
+				"field_subject":   subjects,
+			},
+		},
+	)
+}
+
+func readResolutionCsv(t *testing.T) []*csvTable {
+	table, err := readCsv("islandora_object.csv", strings.NewReader(resolutionCsv), nil)
+	assert.Nil(t, err)
+	return []*csvTable{table}
+}
+
+func Test_CellQuads(t *testing.T) {
+	assert.Equal(t, []entityQuad{
+		{"node", "collection_object", "title", "Collection A"},
+		{"node", "islandora_object", "title", "Item B"},
+	}, cellQuads("member_of", ":::Collection A|:islandora_object::Item B"))
+	assert.Equal(t, []entityQuad{
+		{"taxonomy_term", "person", "name", "Adams, Ansel"},
+		{"taxonomy_term", "family", "name", "Weston"},
+	}, cellQuads("creator", "relators:art;:::Adams, Ansel|relators:pht;:family::Weston"))
+}
+
+func Test_VerifyEntityResolution_Resolved(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateResolutionFake(fake, "images-collection", fakeRelationship{Type: "taxonomy_term--subject", Id: "my-subject"})
+
+	report := newReport("resolution", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyEntityResolution("node--islandora_object", readResolutionCsv(t), report)
+
+	assert.Equal(t, 0, len(report.Findings), "unexpected findings: %v", report.Findings)
+	assert.Equal(t, 1, report.Checked)
+	assert.Equal(t, 2, report.Counts["lookups"])
+	assert.Equal(t, 2, report.Counts["resolved"])
+	assert.Equal(t, 1, report.Counts["homonyms"])
+}
+
+func Test_VerifyEntityResolution_Unresolved(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	// the subject resolved to the geo_location, and a stub collection and person were created
+	populateResolutionFake(fake, "stub-collection",
+		fakeRelationship{Type: "taxonomy_term--geo_location", Id: "my-subject-place"},
+		fakeRelationship{Type: "taxonomy_term--person", Id: "stub-person"})
+	fake.add(
+		fakeNode("node--collection_object", "stub-collection", "Images Collection", nil),
+		fakeTaxonomyTerm("person", "stub-person", "My Subject", nil),
+	)
+
+	report := newReport("resolution", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyEntityResolution("node--islandora_object", readResolutionCsv(t), report)
+	assert.True(t, report.failed())
+
+	var problems []string
+	for _, f := range report.Findings {
+		problems = append(problems, fmt.Sprintf("%s [%s] %s", f.Subject, f.Field, f.Message))
+	}
+	assert.Equal(t, []string{
+		"node--islandora_object sample-item [field_member_of] 2 node--collection_object have the member_of 'Images Collection' of 'Sample Repository Item' as their title, the lookup is ambiguous or stubs were created: images-collection, stub-collection",
+		"node--islandora_object sample-item [field_subject] the subject 'taxonomy_term:subject:name:My Subject' of 'Sample Repository Item' resolved to a taxonomy_term--geo_location rather than a taxonomy_term--subject",
+		"node--islandora_object sample-item [field_subject] the subject 'taxonomy_term:subject:name:My Subject' of 'Sample Repository Item' resolved to a taxonomy_term--person rather than a taxonomy_term--subject",
+		"node--islandora_object sample-item [field_subject] the subject 'taxonomy_term:subject:name:My Subject' of 'Sample Repository Item' was not resolved to the taxonomy_term--subject 'My Subject'",
+	}, problems)
+	assert.Equal(t, 2, report.Counts["homonyms"])
+}
+
+func Test_VerifyEntityResolution_Stub(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateResolutionFake(fake, "images-collection",
+		fakeRelationship{Type: "taxonomy_term--subject", Id: "my-subject"},
+		fakeRelationship{Type: "taxonomy_term--subject", Id: "stub-subject"})
+	fake.add(fakeTaxonomyTerm("subject", "stub-subject", "Unknown Subject", nil))
+
+	report := newReport("resolution", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyEntityResolution("node--islandora_object", readResolutionCsv(t), report)
+
+	assert.Equal(t, 1, len(report.Findings))
+	assert.Equal(t, "'Sample Repository Item' refers to the taxonomy_term--subject 'Unknown Subject', which no subject of its row resolves to: it may be a stub",
+		report.Findings[0].Message)
+}
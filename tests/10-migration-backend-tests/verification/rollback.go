@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+)
+
+// An entity recorded by a snapshot
+type snapshotEntity struct {
+	Type  DrupalType `json:"type"`
+	Label string     `json:"label"`
+	// the SHA-1 of the attributes and relationships of the entity, which changes if the entity is modified
+	Digest string `json:"digest"`
+	// the download URL and content address of a file
+	Url     string `json:"url,omitempty"`
+	Address string `json:"address,omitempty"`
+	// the id of the current revision of a node or media (its drupal_internal__vid)
+	Revision string `json:"revision,omitempty"`
+}
+
+// The nodes, media, files and taxonomy terms of an IDC instance at a point in time, keyed by UUID.  Snapshots taken
+// before a migration, after it, and after it is rolled back identify the entities the migration created.
+type entitySnapshot struct {
+	BaseUrl  string                    `json:"baseUrl"`
+	Taken    time.Time                 `json:"taken"`
+	Entities map[string]snapshotEntity `json:"entities"`
+}
+
+// Answers the SHA-1 of the attributes and relationships of a resource
+func digestOf(res *JsonApiResource) string {
+	b, _ := json.Marshal(struct {
+		Attributes    map[string]interface{}
+		Relationships map[string]JsonApiRelationship
+	}{res.Attributes, res.Relationships})
+	return fmt.Sprintf("%x", sha1.Sum(b))
+}
+
+// Crawls every node, media, file and taxonomy term, answering a snapshot of them.  Resource types that cannot be
+// crawled are added to the report.
+func (c *jsonApiClient) snapshot(report *Report) *entitySnapshot {
+	s := &entitySnapshot{BaseUrl: c.baseUrl, Taken: time.Now(), Entities: make(map[string]snapshotEntity)}
+	for t, resources := range c.crawl(report, "snapshot", auditedEntities) {
+		for i := range resources {
+			res := &resources[i]
+			e := snapshotEntity{Type: t, Label: res.label(), Digest: digestOf(res), Revision: scalarString(res.Attributes["drupal_internal__vid"])}
+			if res.Type.entity() == "file" {
+				uri, _ := res.Attributes["uri"].(map[string]interface{})
+				e.Url = scalarString(uri["url"])
+				e.Address = contentAddress(scalarString(uri["value"]))
+			}
+			s.Entities[res.Id] = e
+		}
+	}
+	return s
+}
+
+// Reads a snapshot written by the snapshot command
+func readSnapshot(path string) (*entitySnapshot, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &entitySnapshot{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("error unmarshaling snapshot %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Answers the ids of the entities of the snapshot, sorted
+func (s *entitySnapshot) ids() []string {
+	ids := make([]string, 0, len(s.Entities))
+	for id := range s.Entities {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Verifies that rolling back a migration removed exactly what it created.  The entities created by the migration are
+// those of the after snapshot, taken after the migration, that are not in the before snapshot, taken before it.
+//
+// Every created entity must answer 404 from JSONAPI.  The bytes of a created file must be removed as well (its
+// download URL answers 404), unless the file shares its content address with a file that existed before the migration:
+// ingest deduplicates files by content, so those bytes are retained, and must still be downloadable.  Every entity of
+// the before snapshot, including the entities referred to by the lookups of the migration, must be untouched: present,
+// and unmodified.
+func (c *jsonApiClient) verifyRollback(before, after *entitySnapshot, report *Report) {
+	current := c.snapshot(report)
+
+	// the pre-existing files, keyed by content address
+	retained := make(map[string]string)
+	for _, id := range before.ids() {
+		if e := before.Entities[id]; e.Address != "" {
+			retained[e.Address] = id
+		}
+	}
+
+	for _, id := range after.ids() {
+		if _, ok := before.Entities[id]; ok {
+			continue
+		}
+		e := after.Entities[id]
+		subject := fmt.Sprintf("%s %s", e.Type, id)
+		report.Checked++
+		report.Counts["created"]++
+
+		if _, err := c.resolve(JsonApiData{Type: e.Type, Id: id}); err == nil {
+			report.error("rollback", subject, "'%s' was created by the migration, but was not removed by rollback", e.Label)
+			continue
+		} else if !errors.Is(err, ErrNotFound) {
+			report.error("rollback", subject, "unable to determine whether '%s' was removed: %s", e.Label, err)
+			continue
+		}
+		report.Counts["removed"]++
+
+		if e.Url == "" {
+			continue
+		}
+		if sharer, ok := retained[e.Address]; ok && e.Address != "" {
+			report.Counts["retained files"]++
+			if _, err := c.download(before.Entities[sharer].Url, ioutil.Discard); err != nil {
+				report.error("rollback", subject, "the content of '%s' is shared by the pre-existing file %s, but is no longer downloadable: %s",
+					e.Label, sharer, err)
+			}
+			continue
+		}
+		if _, err := c.download(e.Url, ioutil.Discard); err == nil {
+			report.error("rollback", subject, "the file '%s' was removed, but its content is still served at %s", e.Label, e.Url)
+		} else if !errors.Is(err, ErrNotFound) {
+			report.error("rollback", subject, "the file '%s' was removed, but its content at %s did not answer 404: %s", e.Label, e.Url, err)
+		}
+	}
+
+	for _, id := range before.ids() {
+		e := before.Entities[id]
+		subject := fmt.Sprintf("%s %s", e.Type, id)
+		actual, ok := current.Entities[id]
+		switch {
+		case !ok:
+			report.error("rollback", subject, "'%s' existed before the migration, but not after rollback", e.Label)
+		case actual.Digest != e.Digest:
+			report.add(Finding{
+				Level:    LevelError,
+				Check:    "rollback",
+				Subject:  subject,
+				Field:    "digest",
+				Expected: e.Digest,
+				Actual:   actual.Digest,
+				Message:  fmt.Sprintf("'%s' existed before the migration, but was modified by the migration or its rollback", e.Label),
+			})
+		default:
+			report.Counts["untouched"]++
+		}
+	}
+}
+
+// Writes the snapshot as JSON to the named file
+func writeSnapshot(s *entitySnapshot, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
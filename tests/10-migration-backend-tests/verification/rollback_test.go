@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Populates the fake with the entities that exist before a migration, including a content-addressed file, and answers
+// a snapshot of them
+func populateRollbackFake(t *testing.T, fake *fakeJsonApi) (*jsonApiClient, *entitySnapshot) {
+	populateIntegrityFake(fake)
+	fake.add(fakeStoredFile(fake, "kept-file", "shared bytes", 12))
+
+	report := newReport("snapshot", fake.URL)
+	before := newJsonApiClient(fake.URL, "", "").snapshot(report)
+	assert.Equal(t, 0, len(report.Findings), "unexpected findings: %v", report.Findings)
+	return newJsonApiClient(fake.URL, "", ""), before
+}
+
+// Adds the entities created by a migration: a node, its document and the document's file, and a file with the same
+// content as the pre-existing file.  Answers a snapshot taken after the migration, and the path of the created file.
+func migrateRollbackFake(t *testing.T, fake *fakeJsonApi) (*entitySnapshot, string) {
+	file := fakeStoredFile(fake, "created-file", "created bytes", 13)
+	fake.add(
+		fakeResource{
+			Type:       "node--islandora_object",
+			Id:         "created-object",
+			Attributes: map[string]interface{}{"title": "Created Item"},
+			Relationships: map[string]interface{}{
+				"field_member_of": []fakeRelationship{{Type: "node--collection_object", Id: collectionId}},
+			},
+		},
+		fakeDocument("created-document", "created-file", 13),
+		file,
+		fakeStoredFile(fake, "dedup-file", "shared bytes", 12),
+	)
+
+	report := newReport("snapshot", fake.URL)
+	after := newJsonApiClient(fake.URL, "", "").snapshot(report)
+	assert.Equal(t, 0, len(report.Findings), "unexpected findings: %v", report.Findings)
+	uri := file.Attributes["uri"].(map[string]interface{})
+	return after, uri["url"].(string)
+}
+
+func Test_VerifyRollback_Removed(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	c, before := populateRollbackFake(t, fake)
+	after, createdFileUrl := migrateRollbackFake(t, fake)
+
+	fake.remove("created-object", "created-document", "created-file", "dedup-file")
+	fake.fail(createdFileUrl, http.StatusNotFound, "Not Found")
+
+	report := newReport("rollback", fake.URL)
+	c.verifyRollback(before, after, report)
+
+	assert.Equal(t, 0, len(report.Findings), "unexpected findings: %v", report.Findings)
+	assert.Equal(t, 4, report.Counts["created"])
+	assert.Equal(t, 4, report.Counts["removed"])
+	assert.Equal(t, 1, report.Counts["retained files"])
+	assert.Equal(t, len(before.Entities), report.Counts["untouched"])
+}
+
+func Test_VerifyRollback_Incomplete(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	c, before := populateRollbackFake(t, fake)
+	after, _ := migrateRollbackFake(t, fake)
+
+	// the document is not rolled back, the bytes of the created file remain, the content shared with the pre-existing
+	// file was removed along with the duplicate, and pre-existing entities were modified and deleted
+	fake.remove("created-object", "created-file", "dedup-file", englishId)
+	fake.fail(before.Entities["kept-file"].Url, http.StatusNotFound, "Not Found")
+	fake.set(personOneId, "name", "Adams, Ansel")
+
+	report := newReport("rollback", fake.URL)
+	c.verifyRollback(before, after, report)
+	assert.True(t, report.failed())
+
+	var problems []string
+	for _, f := range report.Findings {
+		problems = append(problems, fmt.Sprintf("%s [%s]", f.Subject, f.Field))
+	}
+	assert.ElementsMatch(t, []string{
+		"media--document created-document []",
+		"file--file created-file []",
+		"file--file dedup-file []",
+		"taxonomy_term--language " + englishId + " []",
+		"taxonomy_term--person " + personOneId + " [digest]",
+	}, problems)
+	for _, f := range report.Findings {
+		switch f.Subject {
+		case "file--file created-file":
+			assert.Contains(t, f.Message, "its content is still served")
+		case "file--file dedup-file":
+			assert.Contains(t, f.Message, "shared by the pre-existing file kept-file")
+		}
+	}
+	assert.Equal(t, 3, report.Counts["removed"])
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The defaults applied by `parse_entity_lookup` to the entity reference "quads" of each ingest CSV column, following
+// `migrate_plus.migration.idc_ingest_new_items.yml` and `migrate_plus.migration.idc_ingest_new_collection.yml`.  Typed
+// relation columns (e.g. `creator`) hold a relator followed by a quad, e.g. "relators:art;:person::Adams, Ansel".
+var quadDefaults = map[string]entityQuad{
+	"contributor":       {"taxonomy_term", "person", "name", ""},
+	"copyright_holder":  {"taxonomy_term", "person", "name", ""},
+	"creator":           {"taxonomy_term", "person", "name", ""},
+	"digital_publisher": {"taxonomy_term", "corporate_body", "name", ""},
+	"member_of":         {"node", "collection_object", "title", ""},
+	"publisher":         {"taxonomy_term", "corporate_body", "name", ""},
+	"subject":           {"taxonomy_term", "subject", "name", ""},
+}
+
+// Columns whose values are a relator followed by a quad
+var typedRelationColumns = map[string]bool{
+	"contributor": true,
+	"creator":     true,
+}
+
+// Columns holding a boolean, exported as "true" or the empty string, but ingested as "1" or "0"
+var booleanColumns = map[string]bool{
+	"featured_item": true,
+}
+
+// Export Metadata columns whose label differs from the ingest CSV column they round trip to
+var exportColumnAliases = map[string]string{
+	"alternate_title": "alternative_title",
+}
+
+// The separator of the values of a multi-valued column
+const csvValueSeparator = "|"
+
+// An entity reference in an ingest or export CSV: `<entity_type>:<bundle>:<value_key>:<value>`
+type entityQuad struct {
+	entityType string
+	bundle     string
+	valueKey   string
+	value      string
+}
+
+func (q entityQuad) String() string {
+	return strings.Join([]string{q.entityType, q.bundle, q.valueKey, q.value}, ":")
+}
+
+// Parses a quad, applying the supplied defaults to its empty parts.  A value without the three leading separators of
+// a quad is the value of a quad with only defaults.
+func parseQuad(s string, defaults entityQuad) entityQuad {
+	parts := strings.SplitN(s, ":", 4)
+	if len(parts) != 4 {
+		defaults.value = s
+		return defaults
+	}
+	q := entityQuad{parts[0], parts[1], parts[2], parts[3]}
+	if q.entityType == "" {
+		q.entityType = defaults.entityType
+	}
+	if q.bundle == "" {
+		q.bundle = defaults.bundle
+	}
+	if q.valueKey == "" {
+		q.valueKey = defaults.valueKey
+	}
+	return q
+}
+
+// Answers the values of a cell of the named column in a form that is equal for semantically equal ingest and export
+// CSVs: quads are fully qualified, booleans are "1" or "0", and multiple values are sorted.
+func normalizeCell(column, cell string) []string {
+	if booleanColumns[column] {
+		switch strings.ToLower(strings.TrimSpace(cell)) {
+		case "1", "true", "yes", "on":
+			return []string{"1"}
+		}
+		return []string{"0"}
+	}
+
+	var values []string
+	for _, v := range strings.Split(cell, csvValueSeparator) {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if defaults, ok := quadDefaults[column]; ok {
+			if typedRelationColumns[column] {
+				if i := strings.Index(v, ";"); i >= 0 {
+					v = v[:i+1] + parseQuad(v[i+1:], defaults).String()
+				}
+			} else {
+				v = parseQuad(v, defaults).String()
+			}
+		}
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// A CSV, read into rows keyed by column
+type csvTable struct {
+	path    string
+	columns []string
+	rows    []map[string]string
+}
+
+// Reads a CSV whose first row names its columns.  Column names are renamed according to aliases.
+func readCsv(path string, r io.Reader, aliases map[string]string) (*csvTable, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV %s: %w", path, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("CSV %s has no header", path)
+	}
+
+	table := &csvTable{path: path}
+	for _, column := range records[0] {
+		column = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+		if alias, ok := aliases[column]; ok {
+			column = alias
+		}
+		table.columns = append(table.columns, column)
+	}
+	for _, record := range records[1:] {
+		row := make(map[string]string)
+		for i, cell := range record {
+			if i < len(table.columns) {
+				row[table.columns[i]] = cell
+			}
+		}
+		table.rows = append(table.rows, row)
+	}
+	return table, nil
+}
+
+// Answers the key rows of a table are matched by: the local_id, or the title if the row has no local_id
+func rowKey(row map[string]string) string {
+	if id := strings.TrimSpace(row["local_id"]); id != "" {
+		return "local_id " + id
+	}
+	return "title " + strings.TrimSpace(row["title"])
+}
+
+// Compares the rows of an export CSV with the rows of the ingest CSV(s) it should round trip, row by row and field by
+// field.  Rows are matched by local_id (or title).  Every ingest row must be exported, and every non-empty ingest cell
+// must be semantically equal to its exported cell.  Empty ingest cells are not compared, since the migrations may
+// supply defaults for them, and neither is the node_id column, because it is assigned by ingest.
+func compareRoundTrip(exported *csvTable, ingested []*csvTable, report *Report) {
+	byKey := make(map[string]map[string]string)
+	for _, row := range exported.rows {
+		byKey[rowKey(row)] = row
+	}
+	exportedColumns := make(map[string]bool)
+	for _, column := range exported.columns {
+		exportedColumns[column] = true
+	}
+
+	unexported := make(map[string]bool)
+	for _, table := range ingested {
+		for _, row := range table.rows {
+			report.Checked++
+			key := rowKey(row)
+			subject := fmt.Sprintf("%s %s", table.path, key)
+
+			actual, ok := byKey[key]
+			if !ok {
+				report.error("roundtrip", subject, "the row was not exported")
+				continue
+			}
+			report.Counts["rows"]++
+
+			for _, column := range table.columns {
+				if column == "node_id" || strings.TrimSpace(row[column]) == "" {
+					continue
+				}
+				if !exportedColumns[column] {
+					unexported[column] = true
+					continue
+				}
+				expected, got := normalizeCell(column, row[column]), normalizeCell(column, actual[column])
+				if strings.Join(expected, csvValueSeparator) != strings.Join(got, csvValueSeparator) {
+					report.add(Finding{
+						Level:    LevelError,
+						Check:    "roundtrip",
+						Subject:  subject,
+						Field:    column,
+						Expected: row[column],
+						Actual:   actual[column],
+						Message:  fmt.Sprintf("the exported %s is not semantically equal to the ingested %s", column, column),
+					})
+				}
+			}
+		}
+	}
+
+	var columns []string
+	for column := range unexported {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		report.warning("roundtrip", exported.path, "the ingested column '%s' is not exported, and was not compared", column)
+	}
+}
+
+// Matches the link to the file written by a batch data export, and the meta refresh of each page of a batch
+var (
+	exportDownloadLink = regexp.MustCompile(`<a[^>]*href="([^"]+)"[^>]*id="vde-automatic-download"|<a[^>]*id="vde-automatic-download"[^>]*href="([^"]+)"`)
+	metaRefresh        = regexp.MustCompile(`(?i)<meta[^>]*http-equiv="refresh"[^>]*content="[0-9]+;\s*url=([^"]+)"`)
+)
+
+// Answers the content of the CSV written by the Export Metadata view at path (e.g. "export_items"), optionally filtered
+// by a fulltext query.  The view exports in batches, so the batch is followed from page to page until the link to the
+// exported file is found.
+func (c *jsonApiClient) exportCsv(path, query string) ([]byte, error) {
+	browser, err := c.session(5 * time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	u := fmt.Sprintf("%s/%s", c.baseUrl, strings.TrimPrefix(path, "/"))
+	if query != "" {
+		u += "?" + url.Values{"query": {query}}.Encode()
+	}
+
+	// each page of the batch refreshes to the next; the number of pages followed is bounded in case the batch never ends
+	for page := 0; page < 1000; page++ {
+		res, err := browser.Get(u)
+		if err != nil {
+			return nil, err
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", u, err)
+		}
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%d status encountered when requesting %s", res.StatusCode, u)
+		}
+		if !strings.Contains(res.Header.Get("Content-Type"), "html") {
+			return body, nil
+		}
+
+		var next string
+		if m := exportDownloadLink.FindSubmatch(body); m != nil {
+			next = string(m[1]) + string(m[2])
+		} else if m := metaRefresh.FindSubmatch(body); m != nil {
+			next = string(m[1])
+		} else {
+			return nil, fmt.Errorf("neither an exported file nor the next page of the export batch was found at %s", u)
+		}
+
+		ref, err := url.Parse(html.UnescapeString(next))
+		if err != nil {
+			return nil, err
+		}
+		base, _ := url.Parse(u)
+		u = base.ResolveReference(ref).String()
+	}
+	return nil, fmt.Errorf("the export batch did not complete")
+}
+
+// Reads the ingest CSVs at the supplied paths
+func readIngestCsvs(paths []string) ([]*csvTable, error) {
+	var tables []*csvTable
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		table, err := readCsv(path, f, nil)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
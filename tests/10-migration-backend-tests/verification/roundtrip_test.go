@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// An ingest CSV, in the form of the testcafe migrations: quads are abbreviated, booleans are 1 or 0
+const ingestCsv = `node_id,local_id,title,alternative_title,member_of,creator,subject,featured_item
+,item-1,Item One,Alt One|Alt Two,:::Collection A,relators:art;:person::Adams|relators:aut;:::Baker,,1
+,,Item Two,,node:collection_object:title:Collection A,,Soil,0
+`
+
+// The export of the ingest CSV by the Export Metadata view: quads are fully qualified, booleans are true or empty, and
+// the alternative title column is labeled alternate_title
+const exportedCsv = `node_id,local_id,title,alternate_title,member_of,creator,subject,featured_item
+12,item-1,Item One,Alt Two|Alt One,:collection_object::Collection A,relators:aut;taxonomy_term:person:name:Baker|relators:art;:person::Adams,,true
+13,,Item Two,,node:collection_object:title:Collection A,,taxonomy_term:subject:name:Soil,
+`
+
+// An in-process stand-in for the Export Metadata view of Drupal.  A session must be established by logging in, and the
+// view exports in a batch of pages before linking to the exported file.
+type fakeExport struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	csv     string
+	pages   int
+	visited int
+}
+
+func newFakeExport(t *testing.T, csv string, pages int) *fakeExport {
+	fake := &fakeExport{csv: csv, pages: pages}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (fake *fakeExport) serve(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if r.URL.Path == "/user/login" {
+		http.SetCookie(w, &http.Cookie{Name: "SESS", Value: "session", Path: "/"})
+		return
+	}
+	if _, err := r.Cookie("SESS"); err != nil {
+		http.Error(w, "access denied", http.StatusForbidden)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/export_items":
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		fmt.Fprint(w, `<html><head><meta http-equiv="Refresh" content="0; URL=/batch?id=1&amp;op=do_nojs"></head></html>`)
+	case "/batch":
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		if fake.visited++; fake.visited < fake.pages {
+			fmt.Fprint(w, `<html><head><meta http-equiv="Refresh" content="0; URL=/batch?id=1&amp;op=do_nojs"></head></html>`)
+			return
+		}
+		fmt.Fprint(w, `<p>Export complete. <a href="/system/files/views_data_export/export.csv" id="vde-automatic-download">download</a></p>`)
+	case "/system/files/views_data_export/export.csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		fmt.Fprint(w, fake.csv)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func Test_NormalizeCell(t *testing.T) {
+	assert.Equal(t, []string{"node:collection_object:title:Collection A"}, normalizeCell("member_of", ":::Collection A"))
+	assert.Equal(t, []string{"node:collection_object:title:Collection A"}, normalizeCell("member_of", ":collection_object::Collection A"))
+	assert.Equal(t, []string{"node:collection_object:title:Collection: A"}, normalizeCell("member_of", "Collection: A"))
+	assert.Equal(t, []string{"relators:art;taxonomy_term:person:name:Adams"}, normalizeCell("creator", "relators:art;:person::Adams"))
+	assert.Equal(t, []string{"a", "b"}, normalizeCell("alternative_title", " b | a |"))
+	assert.Equal(t, []string{"1"}, normalizeCell("featured_item", "true"))
+	assert.Equal(t, []string{"0"}, normalizeCell("featured_item", ""))
+	assert.Equal(t, []string(nil), normalizeCell("subject", ""))
+}
+
+func Test_RoundTrip_Equal(t *testing.T) {
+	ingested, err := readCsv("ingest.csv", strings.NewReader(ingestCsv), nil)
+	assert.Nil(t, err)
+	exported, err := readCsv("export.csv", strings.NewReader(exportedCsv), exportColumnAliases)
+	assert.Nil(t, err)
+
+	report := newReport("roundtrip", "")
+	compareRoundTrip(exported, []*csvTable{ingested}, report)
+
+	assert.Equal(t, 0, len(report.Findings), "unexpected findings: %v", report.Findings)
+	assert.Equal(t, 2, report.Checked)
+	assert.Equal(t, 2, report.Counts["rows"])
+}
+
+func Test_RoundTrip_Unequal(t *testing.T) {
+	ingested, _ := readCsv("ingest.csv", strings.NewReader(strings.Replace(ingestCsv, "Soil,0", "Soil|Silt,0", 1)+",item-3,Item Three,,,,,0\n"), nil)
+	exported, _ := readCsv("export.csv", strings.NewReader(strings.Replace(exportedCsv, "Alt Two|Alt One", "Alt Two", 1)), exportColumnAliases)
+	exported.columns = exported.columns[:len(exported.columns)-1]
+
+	report := newReport("roundtrip", "")
+	compareRoundTrip(exported, []*csvTable{ingested}, report)
+	assert.True(t, report.failed())
+
+	var problems []string
+	for _, f := range report.Findings {
+		problems = append(problems, fmt.Sprintf("%s: %s %s [%s] [%s]", f.Level, f.Subject, f.Field, f.Expected, f.Actual))
+	}
+	assert.Equal(t, []string{
+		"error: ingest.csv local_id item-1 alternative_title [Alt One|Alt Two] [Alt Two]",
+		"error: ingest.csv title Item Two subject [Soil|Silt] [taxonomy_term:subject:name:Soil]",
+		"error: ingest.csv local_id item-3  [] []",
+		"warning: export.csv  [] []",
+	}, problems)
+	assert.Contains(t, report.Findings[3].Message, "'featured_item' is not exported")
+}
+
+func Test_ExportCsv(t *testing.T) {
+	fake := newFakeExport(t, exportedCsv, 3)
+
+	content, err := newJsonApiClient(fake.URL, "admin", "password").exportCsv("export_items", "Item")
+	assert.Nil(t, err)
+	assert.Equal(t, exportedCsv, string(content))
+	assert.Equal(t, 3, fake.visited)
+
+	_, err = newJsonApiClient(fake.URL, "", "").exportCsv("export_items", "")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "403 status")
+}
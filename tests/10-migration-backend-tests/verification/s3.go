@@ -0,0 +1,392 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// The MinIO endpoint and bucket of `docker-compose.minio.yml`, following DRUPAL_DEFAULT_S3_HOSTNAME and
+	// DRUPAL_DEFAULT_S3_BUCKET in `.env`
+	DefaultS3Url    = "http://minio-idc.traefik.me:9000"
+	DefaultS3Bucket = "idc"
+	DefaultS3Region = "us-east-1"
+
+	// The folders s3fs stores private:// and public:// files under, following `s3fs.settings.yml`; s3fs uses
+	// `s3fs-public` when no public folder is configured
+	DefaultS3PrivateFolder = "local"
+	DefaultS3PublicFolder  = "s3fs-public"
+
+	// The SHA-256 of an empty payload, signed for every request since none has a body
+	emptyPayloadSha256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// The entity types crawled to verify object storage
+var s3Entities = map[string]bool{
+	"file": true,
+}
+
+// The folders of a bucket that s3fs maps stream wrapper schemes to
+type s3Folders struct {
+	root    string
+	private string
+	public  string
+}
+
+// Requests the objects of a bucket of S3-compatible storage, such as MinIO, using path-style URLs.  Requests are
+// signed with AWS Signature Version 4 if an access key is supplied, and are anonymous otherwise.
+type s3Client struct {
+	baseUrl   string
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	folders   s3Folders
+	http      *http.Client
+}
+
+func newS3Client(baseUrl, bucket, region, accessKey, secretKey string, folders s3Folders) *s3Client {
+	return &s3Client{
+		baseUrl:   strings.TrimSuffix(baseUrl, "/"),
+		bucket:    bucket,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		folders:   folders,
+		http:      &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// An object of a bucket, as answered by a HEAD request or ListObjectsV2
+type s3Object struct {
+	Key  string `xml:"Key"`
+	Size int64  `xml:"Size"`
+	ETag string `xml:"ETag"`
+}
+
+// The result of ListObjectsV2 (https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectsV2.html)
+type s3ListResult struct {
+	IsTruncated           bool       `xml:"IsTruncated"`
+	NextContinuationToken string     `xml:"NextContinuationToken"`
+	Contents              []s3Object `xml:"Contents"`
+}
+
+// Answers the key s3fs stores the file with the supplied uri under, or false if the uri is not of a scheme s3fs
+// stores, e.g.:
+//
+//   private://c9/a0/60/c39365820edc5d1a51f221d49e96a8a730 -> local/c9/a0/60/c39365820edc5d1a51f221d49e96a8a730
+func (s *s3Client) key(uri string) (string, bool) {
+	i := strings.Index(uri, "://")
+	if i < 0 {
+		return "", false
+	}
+
+	var folder string
+	switch uri[:i] {
+	case "s3":
+	case "private":
+		folder = s.folders.private
+	case "public":
+		folder = s.folders.public
+	default:
+		return "", false
+	}
+
+	return joinKey(s.folders.root, folder, uri[i+3:]), true
+}
+
+// Answers true if s3fs generates the object with the key, rather than storing a file: the derivatives of image styles
+func (s *s3Client) generated(key string) bool {
+	for _, folder := range []string{"", s.folders.public} {
+		if strings.HasPrefix(key, joinKey(s.folders.root, folder, "styles")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Joins the non-empty segments of a key with slashes
+func joinKey(segments ...string) string {
+	var nonEmpty []string
+	for _, segment := range segments {
+		if segment = strings.Trim(segment, "/"); segment != "" {
+			nonEmpty = append(nonEmpty, segment)
+		}
+	}
+	return strings.Join(nonEmpty, "/")
+}
+
+// Escapes a string as required by AWS Signature Version 4: every byte other than the unreserved characters of RFC 3986
+// is percent encoded, and if path is true, slashes are not.
+func awsEscape(s string, path bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && path:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// Signs a request without a body with AWS Signature Version 4
+// (https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html), as made at the supplied time
+func (s *s3Client) sign(req *http.Request, at time.Time) {
+	amzDate := at.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadSha256)
+
+	var params []string
+	for name, values := range req.URL.Query() {
+		for _, v := range values {
+			params = append(params, awsEscape(name, false)+"="+awsEscape(v, false))
+		}
+	}
+	sort.Strings(params)
+
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		awsEscape(path, true),
+		strings.Join(params, "&"),
+		"host:" + host,
+		"x-amz-content-sha256:" + emptyPayloadSha256,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		emptyPayloadSha256,
+	}, "\n")
+	digest := sha256.Sum256([]byte(canonical))
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, s.region)
+	toSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(digest[:])}, "\n")
+	key := hmacSha256(hmacSha256(hmacSha256(hmacSha256([]byte("AWS4"+s.secretKey), date), s.region), "s3"), "aws4_request")
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, hex.EncodeToString(hmacSha256(key, toSign))))
+}
+
+// Performs a request of the bucket, or of the object with the key if it is not empty
+func (s *s3Client) do(method, key string, query url.Values) (*http.Response, error) {
+	u := fmt.Sprintf("%s/%s", s.baseUrl, s.bucket)
+	if key != "" {
+		u += "/" + awsEscape(key, true)
+	}
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.accessKey != "" {
+		s.sign(req, time.Now())
+	}
+
+	res, err := s.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		res.Body.Close()
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, u)
+	case res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusUnauthorized:
+		res.Body.Close()
+		return nil, fmt.Errorf("%w: %s", ErrForbidden, u)
+	case res.StatusCode != http.StatusOK:
+		res.Body.Close()
+		return nil, fmt.Errorf("%d status encountered when requesting %s %s", res.StatusCode, method, u)
+	}
+	return res, nil
+}
+
+// Answers the size and ETag of the object with the key
+func (s *s3Client) head(key string) (*s3Object, error) {
+	res, err := s.do(http.MethodHead, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+	size, _ := strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64)
+	return &s3Object{Key: key, Size: size, ETag: res.Header.Get("ETag")}, nil
+}
+
+// Streams the content of the object with the key to w, answering the number of bytes written
+func (s *s3Client) get(key string, w io.Writer) (int64, error) {
+	res, err := s.do(http.MethodGet, key, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	n, err := io.Copy(w, res.Body)
+	if err != nil {
+		return n, fmt.Errorf("error reading %s: %w", key, err)
+	}
+	return n, nil
+}
+
+// Answers every object of the bucket, following continuation tokens until the listing is complete
+func (s *s3Client) list() ([]s3Object, error) {
+	var (
+		objects []s3Object
+		token   string
+	)
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		res, err := s.do(http.MethodGet, "", query)
+		if err != nil {
+			return nil, err
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		result := &s3ListResult{}
+		if err := xml.Unmarshal(body, result); err != nil {
+			return nil, fmt.Errorf("error unmarshaling the objects of bucket %s: %w", s.bucket, err)
+		}
+		objects = append(objects, result.Contents...)
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Verifies the objects s3fs stores the files of Drupal in.  The key of every File entity with a private://, public://
+// or s3:// uri is determined from the folders s3fs is configured with, and the object must exist in the bucket with the
+// `filesize` of the entity.  The content of each object is retrieved: its MD5 must match its ETag (unless it was
+// uploaded in parts, when the ETag is not an MD5), and its SHA-1 must match the content-addressed uri of the file.  Many
+// File entities may share a uri, so each object is checked once.  Finally, objects of the bucket that no File entity
+// refers to are reported as warnings, other than the derivatives s3fs generates for image styles.
+func (c *jsonApiClient) verifyObjectStorage(s *s3Client, report *Report) {
+	idx := newCrawlIndex(c.crawl(report, "s3", s3Entities))
+
+	// the files stored under each key
+	files := make(map[string][]*JsonApiResource)
+	for i := range idx.crawled["file--file"] {
+		file := &idx.crawled["file--file"][i]
+		key, ok := s.key(fileUri(file))
+		if !ok {
+			report.Counts["not in object storage"]++
+			continue
+		}
+		files[key] = append(files[key], file)
+	}
+
+	var keys []string
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		c.verifyObject(s, key, files[key], report)
+	}
+
+	objects, err := s.list()
+	if err != nil {
+		report.error("s3", s.bucket, "unable to list the objects of the bucket: %s", err)
+		return
+	}
+	for _, object := range objects {
+		if _, ok := files[object.Key]; ok || s.generated(object.Key) {
+			continue
+		}
+		report.Counts["unreferenced"]++
+		report.warning("s3", object.Key, "the object (%d bytes) is not the content of any File entity", object.Size)
+	}
+	report.Counts["objects"] = len(objects)
+}
+
+// Verifies the object stored under the key, the content of the supplied files
+func (c *jsonApiClient) verifyObject(s *s3Client, key string, files []*JsonApiResource, report *Report) {
+	report.Checked++
+	file := files[0]
+	fail := func(field, expected, actual, format string, args ...interface{}) {
+		report.add(Finding{
+			Level:    LevelError,
+			Check:    "s3",
+			Subject:  file.String(),
+			Field:    field,
+			Expected: expected,
+			Actual:   actual,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	object, err := s.head(key)
+	if err != nil {
+		fail("uri", key, "", "the object of '%s' is not in bucket %s: %s", file.label(), s.bucket, err)
+		return
+	}
+	report.Counts["stored"]++
+
+	actualSize := strconv.FormatInt(object.Size, 10)
+	for _, f := range files {
+		if expected := scalarString(f.Attributes["filesize"]); expected != "" && expected != actualSize {
+			report.add(Finding{
+				Level:    LevelError,
+				Check:    "s3",
+				Subject:  f.String(),
+				Field:    "filesize",
+				Expected: expected,
+				Actual:   actualSize,
+				Message:  fmt.Sprintf("the size of object %s does not match the size of '%s'", key, f.label()),
+			})
+		}
+	}
+
+	md5sum, sha1sum := md5.New(), sha1.New()
+	if _, err := s.get(key, io.MultiWriter(md5sum, sha1sum)); err != nil {
+		fail("uri", "", "", "unable to retrieve the object of '%s': %s", file.label(), err)
+		return
+	}
+
+	// the ETag of an object uploaded in parts is the MD5 of the MD5s of its parts, suffixed with the number of parts
+	etag := strings.Trim(object.ETag, `"`)
+	if actual := hex.EncodeToString(md5sum.Sum(nil)); etag != "" && !strings.Contains(etag, "-") && etag != actual {
+		fail("ETag", etag, actual, "the MD5 of object %s does not match its ETag", key)
+	}
+	if address, actual := contentAddress(fileUri(file)), hex.EncodeToString(sha1sum.Sum(nil)); address != "" && address != actual {
+		fail("uri", address, actual, "the SHA-1 of object %s does not match the content-addressed uri of '%s'", key, file.label())
+	}
+}
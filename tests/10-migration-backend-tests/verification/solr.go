@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The Solr core Search API indexes nodes in, following the traefik routes in `docker-compose.solr.yml` and the core
+// configured by `search_api.server.default_solr_server.yml`
+const DefaultSolrUrl = "http://solr-idc.traefik.me/solr/ISLANDORA"
+
+// How the value of a Drupal field is indexed by Search API
+type solrValueKind int
+
+const (
+	// the attribute itself, e.g. the title of a node
+	solrAttribute solrValueKind = iota
+	// the label of each target of a relationship, e.g. `field_creator:entity:name`
+	solrTargetLabel
+	// the internal id of each target of a relationship, e.g. the nid of each `field_member_of`
+	solrTargetId
+)
+
+// A Drupal field indexed in Solr, following `search_api.index.default_solr_index.yml`
+type solrField struct {
+	field string
+	kind  solrValueKind
+	// the name of the Solr field, whose prefix Search API derives from the type and cardinality of the field, and for
+	// text fields, their language
+	solr string
+}
+
+// The fields of every node compared with its Solr document.  The title is also indexed as sort_X3b_en_title, which is
+// not compared.
+var solrFields = []solrField{
+	{"title", solrAttribute, "tm_X3b_en_title"},
+	{"field_creator", solrTargetLabel, "sm_field_creator"},
+	{"field_subject", solrTargetLabel, "sm_field_subject"},
+	{"field_member_of", solrTargetId, "itm_field_member_of"},
+	{"field_access_terms", solrTargetId, "itm_field_access_terms"},
+}
+
+// The entity types crawled to verify the Solr index
+var solrEntities = map[string]bool{
+	"node": true,
+}
+
+// Queries the Solr core Search API indexes Drupal entities in
+type solrClient struct {
+	coreUrl string
+	http    *http.Client
+}
+
+func newSolrClient(coreUrl string) *solrClient {
+	return &solrClient{
+		coreUrl: strings.TrimSuffix(coreUrl, "/"),
+		http:    &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// A document of the Solr index, keyed by Solr field name
+type solrDocument map[string]interface{}
+
+// Answers the values of the Solr field named by name
+func (doc solrDocument) values(name string) []string {
+	var values []string
+	for _, value := range asList(doc[name]) {
+		values = append(values, scalarString(value))
+	}
+	return values
+}
+
+// Answers the documents matching the query, using the standard select request handler
+func (s *solrClient) selectDocuments(q string) ([]solrDocument, error) {
+	u := fmt.Sprintf("%s/select?%s", s.coreUrl, url.Values{"q": {q}, "wt": {"json"}}.Encode())
+	res, err := s.http.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, u)
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%d status encountered when requesting %s", res.StatusCode, u)
+	}
+
+	body := struct {
+		Response struct {
+			NumFound int            `json:"numFound"`
+			Docs     []solrDocument `json:"docs"`
+		} `json:"response"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("error unmarshaling Solr response from %s: %w", u, err)
+	}
+	return body.Response.Docs, nil
+}
+
+// Verifies that every node is present in the Solr index, queried by its UUID, and that its title, creators, subjects,
+// collection membership and access terms are indexed as they are in Drupal.
+func (c *jsonApiClient) verifySolr(s *solrClient, report *Report) {
+	idx := newCrawlIndex(c.crawl(report, "solr", solrEntities))
+
+	for _, t := range sortedTypes(idx.crawled) {
+		for i := range idx.crawled[t] {
+			res := &idx.crawled[t][i]
+			report.Checked++
+
+			docs, err := s.selectDocuments(fmt.Sprintf(`ss_uuid:"%s"`, res.Id))
+			if err != nil {
+				report.error("solr", res.String(), "unable to query Solr: %s", err)
+				continue
+			}
+			if len(docs) == 0 {
+				report.error("solr", res.String(), "'%s' is not in the Solr index", res.label())
+				continue
+			}
+			report.Counts["indexed"]++
+
+			for _, f := range solrFields {
+				c.compareSolrField(idx, res, f, docs[0], report)
+			}
+		}
+	}
+}
+
+// Compares the values of a single field of res with the values indexed in its Solr document
+func (c *jsonApiClient) compareSolrField(idx *crawlIndex, res *JsonApiResource, f solrField, doc solrDocument, report *Report) {
+	var expected []string
+	if f.kind == solrAttribute {
+		if v := scalarString(res.Attributes[f.field]); v != "" {
+			expected = append(expected, v)
+		}
+	} else {
+		for _, target := range res.related(f.field) {
+			if target.Id == missingId {
+				continue
+			}
+			resolved := idx.byId[target.Id]
+			if resolved == nil {
+				var err error
+				if resolved, err = c.resolve(target.JsonApiData); err != nil {
+					report.error("solr", res.String(), "unable to resolve the %s of '%s': %s", f.field, res.label(), err)
+					return
+				}
+			}
+			if f.kind == solrTargetLabel {
+				expected = append(expected, resolved.label())
+			} else {
+				path := strings.Split(resolved.entityPath(), "/")
+				expected = append(expected, path[len(path)-1])
+			}
+		}
+	}
+
+	actual := doc.values(f.solr)
+	sort.Strings(expected)
+	sort.Strings(actual)
+	if strings.Join(expected, "\x00") == strings.Join(actual, "\x00") {
+		return
+	}
+	report.add(Finding{
+		Level:    LevelError,
+		Check:    "solr",
+		Subject:  res.String(),
+		Field:    f.field,
+		Expected: strings.Join(expected, ", "),
+		Actual:   strings.Join(actual, ", "),
+		Message:  fmt.Sprintf("the indexed %s of '%s' does not match Drupal", f.field, res.label()),
+	})
+}
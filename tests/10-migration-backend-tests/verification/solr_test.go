@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// An in-process stand-in for the select request handler of a Solr core, answering the documents whose `ss_uuid`
+// matches the query
+type fakeSolr struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	docs map[string]solrDocument
+}
+
+var uuidQuery = regexp.MustCompile(`^ss_uuid:"([^"]+)"$`)
+
+// Starts a fake Solr core which is closed when the test completes
+func newFakeSolr(t *testing.T) *fakeSolr {
+	fake := &fakeSolr{docs: make(map[string]solrDocument)}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// Indexes a document for the node with the supplied uuid
+func (fake *fakeSolr) index(uuid string, doc solrDocument) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	doc["ss_uuid"] = uuid
+	fake.docs[uuid] = doc
+}
+
+func (fake *fakeSolr) serve(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if r.URL.Path != "/solr/ISLANDORA/select" {
+		http.NotFound(w, r)
+		return
+	}
+	docs := []solrDocument{}
+	if m := uuidQuery.FindStringSubmatch(r.URL.Query().Get("q")); m != nil {
+		if doc, ok := fake.docs[m[1]]; ok {
+			docs = append(docs, doc)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"responseHeader": map[string]interface{}{"status": 0},
+		"response":       map[string]interface{}{"numFound": len(docs), "start": 0, "docs": docs},
+	})
+}
+
+func Test_SolrDocumentValues(t *testing.T) {
+	doc := solrDocument{
+		"tm_X3b_en_title":           []interface{}{"Title"},
+		"sort_X3b_en_title":         "title",
+		"itm_field_member_of":       []interface{}{1.0, 2.0},
+		"ss_field_title_language":   "English",
+		"sm_field_creator_rel_type": []interface{}{"relators:cre"},
+	}
+	assert.Equal(t, []string{"Title"}, doc.values("tm_X3b_en_title"))
+	assert.Equal(t, []string{"1", "2"}, doc.values("itm_field_member_of"))
+	assert.Equal(t, []string{"English"}, doc.values("ss_field_title_language"))
+	assert.Nil(t, doc.values("sm_field_creator"))
+}
+
+// Populates the fakes with a repository item that is the member of a collection, both of which are indexed
+func populateSolrFakes(fake *fakeJsonApi, solr *fakeSolr) {
+	populateIntegrityFake(fake)
+	fake.set(collectionId, "drupal_internal__nid", 1)
+	fake.set(objectId, "drupal_internal__nid", 12)
+
+	solr.index(collectionId, solrDocument{"tm_X3b_en_title": []string{"Test Collection One"}})
+	solr.index(objectId, solrDocument{
+		"tm_X3b_en_title":     []string{"Repository Item One"},
+		"sm_field_subject":    []string{personOneName},
+		"itm_field_member_of": []int{1},
+	})
+}
+
+func Test_VerifySolr_Consistent(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	solr := newFakeSolr(t)
+	populateSolrFakes(fake, solr)
+
+	report := newReport("solr", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifySolr(newSolrClient(solr.URL+"/solr/ISLANDORA/"), report)
+
+	assert.Equal(t, 0, len(report.Findings), "unexpected findings: %v", report.Findings)
+	assert.Equal(t, 2, report.Checked)
+	assert.Equal(t, 2, report.Counts["indexed"])
+}
+
+func Test_VerifySolr_Inconsistent(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	solr := newFakeSolr(t)
+	populateSolrFakes(fake, solr)
+	fake.add(fakeResource{Type: "node--islandora_object", Id: "unindexed", Attributes: map[string]interface{}{"title": "Unindexed Item"}})
+
+	// the repository item was indexed before its subject was added, and before it was moved between collections
+	solr.index(objectId, solrDocument{
+		"tm_X3b_en_title":     []string{"Repository Item One"},
+		"itm_field_member_of": []int{2},
+	})
+
+	report := newReport("solr", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifySolr(newSolrClient(solr.URL+"/solr/ISLANDORA"), report)
+	assert.True(t, report.failed())
+
+	var problems []string
+	for _, f := range report.Findings {
+		assert.Equal(t, "solr", f.Check)
+		problems = append(problems, fmt.Sprintf("%s %s: %s [%s] [%s]", f.Subject, f.Field, f.Message, f.Expected, f.Actual))
+	}
+	assert.Equal(t, []string{
+		"node--islandora_object " + objectId + " field_subject: the indexed field_subject of 'Repository Item One' does not match Drupal [" + personOneName + "] []",
+		"node--islandora_object " + objectId + " field_member_of: the indexed field_member_of of 'Repository Item One' does not match Drupal [1] [2]",
+		"node--islandora_object unindexed : 'Unindexed Item' is not in the Solr index [] []",
+	}, problems)
+}
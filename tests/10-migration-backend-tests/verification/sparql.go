@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// The SPARQL endpoint of the Blazegraph namespace populated by Alpaca, following the traefik routes in
+	// `docker-compose.blazegraph.yml`
+	DefaultSparqlUrl = "http://blazegraph-idc.traefik.me/bigdata/namespace/islandora/sparql"
+
+	SchemaName = "http://schema.org/name"
+)
+
+// Namespaces of the compact IRIs (e.g. "schema:knows") used as the `rel_type` of typed relations
+var rdfNamespaces = map[string]string{
+	"schema":   "http://schema.org/",
+	"dcterms":  "http://purl.org/dc/terms/",
+	"pcdm":     "http://pcdm.org/models#",
+	"relators": "http://id.loc.gov/vocabulary/relators/",
+	"owl":      "http://www.w3.org/2002/07/owl#",
+}
+
+// Answers the full IRI of a compact IRI like "schema:knows", or the compact IRI itself if its prefix is unknown
+func expandCurie(curie string) string {
+	if i := strings.Index(curie, ":"); i > 0 {
+		if ns, ok := rdfNamespaces[curie[:i]]; ok {
+			return ns + curie[i+1:]
+		}
+	}
+	return curie
+}
+
+// The triples expected in the triplestore for each resource type: the predicate of the label of the resource, and
+// the predicate of each relationship field
+var triplestoreMappings = map[DrupalType]struct {
+	label  string
+	fields map[string]string
+}{
+	"node--islandora_object":        {DctermsTitle, map[string]string{"field_member_of": PcdmMemberOf}},
+	"node--collection_object":       {DctermsTitle, map[string]string{"field_member_of": PcdmMemberOf}},
+	"taxonomy_term--person":         {SchemaName, map[string]string{}},
+	"taxonomy_term--family":         {SchemaName, map[string]string{}},
+	"taxonomy_term--corporate_body": {SchemaName, map[string]string{}},
+}
+
+// The entity types crawled to verify the triplestore
+var triplestoreEntities = map[string]bool{
+	"node":          true,
+	"taxonomy_term": true,
+}
+
+// Performs SPARQL queries of the triplestore Alpaca indexes Drupal entities in
+type sparqlClient struct {
+	endpoint string
+	username string
+	password string
+	http     *http.Client
+}
+
+// Answers a client for the SPARQL endpoint.  If username is not empty, requests are authenticated using HTTP basic
+// authentication.
+func newSparqlClient(endpoint, username, password string) *sparqlClient {
+	return &sparqlClient{
+		endpoint: endpoint,
+		username: username,
+		password: password,
+		http:     &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// A single RDF term bound to a variable of a SPARQL query solution
+type sparqlTerm struct {
+	// "uri", "literal", "typed-literal" or "bnode"
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	Lang     string `json:"xml:lang"`
+	Datatype string `json:"datatype"`
+}
+
+// The SPARQL 1.1 Query Results JSON Format (https://www.w3.org/TR/sparql11-results-json/)
+type sparqlResults struct {
+	Head struct {
+		Vars []string `json:"vars"`
+	} `json:"head"`
+	Results struct {
+		Bindings []map[string]sparqlTerm `json:"bindings"`
+	} `json:"results"`
+}
+
+// Performs a SELECT query, answering its solutions
+func (s *sparqlClient) query(q string) ([]map[string]sparqlTerm, error) {
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, strings.NewReader(url.Values{"query": {q}}.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/sparql-results+json")
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+
+	res, err := s.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusUnauthorized:
+		return nil, fmt.Errorf("%w: %s", ErrForbidden, s.endpoint)
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%d status encountered when querying %s", res.StatusCode, s.endpoint)
+	}
+
+	results := sparqlResults{}
+	if err := json.NewDecoder(res.Body).Decode(&results); err != nil {
+		return nil, fmt.Errorf("error unmarshaling SPARQL results from %s: %w", s.endpoint, err)
+	}
+	return results.Results.Bindings, nil
+}
+
+// Answers every triple whose subject is one of the supplied IRIs
+func (s *sparqlClient) describe(subjects ...string) ([]triple, error) {
+	var values strings.Builder
+	for _, subject := range subjects {
+		fmt.Fprintf(&values, " <%s>", subject)
+	}
+	solutions, err := s.query(fmt.Sprintf("SELECT ?s ?p ?o WHERE { VALUES ?s {%s } ?s ?p ?o }", values.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	triples := make([]triple, 0, len(solutions))
+	for _, solution := range solutions {
+		o := solution["o"]
+		triples = append(triples, triple{
+			Subject:   solution["s"].Value,
+			Predicate: solution["p"].Value,
+			Object:    o.Value,
+			Literal:   o.Type == "literal" || o.Type == "typed-literal",
+			Lang:      o.Lang,
+			Datatype:  o.Datatype,
+		})
+	}
+	return triples, nil
+}
+
+// Answers the IRIs Alpaca may have indexed a resource as: its JSON-LD URI, under either scheme of the Drupal base URL,
+// e.g. "https://islandora-idc.traefik.me/node/12?_format=jsonld"
+func subjectIris(baseUrl string, res *JsonApiResource) []string {
+	host := baseUrl
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	host = strings.TrimSuffix(host, "/")
+	return []string{
+		fmt.Sprintf("http://%s/%s?_format=jsonld", host, res.entityPath()),
+		fmt.Sprintf("https://%s/%s?_format=jsonld", host, res.entityPath()),
+	}
+}
+
+// Verifies that the triples of every node and agent are present in the triplestore with the expected predicates: the
+// `dcterms:title` and `pcdm:memberOf` of nodes, and the `schema:name` and typed relations (e.g. `schema:knows`) of
+// persons, families and corporate bodies.  A resource without any triples has not been indexed.
+func (c *jsonApiClient) verifyTriplestore(s *sparqlClient, report *Report) {
+	idx := newCrawlIndex(c.crawl(report, "triplestore", triplestoreEntities))
+
+	for _, t := range sortedTypes(idx.crawled) {
+		mapping, ok := triplestoreMappings[t]
+		if !ok {
+			continue
+		}
+		for i := range idx.crawled[t] {
+			res := &idx.crawled[t][i]
+			report.Checked++
+			if res.entityPath() == "" {
+				report.error("triplestore", res.String(), "unable to form the IRI of '%s': it has no internal id", res.label())
+				continue
+			}
+
+			triples, err := s.describe(subjectIris(c.baseUrl, res)...)
+			if err != nil {
+				report.error("triplestore", res.String(), "unable to query the triplestore: %s", err)
+				continue
+			}
+			if len(triples) == 0 {
+				report.error("triplestore", res.String(), "'%s' is not indexed in the triplestore", res.label())
+				continue
+			}
+			report.Counts["indexed"]++
+
+			var labels []string
+			for _, o := range objects(triples, mapping.label) {
+				labels = append(labels, o.Object)
+			}
+			if !contains(labels, res.label()) {
+				report.add(Finding{
+					Level:    LevelError,
+					Check:    "triplestore",
+					Subject:  res.String(),
+					Field:    mapping.label,
+					Expected: res.label(),
+					Actual:   strings.Join(labels, ", "),
+					Message:  fmt.Sprintf("the label of '%s' in the triplestore does not match Drupal", res.label()),
+				})
+			}
+
+			expected := make(map[string][]RelData)
+			for field, predicate := range mapping.fields {
+				expected[predicate] = append(expected[predicate], res.related(field)...)
+			}
+			for _, target := range res.related("field_relationships") {
+				if relType, err := target.metaString("rel_type"); err == nil {
+					predicate := expandCurie(relType)
+					expected[predicate] = append(expected[predicate], target)
+				}
+			}
+			var predicates []string
+			for predicate := range expected {
+				predicates = append(predicates, predicate)
+			}
+			sort.Strings(predicates)
+			for _, predicate := range predicates {
+				c.compareTriplestoreReferences(idx, res, predicate, expected[predicate], triples, report)
+			}
+		}
+	}
+}
+
+// Compares the targets of the relationships of res expected as objects of predicate with the objects present in the
+// triplestore
+func (c *jsonApiClient) compareTriplestoreReferences(idx *crawlIndex, res *JsonApiResource, predicate string, targets []RelData,
+	triples []triple, report *Report) {
+
+	actual := make(map[string]bool)
+	for _, o := range objects(triples, predicate) {
+		actual[entityPathOf(o.Object)] = true
+	}
+
+	var missing []string
+	for _, target := range targets {
+		if target.Id == missingId {
+			continue
+		}
+		resolved := idx.byId[target.Id]
+		if resolved == nil {
+			resolved, _ = c.resolve(target.JsonApiData)
+		}
+		if resolved == nil || resolved.entityPath() == "" {
+			missing = append(missing, fmt.Sprintf("%s %s", target.Type, target.Id))
+		} else if !actual[resolved.entityPath()] {
+			missing = append(missing, resolved.entityPath())
+		}
+	}
+
+	if len(missing) > 0 {
+		var present []string
+		for _, o := range objects(triples, predicate) {
+			present = append(present, o.Object)
+		}
+		report.add(Finding{
+			Level:    LevelError,
+			Check:    "triplestore",
+			Subject:  res.String(),
+			Field:    predicate,
+			Expected: strings.Join(missing, ", "),
+			Actual:   strings.Join(present, ", "),
+			Message:  fmt.Sprintf("triples of '%s' are missing from the triplestore", res.label()),
+		})
+	}
+}
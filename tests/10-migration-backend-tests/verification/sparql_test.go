@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// An in-process stand-in for a SPARQL endpoint, answering the triples of the subjects named by the VALUES clause of
+// each query
+type fakeSparql struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	triples []triple
+	queries []string
+}
+
+var valuesIris = regexp.MustCompile(`<([^>]+)>`)
+
+// Starts a fake SPARQL endpoint which is closed when the test completes
+func newFakeSparql(t *testing.T) *fakeSparql {
+	fake := &fakeSparql{}
+	fake.Server = httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// Adds a triple with an IRI object to the fake triplestore
+func (fake *fakeSparql) relate(subject, predicate, object string) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.triples = append(fake.triples, triple{Subject: subject, Predicate: predicate, Object: object})
+}
+
+// Adds a triple with a literal object to the fake triplestore
+func (fake *fakeSparql) state(subject, predicate, object string) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.triples = append(fake.triples, triple{Subject: subject, Predicate: predicate, Object: object, Literal: true})
+}
+
+func (fake *fakeSparql) serve(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	q := r.FormValue("query")
+	fake.queries = append(fake.queries, q)
+	values := q[strings.Index(q, "VALUES"):strings.Index(q, "}")]
+	subjects := make(map[string]bool)
+	for _, m := range valuesIris.FindAllStringSubmatch(values, -1) {
+		subjects[m[1]] = true
+	}
+
+	bindings := []map[string]sparqlTerm{}
+	for _, t := range fake.triples {
+		if !subjects[t.Subject] {
+			continue
+		}
+		o := sparqlTerm{Type: "uri", Value: t.Object}
+		if t.Literal {
+			o.Type = "literal"
+		}
+		bindings = append(bindings, map[string]sparqlTerm{
+			"s": {Type: "uri", Value: t.Subject},
+			"p": {Type: "uri", Value: t.Predicate},
+			"o": o,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/sparql-results+json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"head":    map[string]interface{}{"vars": []string{"s", "p", "o"}},
+		"results": map[string]interface{}{"bindings": bindings},
+	})
+}
+
+func Test_ExpandCurie(t *testing.T) {
+	assert.Equal(t, SchemaKnows, expandCurie("schema:knows"))
+	assert.Equal(t, PcdmMemberOf, expandCurie("pcdm:memberOf"))
+	assert.Equal(t, "unknown:predicate", expandCurie("unknown:predicate"))
+}
+
+// Populates the fakes with a repository item that is the member of a collection, and two persons who know each
+// other.  Each is indexed under the http IRI of the Drupal base URL.
+func populateSparqlFakes(fake *fakeJsonApi, sparql *fakeSparql) (object, collection, personOne, personTwo string) {
+	populateIntegrityFake(fake)
+	fake.set(collectionId, "drupal_internal__nid", 1)
+	fake.set(objectId, "drupal_internal__nid", 12)
+	fake.set(personOneId, "drupal_internal__tid", 5)
+	fake.set(personTwoId, "drupal_internal__tid", 6)
+
+	object, collection = fake.URL+"/node/12?_format=jsonld", fake.URL+"/node/1?_format=jsonld"
+	personOne, personTwo = fake.URL+"/taxonomy/term/5?_format=jsonld", fake.URL+"/taxonomy/term/6?_format=jsonld"
+
+	sparql.state(collection, DctermsTitle, "Test Collection One")
+	sparql.state(object, DctermsTitle, "Repository Item One")
+	sparql.relate(object, PcdmMemberOf, collection)
+	sparql.state(personOne, SchemaName, personOneName)
+	sparql.relate(personOne, SchemaKnows, personTwo)
+	sparql.state(personTwo, SchemaName, personTwoName)
+	sparql.relate(personTwo, SchemaKnows, personOne)
+	return
+}
+
+func Test_VerifyTriplestore_Consistent(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	sparql := newFakeSparql(t)
+	populateSparqlFakes(fake, sparql)
+
+	report := newReport("triplestore", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyTriplestore(newSparqlClient(sparql.URL, "", ""), report)
+
+	assert.Equal(t, 0, len(report.Findings), "unexpected findings: %v", report.Findings)
+	assert.Equal(t, 4, report.Checked)
+	assert.Equal(t, 4, report.Counts["indexed"])
+	assert.Contains(t, sparql.queries[0], "VALUES ?s")
+}
+
+func Test_VerifyTriplestore_Inconsistent(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	sparql := newFakeSparql(t)
+	object, _, personOne, _ := populateSparqlFakes(fake, sparql)
+
+	// the collection and the second person were never indexed, and the first person is stale: its name differs, and
+	// it knows no one.  Entities are referred to by path, regardless of the host of their IRI.
+	sparql.triples = nil
+	sparql.state(object, DctermsTitle, "Repository Item One")
+	sparql.relate(object, PcdmMemberOf, "https://islandora-idc.traefik.me/node/1?_format=jsonld")
+	sparql.state(personOne, SchemaName, "Adams, Ansel")
+	fake.add(fakeResource{Type: "taxonomy_term--person", Id: "no-tid", Attributes: map[string]interface{}{"name": "Nobody"}})
+
+	report := newReport("triplestore", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyTriplestore(newSparqlClient(sparql.URL, "", ""), report)
+	assert.True(t, report.failed())
+
+	var problems []string
+	for _, f := range report.Findings {
+		assert.Equal(t, "triplestore", f.Check)
+		problems = append(problems, fmt.Sprintf("%s %s: %s", f.Subject, f.Field, f.Message))
+	}
+	assert.Equal(t, []string{
+		"node--collection_object " + collectionId + " : 'Test Collection One' is not indexed in the triplestore",
+		"taxonomy_term--person " + personOneId + " " + SchemaName + ": the label of '" + personOneName + "' in the triplestore does not match Drupal",
+		"taxonomy_term--person " + personOneId + " " + SchemaKnows + ": triples of '" + personOneName + "' are missing from the triplestore",
+		"taxonomy_term--person " + personTwoId + " : '" + personTwoName + "' is not indexed in the triplestore",
+		"taxonomy_term--person no-tid : unable to form the IRI of 'Nobody': it has no internal id",
+	}, problems)
+}
@@ -0,0 +1,614 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The entity types crawled to verify technical metadata
+var technicalEntities = map[string]bool{
+	"media": true,
+	"file":  true,
+}
+
+// MIME types naming the same format as another, keyed by alias
+var mimeAliases = map[string]string{
+	"audio/m4a":      "audio/mp4",
+	"audio/mp3":      "audio/mpeg",
+	"audio/mpeg3":    "audio/mpeg",
+	"audio/vnd.wave": "audio/x-wav",
+	"audio/wav":      "audio/x-wav",
+	"audio/wave":     "audio/x-wav",
+	"audio/x-m4a":    "audio/mp4",
+	"audio/x-mpeg":   "audio/mpeg",
+	"image/jpg":      "image/jpeg",
+	"image/pjpeg":    "image/jpeg",
+	"image/x-tiff":   "image/tiff",
+	"text/xml":       "application/xml",
+}
+
+// MIME types identified from content that are too general to show the format of a file: a .docx is a zip, and a
+// .csv is plain text
+var genericMimeTypes = map[string]bool{
+	"application/octet-stream": true,
+	"application/zip":          true,
+	"text/plain":               true,
+}
+
+// The MIME types of the major brands of MP4 and QuickTime files, named by the ftyp box that begins them.  Other ISO base
+// media files, like HEIC and AVIF images, have brands of their own.
+var mp4Brands = map[string]string{
+	"avc1": "video/mp4",
+	"dash": "video/mp4",
+	"f4v ": "video/mp4",
+	"iso2": "video/mp4",
+	"iso4": "video/mp4",
+	"iso5": "video/mp4",
+	"iso6": "video/mp4",
+	"isom": "video/mp4",
+	"M4A ": "audio/mp4",
+	"M4B ": "audio/mp4",
+	"M4V ": "video/mp4",
+	"mp41": "video/mp4",
+	"mp42": "video/mp4",
+	"qt  ": "video/quicktime",
+}
+
+// The codecs of WAVE audio, keyed by the format tag of its fmt chunk
+var wavCodecs = map[uint16]string{
+	0x0001: "pcm",
+	0x0003: "pcm_float",
+	0x0006: "alaw",
+	0x0007: "mulaw",
+	0x0055: "mp3",
+	0xfffe: "extensible",
+}
+
+// Answers a MIME type without parameters, in lower case, and with aliases replaced, e.g. "text/plain; charset=utf-8"
+// -> "text/plain", or "image/jpg" -> "image/jpeg"
+func canonicalMime(mimeType string) string {
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if alias, ok := mimeAliases[mimeType]; ok {
+		return alias
+	}
+	return mimeType
+}
+
+// The number of bytes of content read to identify its MIME type
+const sniffLength = 512
+
+// Identifies the MIME type of content from its first bytes.  Formats http.DetectContentType does not identify (TIFF,
+// MP3 without an ID3 tag, and the brands of MP4 and QuickTime) are identified first.
+func sniffMimeType(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("II*\x00")), bytes.HasPrefix(head, []byte("MM\x00*")):
+		return "image/tiff"
+	case bytes.HasPrefix(head, []byte("ID3")), isMpegAudio(head):
+		return "audio/mpeg"
+	case len(head) >= 12 && string(head[0:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		return "audio/x-wav"
+	case len(head) >= 12 && string(head[4:8]) == "ftyp" && mp4Brands[string(head[8:12])] != "":
+		return mp4Brands[string(head[8:12])]
+	}
+	return canonicalMime(http.DetectContentType(head))
+}
+
+// The technical metadata of content
+type technicalMetadata struct {
+	mimeType string
+	width    int
+	height   int
+	duration float64
+	codecs   []string
+}
+
+// Reads the technical metadata of content of the supplied size.  The MIME type is always answered, even if the
+// content cannot be read as a file of that type.
+func readTechnicalMetadata(r io.ReaderAt, size int64) (*technicalMetadata, error) {
+	head := make([]byte, sniffLength)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	md := &technicalMetadata{mimeType: sniffMimeType(head[:n])}
+
+	err = nil
+	switch md.mimeType {
+	case "image/jpeg", "image/png", "image/gif":
+		config, _, err := image.DecodeConfig(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return md, fmt.Errorf("unable to decode the %s image: %w", md.mimeType, err)
+		}
+		md.width, md.height = config.Width, config.Height
+	case "image/tiff":
+		md.width, md.height, err = tiffDimensions(r)
+	case "audio/x-wav":
+		err = readWav(r, size, md)
+	case "audio/mpeg":
+		err = readMp3(r, size, md)
+	case "audio/mp4", "video/mp4", "video/quicktime":
+		err = readMp4(r, size, md)
+	}
+	return md, err
+}
+
+// Answers the width and height recorded by the first image file directory of a TIFF
+func tiffDimensions(r io.ReaderAt) (int, int, error) {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return 0, 0, fmt.Errorf("unable to read the TIFF header: %w", err)
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if string(header[0:2]) == "MM" {
+		order = binary.BigEndian
+	}
+
+	ifd := int64(order.Uint32(header[4:]))
+	count := make([]byte, 2)
+	if _, err := r.ReadAt(count, ifd); err != nil {
+		return 0, 0, fmt.Errorf("unable to read the TIFF image file directory: %w", err)
+	}
+	entries := make([]byte, 12*int(order.Uint16(count)))
+	if _, err := r.ReadAt(entries, ifd+2); err != nil {
+		return 0, 0, fmt.Errorf("unable to read the TIFF image file directory: %w", err)
+	}
+
+	var width, height int
+	for e := entries; len(e) >= 12; e = e[12:] {
+		var value int
+		switch order.Uint16(e[2:]) {
+		case 3: // SHORT
+			value = int(order.Uint16(e[8:]))
+		case 4: // LONG
+			value = int(order.Uint32(e[8:]))
+		}
+		switch order.Uint16(e[0:]) {
+		case 256: // ImageWidth
+			width = value
+		case 257: // ImageLength
+			height = value
+		}
+	}
+	if width == 0 || height == 0 {
+		return 0, 0, errors.New("the TIFF does not record the dimensions of its image")
+	}
+	return width, height, nil
+}
+
+// Reads the duration and codec of WAVE audio from its fmt and data chunks
+func readWav(r io.ReaderAt, size int64, md *technicalMetadata) error {
+	var (
+		byteRate uint32
+		data     int64 = -1
+	)
+	chunk := make([]byte, 16)
+	for offset := int64(12); offset+8 <= size; {
+		if _, err := r.ReadAt(chunk[:8], offset); err != nil {
+			return fmt.Errorf("unable to read the WAVE chunk at %d: %w", offset, err)
+		}
+		id, length := string(chunk[0:4]), int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		switch id {
+		case "fmt ":
+			if _, err := r.ReadAt(chunk, offset+8); err != nil {
+				return fmt.Errorf("unable to read the WAVE fmt chunk: %w", err)
+			}
+			format := binary.LittleEndian.Uint16(chunk[0:])
+			if codec, ok := wavCodecs[format]; ok {
+				md.codecs = []string{codec}
+			} else {
+				md.codecs = []string{fmt.Sprintf("0x%04x", format)}
+			}
+			byteRate = binary.LittleEndian.Uint32(chunk[8:])
+		case "data":
+			data = length
+		}
+		offset += 8 + length + length%2
+	}
+
+	if byteRate == 0 || data < 0 {
+		return errors.New("the WAVE has no fmt or data chunk")
+	}
+	md.duration = float64(data) / float64(byteRate)
+	return nil
+}
+
+var (
+	// Bit rates in kbit/s of MPEG-1 and MPEG-2 (and 2.5) audio, by layer and bit rate index
+	mpeg1Bitrates = [4][16]int{
+		3: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		2: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		1: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	}
+	mpeg2Bitrates = [4][16]int{
+		3: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		2: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		1: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	}
+	mpeg1SampleRates = [3]int{44100, 48000, 32000}
+)
+
+// An MPEG audio frame header
+type mpegFrame struct {
+	// 1 for MPEG-1, 2 for MPEG-2 and 2.5
+	version    int
+	layer      int
+	bitrate    int
+	sampleRate int
+	padded     bool
+	mono       bool
+}
+
+// Parses the four bytes of an MPEG audio frame header, answering false if they are not one
+func parseMpegFrame(b []byte) (mpegFrame, bool) {
+	if len(b) < 4 || b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return mpegFrame{}, false
+	}
+	versionBits, layerBits := (b[1]>>3)&3, (b[1]>>1)&3
+	bitrateIndex, rateIndex := b[2]>>4, (b[2]>>2)&3
+	if versionBits == 1 || layerBits == 0 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return mpegFrame{}, false
+	}
+
+	f := mpegFrame{layer: int(4 - layerBits), padded: (b[2]>>1)&1 == 1, mono: b[3]>>6 == 3}
+	switch versionBits {
+	case 3:
+		f.version, f.sampleRate = 1, mpeg1SampleRates[rateIndex]
+		f.bitrate = mpeg1Bitrates[layerBits][bitrateIndex]
+	case 2:
+		f.version, f.sampleRate = 2, mpeg1SampleRates[rateIndex]/2
+		f.bitrate = mpeg2Bitrates[layerBits][bitrateIndex]
+	default:
+		f.version, f.sampleRate = 2, mpeg1SampleRates[rateIndex]/4
+		f.bitrate = mpeg2Bitrates[layerBits][bitrateIndex]
+	}
+	return f, true
+}
+
+// Answers the number of bytes of the frame, including its header
+func (f mpegFrame) length() int {
+	padding := 0
+	if f.padded {
+		padding = 1
+	}
+	if f.layer == 1 {
+		return (12*f.bitrate*1000/f.sampleRate + padding) * 4
+	}
+	return f.samples()/8*f.bitrate*1000/f.sampleRate + padding
+}
+
+// Answers true if the first bytes of content are MPEG audio: a frame header, followed by another frame header unless
+// the next frame is beyond the bytes read.  Other content may begin with bytes resembling a single frame header, like
+// the byte order mark of UTF-16LE text.
+func isMpegAudio(head []byte) bool {
+	f, ok := parseMpegFrame(head)
+	if !ok {
+		return false
+	}
+	if next := f.length(); next+4 <= len(head) {
+		_, ok = parseMpegFrame(head[next:])
+		return ok
+	}
+	return len(head) >= sniffLength
+}
+
+// Answers the number of samples encoded by each frame
+func (f mpegFrame) samples() int {
+	switch {
+	case f.layer == 1:
+		return 384
+	case f.layer == 3 && f.version == 2:
+		return 576
+	}
+	return 1152
+}
+
+// Reads the duration and codec of MPEG audio.  The duration is the number of frames recorded by a Xing, Info or VBRI
+// header, or is estimated from the bit rate of the first frame if there is none.
+func readMp3(r io.ReaderAt, size int64, md *technicalMetadata) error {
+	var start int64
+	id3 := make([]byte, 10)
+	if _, err := r.ReadAt(id3, 0); err == nil && string(id3[0:3]) == "ID3" {
+		// the size of an ID3v2 tag is a "syncsafe" integer of 7 bits per byte
+		start = 10 + (int64(id3[6])<<21 | int64(id3[7])<<14 | int64(id3[8])<<7 | int64(id3[9]))
+		if id3[5]&0x10 != 0 {
+			start += 10
+		}
+	}
+
+	buf := make([]byte, 64*1024)
+	n, err := r.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("unable to read the MPEG audio: %w", err)
+	}
+	buf = buf[:n]
+
+	for i := 0; i+4 <= len(buf); i++ {
+		f, ok := parseMpegFrame(buf[i:])
+		if !ok {
+			continue
+		}
+		md.codecs = []string{fmt.Sprintf("mp%d", f.layer)}
+
+		// the Xing or Info header follows the side information of the first frame, and the VBRI header 32 bytes
+		side := 32
+		switch {
+		case f.version == 1 && f.mono, f.version == 2 && !f.mono:
+			side = 17
+		case f.version == 2 && f.mono:
+			side = 9
+		}
+		frames := 0
+		if x := i + 4 + side; x+12 <= len(buf) && (string(buf[x:x+4]) == "Xing" || string(buf[x:x+4]) == "Info") {
+			if binary.BigEndian.Uint32(buf[x+4:])&1 != 0 {
+				frames = int(binary.BigEndian.Uint32(buf[x+8:]))
+			}
+		} else if v := i + 4 + 32; v+18 <= len(buf) && string(buf[v:v+4]) == "VBRI" {
+			frames = int(binary.BigEndian.Uint32(buf[v+14:]))
+		}
+
+		if frames > 0 {
+			md.duration = float64(frames*f.samples()) / float64(f.sampleRate)
+		} else {
+			md.duration = float64(size-start-int64(i)) * 8 / float64(f.bitrate*1000)
+		}
+		return nil
+	}
+	return errors.New("no MPEG audio frame was found")
+}
+
+// Visits the boxes of an ISO base media file (MP4 or QuickTime) between start and end, answering the type and the
+// offsets of the payload of each box
+func mp4Boxes(r io.ReaderAt, start, end int64, visit func(box string, payload, end int64) error) error {
+	header := make([]byte, 16)
+	for offset := start; offset+8 <= end; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return fmt.Errorf("unable to read the box at %d: %w", offset, err)
+		}
+		size, box, payload := int64(binary.BigEndian.Uint32(header[0:])), string(header[4:8]), offset+8
+		switch size {
+		case 0:
+			size = end - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return fmt.Errorf("unable to read the size of the box at %d: %w", offset, err)
+			}
+			size, payload = int64(binary.BigEndian.Uint64(header[8:])), offset+16
+		}
+		if size < payload-offset || offset+size > end {
+			return fmt.Errorf("the %s box at %d has an invalid size %d", box, offset, size)
+		}
+		if err := visit(box, payload, offset+size); err != nil {
+			return err
+		}
+		offset += size
+	}
+	return nil
+}
+
+// Reads the duration, codecs and video dimensions of an MP4 or QuickTime file from its movie header and the sample
+// descriptions and headers of its tracks
+func readMp4(r io.ReaderAt, size int64, md *technicalMetadata) error {
+	var (
+		found   bool
+		handler string
+		width   int
+		height  int
+	)
+	read := func(offset int64, n int) ([]byte, error) {
+		b := make([]byte, n)
+		_, err := r.ReadAt(b, offset)
+		return b, err
+	}
+
+	var visit func(box string, payload, end int64) error
+	visit = func(box string, payload, end int64) error {
+		switch box {
+		case "moov", "mdia", "minf", "stbl":
+			return mp4Boxes(r, payload, end, visit)
+		case "trak":
+			handler, width, height = "", 0, 0
+			if err := mp4Boxes(r, payload, end, visit); err != nil {
+				return err
+			}
+			if handler == "vide" && width > 0 {
+				md.width, md.height = width, height
+			}
+		case "mvhd":
+			b, err := read(payload, 32)
+			if err != nil {
+				return fmt.Errorf("unable to read the movie header: %w", err)
+			}
+			var timescale, duration uint64
+			if b[0] == 1 {
+				timescale, duration = uint64(binary.BigEndian.Uint32(b[20:])), binary.BigEndian.Uint64(b[24:])
+			} else {
+				timescale, duration = uint64(binary.BigEndian.Uint32(b[12:])), uint64(binary.BigEndian.Uint32(b[16:]))
+			}
+			if timescale > 0 {
+				md.duration = float64(duration) / float64(timescale)
+			}
+			found = true
+		case "tkhd":
+			b, err := read(payload, 1)
+			if err != nil {
+				return fmt.Errorf("unable to read the track header: %w", err)
+			}
+			// the width and height are 16.16 fixed point numbers ending the header
+			offset := payload + 76
+			if b[0] == 1 {
+				offset = payload + 88
+			}
+			if b, err = read(offset, 8); err == nil {
+				width, height = int(binary.BigEndian.Uint32(b[0:])>>16), int(binary.BigEndian.Uint32(b[4:])>>16)
+			}
+		case "hdlr":
+			if b, err := read(payload+8, 4); err == nil {
+				handler = string(b)
+			}
+		case "stsd":
+			// the format of the first sample description names the codec of the track
+			if b, err := read(payload+12, 4); err == nil {
+				if codec := strings.TrimSpace(string(b)); codec != "" && !contains(md.codecs, codec) {
+					md.codecs = append(md.codecs, codec)
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := mp4Boxes(r, 0, size, visit); err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("the file has no movie header")
+	}
+	sort.Strings(md.codecs)
+	return nil
+}
+
+// Verifies the technical metadata Drupal records for the file of every media against the content of the file.  Each
+// file is downloaded, and its size, MIME type, the dimensions of images, and the duration and codecs of audio and video
+// are read from its content:
+//
+//   - the size must match the `field_file_size` of the media and the `filesize` of the File entity
+//   - the MIME type identified from the content must match the `field_mime_type` of the media and the `filemime` of
+//     the File entity, unless the content only identifies a general type, like the zip of a .docx
+//   - the dimensions of JPEG, PNG, GIF and TIFF images must match the `field_width` and `field_height` of the media
+//   - WAVE, MPEG and MP4 audio and video must be readable, with a duration
+//
+// A TechnicalRecord is added to the report for every media.
+func (c *jsonApiClient) verifyTechnicalMetadata(report *Report) {
+	idx := newCrawlIndex(c.crawl(report, "techmd", technicalEntities))
+
+	for _, t := range sortedTypes(idx.crawled) {
+		if t.entity() != "media" {
+			continue
+		}
+		fields, known := fixtureFields[t]
+		if !known {
+			report.Counts["unchecked media"] += len(idx.crawled[t])
+			report.warning("techmd", string(t), "the file field of %s is not known, so its %d media were not checked", t, len(idx.crawled[t]))
+			continue
+		}
+		// media without a file, like remote video, have no technical metadata
+		mapping, ok := fields["uri"]
+		if !ok || mapping.kind != fileField {
+			continue
+		}
+		for i := range idx.crawled[t] {
+			c.verifyMediaTechnicalMetadata(idx, &idx.crawled[t][i], mapping.field, report)
+		}
+	}
+}
+
+// Verifies the technical metadata of a single media, whose file is the target of the field
+func (c *jsonApiClient) verifyMediaTechnicalMetadata(idx *crawlIndex, media *JsonApiResource, field string, report *Report) {
+	report.Checked++
+	record := TechnicalRecord{Media: media.Id}
+	defer func() { report.Technical = append(report.Technical, record) }()
+	fail := func(field, expected, actual, format string, args ...interface{}) {
+		report.add(Finding{
+			Level:    LevelError,
+			Check:    "techmd",
+			Subject:  media.String(),
+			Field:    field,
+			Expected: expected,
+			Actual:   actual,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	var file *JsonApiResource
+	for _, target := range media.related(field) {
+		if file = idx.byId[target.Id]; file == nil && target.Id != missingId {
+			file, _ = c.resolve(target.JsonApiData)
+		}
+	}
+	if file == nil {
+		fail(field, "", "", "'%s' has no file", media.label())
+		return
+	}
+	record.File = file.Id
+	uri, _ := file.Attributes["uri"].(map[string]interface{})
+	u := scalarString(uri["url"])
+	if u == "" {
+		fail(field, "", "", "the file '%s' of '%s' has no url", file.label(), media.label())
+		return
+	}
+
+	tmp, err := ioutil.TempFile("", "idc-verify-techmd-")
+	if err != nil {
+		fail(field, "", "", "unable to download '%s': %s", file.label(), err)
+		return
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	size, err := c.download(u, tmp)
+	if err != nil {
+		fail(field, "", "", "unable to retrieve the content of '%s': %s", file.label(), err)
+		return
+	}
+	record.Size = size
+	md, mdErr := readTechnicalMetadata(tmp, size)
+	if md == nil {
+		fail(field, "", "", "unable to read the content of '%s': %s", file.label(), mdErr)
+		return
+	}
+	record.MimeType, record.Width, record.Height = md.mimeType, md.width, md.height
+	record.Duration, record.Codec = md.duration, strings.Join(md.codecs, ", ")
+	failures := report.count(LevelError)
+	defer func() { record.Ok = report.count(LevelError) == failures }()
+
+	actualSize := strconv.FormatInt(size, 10)
+	if expected := scalarString(media.Attributes["field_file_size"]); expected != "" && expected != actualSize {
+		fail("field_file_size", expected, actualSize, "the file size of '%s' does not match the size of its content", media.label())
+	}
+	if expected := scalarString(file.Attributes["filesize"]); expected != "" && expected != actualSize {
+		fail("filesize", expected, actualSize, "the size of '%s' does not match the size of its content", file.label())
+	}
+
+	for _, recorded := range []struct {
+		res   *JsonApiResource
+		field string
+	}{{media, "field_mime_type"}, {file, "filemime"}} {
+		expected := scalarString(recorded.res.Attributes[recorded.field])
+		switch {
+		case expected == "" || canonicalMime(expected) == md.mimeType:
+		case genericMimeTypes[md.mimeType]:
+			report.Counts["unidentified formats"]++
+		default:
+			fail(recorded.field, expected, md.mimeType, "the MIME type of '%s' does not match the format of its content", recorded.res.label())
+		}
+	}
+
+	if mdErr != nil {
+		fail(field, "", "", "unable to read the technical metadata of '%s': %s", file.label(), mdErr)
+		return
+	}
+	if width, height := scalarString(media.Attributes["field_width"]), scalarString(media.Attributes["field_height"]); md.width > 0 && (width != "" || height != "") {
+		if actual := fmt.Sprintf("%dx%d", md.width, md.height); actual != width+"x"+height {
+			fail("field_width, field_height", width+"x"+height, actual, "the dimensions of '%s' do not match the dimensions of its image", media.label())
+		}
+	}
+	if kind := strings.Split(md.mimeType, "/")[0]; (kind == "audio" || kind == "video") && md.duration <= 0 {
+		fail(field, "", "", "the %s of '%s' has no duration", kind, file.label())
+	}
+}
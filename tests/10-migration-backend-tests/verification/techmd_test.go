@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pngContent(t *testing.T, width, height int) []byte {
+	var b bytes.Buffer
+	assert.Nil(t, png.Encode(&b, image.NewGray(image.Rect(0, 0, width, height))))
+	return b.Bytes()
+}
+
+func gifContent(t *testing.T, width, height int) []byte {
+	var b bytes.Buffer
+	assert.Nil(t, gif.Encode(&b, image.NewGray(image.Rect(0, 0, width, height)), nil))
+	return b.Bytes()
+}
+
+// Answers a big-endian TIFF whose image file directory records a SHORT width and a LONG height
+func tiffContent(width, height int) []byte {
+	var b bytes.Buffer
+	b.WriteString("MM\x00*")
+	binary.Write(&b, binary.BigEndian, uint32(8))
+	binary.Write(&b, binary.BigEndian, uint16(2))
+	binary.Write(&b, binary.BigEndian, []uint16{256, 3, 0, 1, uint16(width), 0})
+	binary.Write(&b, binary.BigEndian, []uint16{257, 4, 0, 1})
+	binary.Write(&b, binary.BigEndian, uint32(height))
+	binary.Write(&b, binary.BigEndian, uint32(0))
+	return b.Bytes()
+}
+
+// Answers a WAVE of silent 16-bit PCM of the supplied seconds, at 8000 samples per second
+func wavContent(seconds int) []byte {
+	data := make([]byte, 16000*seconds)
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+len(data)))
+	b.WriteString("WAVEfmt ")
+	binary.Write(&b, binary.LittleEndian, []uint32{16})
+	binary.Write(&b, binary.LittleEndian, []uint16{1, 1})
+	binary.Write(&b, binary.LittleEndian, []uint32{8000, 16000})
+	binary.Write(&b, binary.LittleEndian, []uint16{2, 16})
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(len(data)))
+	b.Write(data)
+	return b.Bytes()
+}
+
+// Answers MPEG-1 Layer III frames of 128 kbit/s at 44.1 kHz, preceded by an ID3v2 tag, and a Xing header in the first
+// frame if xingFrames is not zero
+func mp3Content(frames, xingFrames int) []byte {
+	var b bytes.Buffer
+	b.WriteString("ID3\x03\x00\x00\x00\x00\x00\x0a")
+	b.Write(make([]byte, 10))
+	for i := 0; i < frames; i++ {
+		// 144 * 128000 / 44100 = 417 bytes per frame
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xff, 0xfb, 0x90, 0x00})
+		if i == 0 && xingFrames > 0 {
+			copy(frame[36:], "Xing\x00\x00\x00\x01")
+			binary.BigEndian.PutUint32(frame[44:], uint32(xingFrames))
+		}
+		b.Write(frame)
+	}
+	return b.Bytes()
+}
+
+func mp4Box(box string, payload ...[]byte) []byte {
+	content := bytes.Join(payload, nil)
+	b := make([]byte, 8, 8+len(content))
+	binary.BigEndian.PutUint32(b, uint32(8+len(content)))
+	copy(b[4:], box)
+	return append(b, content...)
+}
+
+// Answers an MP4 of the supplied seconds with a video track of the supplied dimensions and an audio track
+func mp4Content(seconds, width, height int) []byte {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], uint32(seconds*1000))
+
+	track := func(handler, codec string, width, height int) []byte {
+		tkhd := make([]byte, 84)
+		binary.BigEndian.PutUint32(tkhd[76:], uint32(width<<16))
+		binary.BigEndian.PutUint32(tkhd[80:], uint32(height<<16))
+		hdlr := make([]byte, 24)
+		copy(hdlr[8:], handler)
+		stsd := make([]byte, 16)
+		binary.BigEndian.PutUint32(stsd[4:], 1)
+		copy(stsd[12:], codec)
+		return mp4Box("trak", mp4Box("tkhd", tkhd),
+			mp4Box("mdia", mp4Box("hdlr", hdlr), mp4Box("minf", mp4Box("stbl", mp4Box("stsd", stsd)))))
+	}
+
+	return bytes.Join([][]byte{
+		mp4Box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2")),
+		mp4Box("moov", mp4Box("mvhd", mvhd), track("vide", "avc1", width, height), track("soun", "mp4a", 0, 0)),
+		mp4Box("mdat", make([]byte, 64)),
+	}, nil)
+}
+
+func Test_CanonicalMime(t *testing.T) {
+	assert.Equal(t, "image/jpeg", canonicalMime("image/jpg"))
+	assert.Equal(t, "audio/x-wav", canonicalMime("Audio/WAV"))
+	assert.Equal(t, "text/plain", canonicalMime("text/plain; charset=utf-8"))
+	assert.Equal(t, "application/pdf", canonicalMime("application/pdf"))
+}
+
+func Test_ReadTechnicalMetadata(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		content  []byte
+		expected technicalMetadata
+	}{
+		{"png", pngContent(t, 40, 30), technicalMetadata{mimeType: "image/png", width: 40, height: 30}},
+		{"gif", gifContent(t, 7, 9), technicalMetadata{mimeType: "image/gif", width: 7, height: 9}},
+		{"tiff", tiffContent(640, 70000), technicalMetadata{mimeType: "image/tiff", width: 640, height: 70000}},
+		{"wav", wavContent(3), technicalMetadata{mimeType: "audio/x-wav", duration: 3, codecs: []string{"pcm"}}},
+		{"mp3 xing", mp3Content(2, 38), technicalMetadata{mimeType: "audio/mpeg", duration: 38 * 1152 / 44100.0, codecs: []string{"mp3"}}},
+		{"mp3 cbr", mp3Content(10, 0), technicalMetadata{mimeType: "audio/mpeg", duration: 4170 * 8 / 128000.0, codecs: []string{"mp3"}}},
+		{"mp4", mp4Content(12, 1280, 720), technicalMetadata{mimeType: "video/mp4", width: 1280, height: 720, duration: 12, codecs: []string{"avc1", "mp4a"}}},
+		{"pdf", []byte("%PDF-1.4\n"), technicalMetadata{mimeType: "application/pdf"}},
+	} {
+		md, err := readTechnicalMetadata(bytes.NewReader(test.content), int64(len(test.content)))
+		assert.Nil(t, err, test.name)
+		assert.Equal(t, test.expected, *md, test.name)
+	}
+
+	// the format is identified even if its content cannot be read
+	md, err := readTechnicalMetadata(bytes.NewReader([]byte("RIFF\x04\x00\x00\x00WAVE")), 12)
+	assert.NotNil(t, err)
+	assert.Equal(t, "audio/x-wav", md.mimeType)
+}
+
+func Test_SniffMimeType(t *testing.T) {
+	for name, test := range map[string]struct {
+		head     []byte
+		expected string
+	}{
+		"mp3":      {mp3Content(2, 0)[20:], "audio/mpeg"},
+		"mp3 head": {mp3Content(2, 0)[20:24], "application/octet-stream"},
+		// the byte order mark and first character resemble the header of an MPEG frame
+		"utf-16le": {[]byte("\xff\xfeh\x00i\x00 \x00t\x00h\x00e\x00r\x00e\x00"), "text/plain"},
+		"mp4":      {mp4Box("ftyp", []byte("mp42\x00\x00\x00\x00mp42isom")), "video/mp4"},
+		"m4a":      {mp4Box("ftyp", []byte("M4A \x00\x00\x00\x00M4A mp42")), "audio/mp4"},
+		"mov":      {mp4Box("ftyp", []byte("qt  \x00\x00\x02\x00qt  ")), "video/quicktime"},
+		// HEIC and AVIF images are ISO base media files, but not MP4
+		"heic": {mp4Box("ftyp", []byte("heic\x00\x00\x00\x00mif1heic")), "application/octet-stream"},
+		"avif": {mp4Box("ftyp", []byte("avif\x00\x00\x00\x00avifmif1")), "application/octet-stream"},
+		"fits": {[]byte("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<fits>"), "application/xml"},
+	} {
+		assert.Equal(t, test.expected, sniffMimeType(test.head), name)
+	}
+}
+
+// Answers a stored file of the content, recording the supplied MIME type
+func fakeTechnicalFile(fake *fakeJsonApi, id string, content []byte, mimeType string) fakeResource {
+	file := fakeStoredFile(fake, id, string(content), len(content))
+	file.Attributes["filemime"] = mimeType
+	return file
+}
+
+func Test_VerifyTechnicalMetadata_Matches(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	image, audio, video := pngContent(t, 40, 30), wavContent(2), mp4Content(5, 320, 240)
+	fake.add(
+		fakeTechnicalFile(fake, "image-file", image, "image/png"),
+		fakeFileMedia("media--image", "image-media", "image-file", map[string]interface{}{
+			"field_file_size": len(image), "field_mime_type": "image/png", "field_width": 40, "field_height": 30,
+		}),
+		fakeTechnicalFile(fake, "audio-file", audio, "audio/wav"),
+		fakeFileMedia("media--audio", "audio-media", "audio-file", map[string]interface{}{
+			"field_file_size": len(audio), "field_mime_type": "audio/x-wav",
+		}),
+		fakeTechnicalFile(fake, "video-file", video, "video/mp4"),
+		fakeFileMedia("media--video", "video-media", "video-file", map[string]interface{}{
+			"field_file_size": len(video), "field_mime_type": "video/mp4",
+		}),
+		// a .docx is identified as a zip
+		fakeTechnicalFile(fake, "document-file", []byte("PK\x03\x04"), "application/vnd.openxmlformats-officedocument.wordprocessingml.document"),
+		fakeFileMedia("media--document", "document-media", "document-file", map[string]interface{}{"field_file_size": 4}),
+		fakeTechnicalFile(fake, "fits-file", []byte(`<?xml version="1.0" encoding="UTF-8"?><fits/>`), "application/xml"),
+		fakeFileMedia("media--fits_technical_metadata", "fits-media", "fits-file", map[string]interface{}{"field_mime_type": "application/xml"}),
+	)
+
+	report := newReport("techmd", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyTechnicalMetadata(report)
+
+	assert.Equal(t, 0, len(report.Findings), "unexpected findings: %v", report.Findings)
+	assert.Equal(t, 5, report.Checked)
+	assert.Equal(t, 1, report.Counts["unidentified formats"])
+	assert.Equal(t, 5, len(report.Technical))
+	for _, record := range report.Technical {
+		assert.True(t, record.Ok, record.Media)
+	}
+	assert.Contains(t, report.Technical, TechnicalRecord{Media: "video-media", File: "video-file", Size: int64(len(video)),
+		MimeType: "video/mp4", Width: 320, Height: 240, Duration: 5, Codec: "avc1, mp4a", Ok: true})
+}
+
+func Test_VerifyTechnicalMetadata_Mismatches(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	image := pngContent(t, 40, 30)
+	fake.add(
+		// a JPEG that is a PNG, with the wrong dimensions and size
+		fakeTechnicalFile(fake, "image-file", image, "image/jpeg"),
+		fakeFileMedia("media--image", "image-media", "image-file", map[string]interface{}{
+			"field_file_size": len(image) + 1, "field_mime_type": "image/jpg", "field_width": 30, "field_height": 40,
+		}),
+		// audio without a duration
+		fakeTechnicalFile(fake, "audio-file", []byte("RIFF\x04\x00\x00\x00WAVE"), "audio/x-wav"),
+		fakeFileMedia("media--audio", "audio-media", "audio-file", map[string]interface{}{"field_mime_type": "audio/x-wav"}),
+		// the content of the file is gone
+		fakeResource{
+			Type: "file--file",
+			Id:   "missing-file",
+			Attributes: map[string]interface{}{
+				"filename": "missing.mp4",
+				"uri":      map[string]interface{}{"value": "private://missing.mp4", "url": "/system/files/missing.mp4"},
+			},
+		},
+		fakeFileMedia("media--video", "video-media", "missing-file", map[string]interface{}{}),
+	)
+
+	report := newReport("techmd", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyTechnicalMetadata(report)
+	assert.True(t, report.failed())
+
+	var problems []string
+	for _, f := range report.Findings {
+		problems = append(problems, fmt.Sprintf("%s [%s] %s", f.Subject, f.Field, f.Message))
+	}
+	assert.Equal(t, []string{
+		"media--audio audio-media [field_media_audio_file] unable to read the technical metadata of 'audio-file.pdf': the WAVE has no fmt or data chunk",
+		"media--image image-media [field_file_size] the file size of 'image-media' does not match the size of its content",
+		"media--image image-media [field_mime_type] the MIME type of 'image-media' does not match the format of its content",
+		"media--image image-media [filemime] the MIME type of 'image-file.pdf' does not match the format of its content",
+		"media--image image-media [field_width, field_height] the dimensions of 'image-media' do not match the dimensions of its image",
+		"media--video video-media [field_media_video_file] unable to retrieve the content of 'missing.mp4': resource not found: " + fake.URL + "/system/files/missing.mp4",
+	}, problems)
+
+	for _, record := range report.Technical {
+		assert.False(t, record.Ok, record.Media)
+	}
+}
+
+func Test_VerifyTechnicalMetadata_Unchecked(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	fake.add(
+		fakeResource{Type: "media--remote_video", Id: "remote-media", Attributes: map[string]interface{}{"name": "Remote"}},
+		fakeResource{Type: "media--spreadsheet", Id: "spreadsheet-media", Attributes: map[string]interface{}{"name": "Spreadsheet"}},
+	)
+
+	// media without a file are not checked, but media of an unknown type are reported
+	report := newReport("techmd", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyTechnicalMetadata(report)
+	assert.False(t, report.failed())
+	assert.Equal(t, 0, report.Checked)
+	assert.Equal(t, 1, report.Counts["unchecked media"])
+	if assert.Equal(t, 1, len(report.Findings)) {
+		assert.Equal(t, "the file field of media--spreadsheet is not known, so its 1 media were not checked", report.Findings[0].Message)
+	}
+}
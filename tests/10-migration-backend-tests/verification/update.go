@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Ingest CSV columns whose fixture key (see fixtureFields) differs from the column name
+var csvColumnFixtureKeys = map[string]string{
+	"alternative_title":    "alt_title",
+	"copyright":            "copyright_and_use",
+	"jhir_uri":             "jhir",
+	"library_catalog_link": "catalog_link",
+	"table_of_contents":    "toc",
+}
+
+// Answers the values of a cell of an ingest CSV column in the form answered by actualValues for the Drupal field the
+// column is migrated to: quads are reduced to their value, typed relations to "<rel_type> <value>", language value
+// pairs to "<value> (<language>)", and booleans to "true" or "false".
+func csvFieldValues(column string, mapping fixtureField, cell string) []string {
+	if booleanColumns[column] {
+		if normalizeCell(column, cell)[0] == "1" {
+			return []string{"true"}
+		}
+		return []string{"false"}
+	}
+
+	var values []string
+	for _, v := range strings.Split(cell, csvValueSeparator) {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		switch mapping.kind {
+		case referenceField:
+			values = append(values, parseQuad(v, quadDefaults[column]).value)
+		case typedRelationField:
+			if i := strings.Index(v, ";"); i >= 0 {
+				v = fmt.Sprintf("%s %s", v[:i], parseQuad(v[i+1:], quadDefaults[column]).value)
+			}
+			values = append(values, v)
+		case languageValueField:
+			if i := strings.LastIndex(v, ";"); i >= 0 {
+				v = fmt.Sprintf("%s (%s)", v[:i], v[i+1:])
+			}
+			values = append(values, v)
+		default:
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Answers the Drupal field of the resource type an ingest CSV column is migrated to
+func csvColumnField(t DrupalType, column string) (fixtureField, bool) {
+	key := column
+	if k, ok := csvColumnFixtureKeys[column]; ok {
+		key = k
+	}
+	mapping, ok := fixtureFields[t][key]
+	return mapping, ok
+}
+
+// Verifies that re-ingesting a modified CSV updated the nodes ingested from the original CSV.  Rows are matched by
+// local_id (or title), and each node is found by the title in the modified CSV, of which there must be exactly one:
+// the update must not have created a duplicate.  Every column migrated to a field of the node must then hold exactly
+// the values in the modified CSV, whether the column was changed (it was updated) or not (it was preserved).  Multiple
+// values must have been replaced rather than appended to, and emptied columns must have emptied the field.  Finally, a
+// new revision must have been recorded: the node's revision must differ from its revision in the snapshot taken before
+// the update.
+func (c *jsonApiClient) verifyUpdate(t DrupalType, snapshot *entitySnapshot, original, modified *csvTable, report *Report) {
+	originals := make(map[string]map[string]string)
+	for _, row := range original.rows {
+		originals[rowKey(row)] = row
+	}
+
+	unmapped := make(map[string]bool)
+	for _, row := range modified.rows {
+		key := rowKey(row)
+		subject := fmt.Sprintf("%s %s", modified.path, key)
+		report.Checked++
+
+		before, ok := originals[key]
+		if !ok {
+			report.warning("update", subject, "the row is not in %s, so was created rather than updated", original.path)
+			before = map[string]string{}
+		}
+
+		nodes, err := c.find(t, "title", row["title"])
+		if err != nil {
+			report.error("update", subject, "unable to find the %s '%s': %s", t, row["title"], err)
+			continue
+		}
+		switch {
+		case len(nodes) == 0:
+			report.error("update", subject, "no %s is titled '%s'", t, row["title"])
+			continue
+		case len(nodes) > 1:
+			var ids []string
+			for _, n := range nodes {
+				ids = append(ids, n.Id)
+			}
+			report.error("update", subject, "%d %s are titled '%s', the update created duplicates: %s", len(nodes), t, row["title"],
+				strings.Join(ids, ", "))
+			continue
+		}
+		node := &nodes[0]
+		subject = node.String()
+		report.Counts["updated"]++
+
+		for _, column := range modified.columns {
+			if column == "node_id" || column == "local_id" {
+				continue
+			}
+			mapping, ok := csvColumnField(t, column)
+			if !ok {
+				unmapped[column] = true
+				continue
+			}
+			c.compareUpdatedField(node, column, mapping, before[column], row[column], report)
+		}
+
+		verifyNewRevision(node, snapshot, report)
+	}
+
+	var columns []string
+	for column := range unmapped {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		report.warning("update", modified.path, "the column '%s' is not mapped to a field of %s, and was not compared", column, t)
+	}
+}
+
+// Compares the values of a field of an updated node with the values of its column in the modified CSV
+func (c *jsonApiClient) compareUpdatedField(node *JsonApiResource, column string, mapping fixtureField, was, is string, report *Report) {
+	expected := csvFieldValues(column, mapping, is)
+	actual, err := c.actualValues(node, mapping, expected)
+	if err != nil {
+		report.error("update", node.String(), "unable to read the %s of '%s': %s", mapping.field, node.label(), err)
+		return
+	}
+	sort.Strings(expected)
+	sort.Strings(actual)
+
+	changed := !sameValues(mapping.kind, csvFieldValues(column, mapping, was), csvFieldValues(column, mapping, is))
+	if changed {
+		report.Counts["changed fields"]++
+	} else {
+		report.Counts["preserved fields"]++
+	}
+	if sameValues(mapping.kind, expected, actual) {
+		return
+	}
+
+	var message string
+	switch {
+	case !changed:
+		message = fmt.Sprintf("the unchanged %s of '%s' was not preserved", column, node.label())
+	case len(actual) > len(expected) && containsAll(actual, expected):
+		message = fmt.Sprintf("the values of the changed %s of '%s' were appended to rather than replaced", column, node.label())
+	default:
+		message = fmt.Sprintf("the changed %s of '%s' was not updated", column, node.label())
+	}
+	report.add(Finding{
+		Level:    LevelError,
+		Check:    "update",
+		Subject:  node.String(),
+		Field:    mapping.field,
+		Expected: strings.Join(expected, ", "),
+		Actual:   strings.Join(actual, ", "),
+		Message:  message,
+	})
+}
+
+// Answers true if every value of subset is in values
+func containsAll(values, subset []string) bool {
+	for _, v := range subset {
+		if !contains(values, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Verifies that the update recorded a new revision of the node: its revision (drupal_internal__vid) differs from its
+// revision in the snapshot taken before the update
+func verifyNewRevision(node *JsonApiResource, before *entitySnapshot, report *Report) {
+	was, ok := before.Entities[node.Id]
+	if !ok || was.Revision == "" {
+		report.warning("update", node.String(), "unable to determine whether a revision of '%s' was recorded: it has no revision in the snapshot taken before the update",
+			node.label())
+		return
+	}
+	revision := scalarString(node.Attributes["drupal_internal__vid"])
+	if revision == "" {
+		report.warning("update", node.String(), "unable to determine whether a revision of '%s' was recorded: it has no drupal_internal__vid", node.label())
+		return
+	}
+	if revision == was.Revision {
+		report.add(Finding{
+			Level:    LevelError,
+			Check:    "update",
+			Subject:  node.String(),
+			Field:    "drupal_internal__vid",
+			Expected: "a revision other than " + was.Revision,
+			Actual:   revision,
+			Message:  fmt.Sprintf("no new revision of '%s' was recorded by the update", node.label()),
+		})
+		return
+	}
+	report.Counts["revisions"]++
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The CSV ingested before the update
+const originalCsv = `node_id,local_id,title,member_of,subject,collection_number,alternative_title,featured_item,years,notes
+,io_01,Updated Item,:::Test Collection One,,1,Old Title;eng,0,1984,
+`
+
+// The corrected CSV re-ingested by the update: a subject and collection number are added, the alternative title is
+// changed, and the item is featured.  The years are respelled, but their meaning is unchanged.
+const modifiedCsv = `node_id,local_id,title,member_of,subject,collection_number,alternative_title,featured_item,years,notes
+,io_01,Updated Item,:::Test Collection One,":subject::` + personOneName + `",1|2,New Title;eng,1,1984-XX,
+`
+
+// The snapshot taken before the update, in which the node updated by the modified CSV is at revision 7
+var updateSnapshot = &entitySnapshot{Entities: map[string]snapshotEntity{
+	"updated-item": {Type: "node--islandora_object", Label: "Updated Item", Revision: "7"},
+}}
+
+// Adds the node updated by the modified CSV, with the supplied collection numbers and alternative title, at the
+// supplied revision
+func populateUpdateFake(fake *fakeJsonApi, numbers []string, altTitle string, memberOf interface{}, revision int) {
+	populateFake(fake)
+	node := fakeNode("node--islandora_object", "updated-item", "Updated Item", map[string]interface{}{
+		"field_collection_number": numbers,
+		"field_featured_item":     true,
+		"field_years":             []string{"1984"},
+		"drupal_internal__vid":    revision,
+	})
+	node.Relationships = map[string]interface{}{
+		"field_member_of": memberOf,
+		"field_subject":   []fakeRelationship{{Type: "taxonomy_term--person", Id: personOneId}},
+		"field_alternative_title": []fakeRelationship{
+			{Type: "taxonomy_term--language", Id: englishId, Meta: map[string]interface{}{"value": altTitle}},
+		},
+	}
+	fake.add(node)
+}
+
+func readUpdateCsvs(t *testing.T, original, modified string) (*csvTable, *csvTable) {
+	o, err := readCsv("original.csv", strings.NewReader(original), nil)
+	assert.Nil(t, err)
+	m, err := readCsv("modified.csv", strings.NewReader(modified), nil)
+	assert.Nil(t, err)
+	return o, m
+}
+
+func Test_CsvFieldValues(t *testing.T) {
+	mapping, ok := csvColumnField("node--islandora_object", "creator")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"relators:art Adams, Ansel", "relators:pht Weston"},
+		csvFieldValues("creator", mapping, "relators:art;:person::Adams, Ansel|relators:pht;Weston"))
+
+	mapping, _ = csvColumnField("node--islandora_object", "alternative_title")
+	assert.Equal(t, []string{"Alt;ernate (eng)"}, csvFieldValues("alternative_title", mapping, "Alt;ernate;eng"))
+
+	mapping, _ = csvColumnField("node--islandora_object", "member_of")
+	assert.Equal(t, []string{"Collection A", "Collection B"}, csvFieldValues("member_of", mapping, ":::Collection A|node:collection_object:title:Collection B"))
+
+	mapping, _ = csvColumnField("node--islandora_object", "featured_item")
+	assert.Equal(t, []string{"false"}, csvFieldValues("featured_item", mapping, ""))
+
+	mapping, _ = csvColumnField("node--islandora_object", "years")
+	assert.Equal(t, dateField, mapping.kind)
+
+	_, ok = csvColumnField("node--islandora_object", "notes")
+	assert.False(t, ok)
+}
+
+func Test_VerifyUpdate_Updated(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateUpdateFake(fake, []string{"1", "2"}, "New Title",
+		[]fakeRelationship{{Type: "node--collection_object", Id: collectionId}}, 8)
+	original, modified := readUpdateCsvs(t, originalCsv, modifiedCsv)
+
+	report := newReport("update", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyUpdate("node--islandora_object", updateSnapshot, original, modified, report)
+
+	assert.False(t, report.failed(), "unexpected findings: %v", report.Findings)
+	assert.Equal(t, 1, len(report.Findings))
+	assert.Contains(t, report.Findings[0].Message, "'notes' is not mapped")
+	assert.Equal(t, 1, report.Counts["updated"])
+	assert.Equal(t, 4, report.Counts["changed fields"])
+	assert.Equal(t, 3, report.Counts["preserved fields"])
+	assert.Equal(t, 1, report.Counts["revisions"])
+}
+
+func Test_VerifyUpdate_NotUpdated(t *testing.T) {
+	fake := newFakeJsonApi(t)
+	populateUpdateFake(fake, []string{"1", "1", "2"}, "Old Title", nil, 7)
+	fake.add(
+		fakeNode("node--islandora_object", "duplicate-1", "Duplicated Item", nil),
+		fakeNode("node--islandora_object", "duplicate-2", "Duplicated Item", nil),
+	)
+	original, modified := readUpdateCsvs(t, originalCsv, modifiedCsv+",io_02,Duplicated Item,,,,,0,,\n")
+
+	// the snapshot records the revision of the node, which the update leaves unchanged
+	before := newJsonApiClient(fake.URL, "", "").snapshot(newReport("snapshot", fake.URL))
+	assert.Equal(t, "7", before.Entities["updated-item"].Revision)
+
+	report := newReport("update", fake.URL)
+	newJsonApiClient(fake.URL, "", "").verifyUpdate("node--islandora_object", before, original, modified, report)
+	assert.True(t, report.failed())
+
+	assert.Equal(t, []string{
+		"error: node--islandora_object updated-item [field_member_of] the unchanged member_of of 'Updated Item' was not preserved",
+		"error: node--islandora_object updated-item [field_collection_number] the values of the changed collection_number of 'Updated Item' were appended to rather than replaced",
+		"error: node--islandora_object updated-item [field_alternative_title] the changed alternative_title of 'Updated Item' was not updated",
+		"error: node--islandora_object updated-item [drupal_internal__vid] no new revision of 'Updated Item' was recorded by the update",
+		"warning: modified.csv local_id io_02 [] the row is not in original.csv, so was created rather than updated",
+		"error: modified.csv local_id io_02 [] 2 node--islandora_object are titled 'Duplicated Item', the update created duplicates: duplicate-1, duplicate-2",
+		"warning: modified.csv [] the column 'notes' is not mapped to a field of node--islandora_object, and was not compared",
+	}, findingLines(report))
+}
@@ -4,11 +4,11 @@ import (
 	"crypto/sha1"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	. "github.com/logrusorgru/aurora/v3"
 	"github.com/stretchr/testify/assert"
 	"io"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
@@ -24,10 +24,6 @@ const (
 	// TODO: consult env?
 	TestBasedir = "10-migration-backend-tests"
 
-	// The base URL of the test instance of IDC.
-	// TODO: consult env
-	DrupalBaseurl = "https://islandora-idc.traefik.me"
-
 	// Env var name for the base URL to media assets
 	AssetsBaseUrl = "BASE_ASSETS_URL"
 )
@@ -38,7 +34,12 @@ func TestMain(m *testing.M) {
 		err error
 	)
 
+	// the tests of the migrated content are skipped under -short, so the assets are not needed
+	flag.Parse()
 	assetsUrl := os.Getenv(AssetsBaseUrl)
+	if testing.Short() {
+		os.Exit(m.Run())
+	}
 	if assetsUrl != "" {
 		if res, err = http.Get(assetsUrl); err != nil {
 			log.Println(Sprintf(Red("Assets container (%s) is not up, media tests will fail: %s"), assetsUrl, BrightRed(err.Error())))
@@ -56,15 +57,25 @@ func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
 
+// Skips a test of the content migrated into the running IDC stack under `go test -short`, which runs only the tests of
+// idc-verify itself
+func skipUnlessLive(t *testing.T) {
+	if testing.Short() {
+		t.Skip("verifies the migrated content of a running IDC stack")
+	}
+}
+
 // Verifies that the Person migrated by testcafe persons-01.csv and persons-02.csv
 // match the expected fields and values present in taxonomy-person-01.json
 func Test_VerifyTaxonomyTermPerson_Person1(t *testing.T) {
+	skipUnlessLive(t)
 	verifyTaxonomyTermPerson(t, "taxonomy-person-01.json", "Ansel Easton")
 }
 
 // Verifies that the Person migrated by testcafe persons-01.csv and persons-02.csv
 // match the expected fields and values present in taxonomy-person-01.json
 func Test_VerifyTaxonomyTermPerson_Person2(t *testing.T) {
+	skipUnlessLive(t)
 	verifyTaxonomyTermPerson(t, "taxonomy-person-02.json", "Lewis Wickes")
 }
 
@@ -134,6 +145,7 @@ func verifyTaxonomyTermPerson(t *testing.T, fileName string, restOfName string)
 // Taxonomy term name lengths are now configurable in settings.local.php, currently set at 2000 for
 // a name field. This test ensures that these long names can be entered via ingest.
 func Test_VerifyTaxonomyTermLongNamePerson(t *testing.T) {
+	skipUnlessLive(t)
 
 	expectedJson := ExpectedPerson{}
 	unmarshalJson(t, "taxonomy-person-03.json", &expectedJson)
@@ -171,6 +183,7 @@ func Test_VerifyTaxonomyTermLongNamePerson(t *testing.T) {
 }
 
 func Test_VerifyTaxonomyTermAccessRights(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := ExpectedAccessRights{}
 	unmarshalJson(t, "taxonomy-accessrights.json", &expectedJson)
 
@@ -210,6 +223,7 @@ func Test_VerifyTaxonomyTermAccessRights(t *testing.T) {
 // match the expected fields and values present in taxonomy-person-01.json
 // This is testing a term with no parent
 func Test_VerifyTaxonomyTermIslandoraAccessTerms_Term1(t *testing.T) {
+	skipUnlessLive(t)
 	verifyTaxonomyTermIslandoraAccessTerms(t, "taxonomy-accessterms-01.json")
 }
 
@@ -217,6 +231,7 @@ func Test_VerifyTaxonomyTermIslandoraAccessTerms_Term1(t *testing.T) {
 // match the expected fields and values present in taxonomy-person-02.json
 // This is testing a term with a parent
 func Test_VerifyTaxonomyTermIslandoraAccessTerms_Term2(t *testing.T) {
+	skipUnlessLive(t)
 	verifyTaxonomyTermIslandoraAccessTerms(t, "taxonomy-accessterms-02.json")
 }
 
@@ -269,6 +284,7 @@ func verifyTaxonomyTermIslandoraAccessTerms(t *testing.T, fileName string) {
 }
 
 func Test_VerifyTaxonomyCopyrightAndUse(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := ExpectedCopyrightAndUse{}
 	unmarshalJson(t, "taxonomy-copyrightanduse.json", &expectedJson)
 
@@ -305,6 +321,7 @@ func Test_VerifyTaxonomyCopyrightAndUse(t *testing.T) {
 }
 
 func Test_VerifyTaxonomyTermResourceType(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := ExpectedResourceType{}
 	unmarshalJson(t, "taxonomy-resourcetypes.json", &expectedJson)
 
@@ -341,6 +358,7 @@ func Test_VerifyTaxonomyTermResourceType(t *testing.T) {
 }
 
 func Test_VerifyTaxonomyTermFamily(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := ExpectedFamily{}
 	unmarshalJson(t, "taxonomy-family-01.json", &expectedJson)
 
@@ -406,6 +424,7 @@ func Test_VerifyTaxonomyTermFamily(t *testing.T) {
 }
 
 func Test_VerifyTaxonomyTermGenre(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := ExpectedGenre{}
 	unmarshalJson(t, "taxonomy-genre.json", &expectedJson)
 
@@ -442,6 +461,7 @@ func Test_VerifyTaxonomyTermGenre(t *testing.T) {
 }
 
 func Test_VerifyTaxonomyTermGeolocation(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := ExpectedGeolocation{}
 	unmarshalJson(t, "taxonomy-geolocation.json", &expectedJson)
 
@@ -486,6 +506,7 @@ func Test_VerifyTaxonomyTermGeolocation(t *testing.T) {
 }
 
 func Test_VerifyTaxonomySubject(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := ExpectedSubject{}
 	unmarshalJson(t, "taxonomy-subject.json", &expectedJson)
 
@@ -522,6 +543,7 @@ func Test_VerifyTaxonomySubject(t *testing.T) {
 }
 
 func Test_VerifyTaxonomyTermLanguage(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := ExpectedLanguage{}
 	unmarshalJson(t, "taxonomy-language.json", &expectedJson)
 
@@ -559,6 +581,7 @@ func Test_VerifyTaxonomyTermLanguage(t *testing.T) {
 }
 
 func Test_VerifyTaxonomyTermCorporateBody(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := ExpectedCorporateBody{}
 	unmarshalJson(t, "taxonomy-corporatebody-02.json", &expectedJson)
 
@@ -627,6 +650,7 @@ func Test_VerifyTaxonomyTermCorporateBody(t *testing.T) {
 }
 
 func Test_VerifyCollection(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := ExpectedCollection{}
 	unmarshalJson(t, "collection-01.json", &expectedJson)
 
@@ -673,23 +697,23 @@ func Test_VerifyCollection(t *testing.T) {
 	assert.NotNil(t, relData.AltTitle.Data)
 	assert.Equal(t, 2, len(relData.AltTitle.Data))
 	assert.Equal(t, len(expectedJson.AltTitle), len(relData.AltTitle.Data))
-	for i, altTitleData := range relData.AltTitle.Data {
+	for _, altTitleData := range relData.AltTitle.Data {
 		assert.Equal(t, "taxonomy_term", altTitleData.Type.entity())
 		assert.Equal(t, "language", altTitleData.Type.bundle())
-		assert.Equal(t, expectedJson.AltTitle[i].Value, altTitleData.value())
-		assert.Equal(t, expectedJson.AltTitle[i].LangCode, altTitleData.langCode(t))
 	}
+	altTitles := languageStrings(t, relData.AltTitle.Data)
+	assert.True(t, sameLanguageStrings(expectedJson.AltTitle, altTitles), "expected %v, got %v", expectedJson.AltTitle, altTitles)
 
 	// Resolve and verify description values and languages
 	assert.NotNil(t, relData.Description)
 	assert.Equal(t, 2, len(relData.Description.Data))
 	assert.Equal(t, len(expectedJson.Description), len(relData.Description.Data))
-	for i, descData := range relData.Description.Data {
+	for _, descData := range relData.Description.Data {
 		assert.Equal(t, "taxonomy_term", descData.Type.entity())
 		assert.Equal(t, "language", descData.Type.bundle())
-		assert.Equal(t, expectedJson.Description[i].Value, descData.value())
-		assert.Equal(t, expectedJson.Description[i].LangCode, descData.langCode(t))
 	}
+	descriptions := languageStrings(t, relData.Description.Data)
+	assert.True(t, sameLanguageStrings(expectedJson.Description, descriptions), "expected %v, got %v", expectedJson.Description, descriptions)
 
 	// Resolve and verify member_of values
 	assert.NotNil(t, relData.MemberOf)
@@ -739,6 +763,7 @@ func Test_VerifyCollection(t *testing.T) {
 // Node title lengths are now configurable in settings.local.php, currently set at 500 for a node
 // This test ensures that these long node titles can be entered via ingest.
 func Test_VerifyLongNodeTitle(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := ExpectedCollection{}
 	unmarshalJson(t, "collection-03.json", &expectedJson)
 
@@ -768,6 +793,7 @@ func Test_VerifyLongNodeTitle(t *testing.T) {
 }
 
 func Test_VerifyRepositoryItem(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := ExpectedRepoObj{}
 	unmarshalJson(t, "item-01.json", &expectedJson)
 
@@ -871,10 +897,8 @@ func Test_VerifyRepositoryItem(t *testing.T) {
 	// Abstract
 	assert.Equal(t, 2, len(expectedJson.Abstract))
 	assert.Equal(t, len(expectedJson.Abstract), len(relData.Abstract.Data))
-	for i := range relData.Abstract.Data {
-		assert.Equal(t, expectedJson.Abstract[i].Value, relData.Abstract.Data[i].value())
-		assert.Equal(t, expectedJson.Abstract[i].LangCode, relData.Abstract.Data[i].langCode(t))
-	}
+	abstracts := languageStrings(t, relData.Abstract.Data)
+	assert.True(t, sameLanguageStrings(expectedJson.Abstract, abstracts), "expected %v, got %v", expectedJson.Abstract, abstracts)
 
 	// Access Rights
 	assert.Equal(t, 2, len(expectedJson.AccessRights))
@@ -897,10 +921,8 @@ func Test_VerifyRepositoryItem(t *testing.T) {
 	// Alt title
 	assert.Equal(t, 2, len(expectedJson.AltTitle))
 	assert.Equal(t, len(expectedJson.AltTitle), len(relData.AltTitle.Data))
-	for i := range relData.AltTitle.Data {
-		assert.Equal(t, expectedJson.AltTitle[i].Value, relData.AltTitle.Data[i].value())
-		assert.Equal(t, expectedJson.AltTitle[i].LangCode, relData.AltTitle.Data[i].langCode(t))
-	}
+	altTitles := languageStrings(t, relData.AltTitle.Data)
+	assert.True(t, sameLanguageStrings(expectedJson.AltTitle, altTitles), "expected %v, got %v", expectedJson.AltTitle, altTitles)
 
 	// Contributor
 	// TODO: type introspection if Contributor can hold some type other than person
@@ -946,18 +968,14 @@ func Test_VerifyRepositoryItem(t *testing.T) {
 	// Custodial History
 	assert.Equal(t, 2, len(expectedJson.CustodialHistory))
 	assert.Equal(t, len(expectedJson.CustodialHistory), len(relData.CustodialHistory.Data))
-	for i := range relData.CustodialHistory.Data {
-		assert.Equal(t, expectedJson.CustodialHistory[i].Value, relData.CustodialHistory.Data[i].value())
-		assert.Equal(t, expectedJson.CustodialHistory[i].LangCode, relData.CustodialHistory.Data[i].langCode(t))
-	}
+	custodialHistory := languageStrings(t, relData.CustodialHistory.Data)
+	assert.True(t, sameLanguageStrings(expectedJson.CustodialHistory, custodialHistory), "expected %v, got %v", expectedJson.CustodialHistory, custodialHistory)
 
 	// Description
 	assert.Equal(t, 2, len(expectedJson.Description))
 	assert.Equal(t, len(expectedJson.Description), len(relData.Description.Data))
-	for i := range relData.Description.Data {
-		assert.Equal(t, expectedJson.Description[i].Value, relData.Description.Data[i].value())
-		assert.Equal(t, expectedJson.Description[i].LangCode, relData.Description.Data[i].langCode(t))
-	}
+	descriptions := languageStrings(t, relData.Description.Data)
+	assert.True(t, sameLanguageStrings(expectedJson.Description, descriptions), "expected %v, got %v", expectedJson.Description, descriptions)
 
 	// Display Hint
 
@@ -1048,10 +1066,8 @@ func Test_VerifyRepositoryItem(t *testing.T) {
 	// Table of Contents
 	assert.Equal(t, 2, len(expectedJson.TableOfContents))
 	assert.Equal(t, len(expectedJson.TableOfContents), len(relData.TableOfContents.Data))
-	for i := range relData.TableOfContents.Data {
-		assert.Equal(t, expectedJson.TableOfContents[i].LangCode, relData.TableOfContents.Data[i].langCode(t))
-		assert.Equal(t, expectedJson.TableOfContents[i].Value, relData.TableOfContents.Data[i].value())
-	}
+	tableOfContents := languageStrings(t, relData.TableOfContents.Data)
+	assert.True(t, sameLanguageStrings(expectedJson.TableOfContents, tableOfContents), "expected %v, got %v", expectedJson.TableOfContents, tableOfContents)
 }
 
 // Two media with identical file content will have different File entities, but each File entity will reference the
@@ -1059,6 +1075,7 @@ func Test_VerifyRepositoryItem(t *testing.T) {
 // File entities allows the same bytestream to have different file metadata (i.e. be known by one name in one Media,
 // and known by a different name in another Media).
 func Test_VerifyDuplicateMediaAndFile(t *testing.T) {
+	skipUnlessLive(t)
 	// There are two Media with this name that were migrated by testcafe; they use the same file, so the File entity
 	// linked by these Media should be byte-for-byte identical.  The File entities will be different, but their URIs
 	// will reference the same content.
@@ -1137,6 +1154,7 @@ func Test_VerifyDuplicateMediaAndFile(t *testing.T) {
 }
 
 func Test_VerifyMediaDocument(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := &ExpectedMediaGeneric{}
 	unmarshalJson(t, "media-document.json", &expectedJson)
 
@@ -1185,6 +1203,7 @@ func Test_VerifyMediaDocument(t *testing.T) {
 }
 
 func Test_VerifyMediaImage(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := &ExpectedMediaImage{}
 	unmarshalJson(t, "media-image.json", &expectedJson)
 
@@ -1234,6 +1253,7 @@ func Test_VerifyMediaImage(t *testing.T) {
 }
 
 func Test_VerifyMediaExtractedText(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := &ExpectedMediaExtractedText{}
 	expectedType := "media"
 	expectedBundle := "extracted_text"
@@ -1285,6 +1305,7 @@ func Test_VerifyMediaExtractedText(t *testing.T) {
 }
 
 func Test_VerifyMediaFile(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := &ExpectedMediaGeneric{}
 	expectedType := "media"
 	expectedBundle := "file"
@@ -1337,6 +1358,7 @@ func Test_VerifyMediaFile(t *testing.T) {
 }
 
 func Test_VerifyMediaAudio(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := &ExpectedMediaGeneric{}
 	expectedType := "media"
 	expectedBundle := "audio"
@@ -1389,6 +1411,7 @@ func Test_VerifyMediaAudio(t *testing.T) {
 }
 
 func Test_VerifyMediaVideo(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := &ExpectedMediaGeneric{}
 	expectedType := "media"
 	expectedBundle := "video"
@@ -1441,6 +1464,7 @@ func Test_VerifyMediaVideo(t *testing.T) {
 }
 
 func Test_VerifyMediaRemoteVideo(t *testing.T) {
+	skipUnlessLive(t)
 	expectedJson := &ExpectedMediaRemoteVideo{}
 	expectedType := "media"
 	expectedBundle := "remote_video"
@@ -1476,6 +1500,23 @@ func Test_VerifyMediaRemoteVideo(t *testing.T) {
 	//assert.Equal(t, expectedJson.MediaOf, mediaOf.JsonApiData[0].JsonApiAttributes.Title)
 }
 
+// Verifies that migrating repository items, which look up persons, subjects, etc. by name, did not create a duplicate of
+// any term.  Terms sharing an authority link are logged, but are not a failure: the test data legitimately shares
+// links between terms.
+func Test_VerifyNoDuplicateTaxonomyTerms(t *testing.T) {
+	skipUnlessLive(t)
+	report := newReport("duplicates", DrupalBaseurl)
+	newJsonApiClient(DrupalBaseurl, "", "").findDuplicateTerms(report)
+
+	for _, f := range report.Findings {
+		if f.Level == LevelError {
+			t.Errorf("%s", f)
+		} else {
+			log.Printf("%s", f)
+		}
+	}
+}
+
 // Searches the file system for the named file.  The `name` should not contain any path components or separators.
 //
 // This function allows for an IDE to discover test resources while allowing for IDC test framework (the one invoked by
@@ -1534,32 +1575,3 @@ func unmarshalJson(t *testing.T, filename string, value interface{}) {
 	err = json.NewDecoder(expectedFile).Decode(value)
 	assert.Nil(t, err, "Error decoding the content of file %s as JSON: %s", expectedJsonFile, err)
 }
-
-// Unmarshal a JSONAPI response body and assert that exactly one data element is present
-func unmarshalSingleResponse(t *testing.T, body []byte, res *http.Response, value *JsonApiResponse) *JsonApiResponse {
-	unmarshalResponse(t, body, res, value, func(value *JsonApiResponse) {
-		assert.Equal(t, 1, len(value.Data), "Exactly one JSONAPI data element is expected in the response, but found %d element(s)", len(value.Data))
-	})
-	return value
-}
-
-// Unmarshal a JSONAPI response body and perform supplied assertions on the response
-func unmarshalResponse(t *testing.T, body []byte, res *http.Response, value *JsonApiResponse, responseAssertions func(res *JsonApiResponse)) *JsonApiResponse {
-	err := json.Unmarshal(body, value)
-	assert.Nil(t, err, "Error unmarshaling JSONAPI response body: %s", err)
-	if responseAssertions != nil {
-		responseAssertions(value)
-	}
-	return value
-}
-
-// Successfully GET the content at the URL and return the response and body.
-func getResource(t *testing.T, u string) (*http.Response, []byte) {
-	res, err := http.Get(u)
-	log.Printf("Retrieving %s", u)
-	assert.Nil(t, err, "encountered error requesting %s: %s", u, err)
-	assert.Equal(t, 200, res.StatusCode, "%d status encountered when requesting %s", res.StatusCode, u)
-	body, err := ioutil.ReadAll(res.Body)
-	assert.Nil(t, err, "error encountered reading response body from %s: %s", u, err)
-	return res, body
-}
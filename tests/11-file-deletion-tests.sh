@@ -2,6 +2,8 @@
 set -e
 
 TESTCAFE_TESTS_FOLDER="$(pwd)/$(dirname $0)/$(basename $0 .sh)/testcafe"
+EXPECTED_FOLDER="$(pwd)/$(dirname $0)/$(basename $0 .sh)/expected"
+VERIFICATION_FOLDER="$(pwd)/$(dirname $0)/10-migration-backend-tests/verification"
 
 # Start the backend that serves the media files to be migrated
 # Listens internally on port 80 (addressed as http://<assets_container>/assets/)
@@ -9,3 +11,9 @@
 
 # Execute migrations using testcafe
 docker run --network gateway -v "${TESTCAFE_TESTS_FOLDER}":/tests testcafe/testcafe --screenshots path=/tests/screenshots,takeOnFails=true chromium /tests/**/*.js
+
+# Verify the deleted media and its file using go.  Drupal refuses to delete the file of the media, so the fixture
+# expects it to be retained, referred to by no media, with its content still served.
+docker build -t local/migration-backend-tests "${VERIFICATION_FOLDER}"
+docker run --network gateway --rm -e IDC_VERIFY_USER=admin -e IDC_VERIFY_PASSWORD=password -v "${EXPECTED_FOLDER}":/expected local/migration-backend-tests \
+  go run . deletion /expected/deletion-file.json
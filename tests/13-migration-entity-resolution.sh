@@ -9,3 +9,9 @@
 
 # Execute migrations using testcafe
 docker run --network gateway -v "${TESTCAFE_TESTS_FOLDER}":/tests testcafe/testcafe --screenshots path=/tests/screenshots,takeOnFails=true chromium /tests/**/*.js
+
+# Verify every entity lookup of the repository item CSV resolved to an entity of the intended bundle, using go
+VERIFICATION_FOLDER="$(pwd)/$(dirname $0)/10-migration-backend-tests/verification"
+docker build -t local/migration-backend-tests "${VERIFICATION_FOLDER}"
+docker run --network gateway --rm -e IDC_VERIFY_USER=admin -e IDC_VERIFY_PASSWORD=password -v "${TESTCAFE_TESTS_FOLDER}/migrations":/migrations local/migration-backend-tests \
+  go run . resolution /migrations/islandora_object.csv